@@ -0,0 +1,85 @@
+package addcache
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// GetString returns the string stored under key, byte slices are converted.
+// Other values fail with an error wrapping ErrTypeMismatch.
+func GetString(c Cache, key string) (string, error) {
+	data, err := c.Get(key)
+	if err != nil {
+		return "", err
+	}
+	switch v := data.(type) {
+	case string:
+		return v, nil
+	case []byte:
+		return string(v), nil
+	}
+	return "", mismatch(key, data, "string")
+}
+
+// GetBytes returns the byte slice stored under key, strings are converted.
+// Other values fail with an error wrapping ErrTypeMismatch.
+func GetBytes(c Cache, key string) ([]byte, error) {
+	data, err := c.Get(key)
+	if err != nil {
+		return nil, err
+	}
+	switch v := data.(type) {
+	case []byte:
+		return v, nil
+	case string:
+		return []byte(v), nil
+	}
+	return nil, mismatch(key, data, "[]byte")
+}
+
+// GetInt returns the integer stored under key, any integer type Increment accepts as well as
+// decimal strings, which remote backends return, are converted. Other values fail with an error
+// wrapping ErrTypeMismatch.
+func GetInt(c Cache, key string) (int64, error) {
+	data, err := c.Get(key)
+	if err != nil {
+		return 0, err
+	}
+	if v, ok := toInt64(data); ok {
+		return v, nil
+	}
+	var text string
+	switch v := data.(type) {
+	case string:
+		text = v
+	case []byte:
+		text = string(v)
+	default:
+		return 0, mismatch(key, data, "integer")
+	}
+	v, err := strconv.ParseInt(text, 10, 64)
+	if err != nil {
+		return 0, mismatch(key, data, "integer")
+	}
+	return v, nil
+}
+
+// GetAs returns the value stored under key as T, values of another type fail with an error
+// wrapping ErrTypeMismatch. No conversion takes place, T must be the stored type or an
+// interface it implements.
+func GetAs[T any](c Cache, key string) (T, error) {
+	var zero T
+	data, err := c.Get(key)
+	if err != nil {
+		return zero, err
+	}
+	v, ok := data.(T)
+	if !ok {
+		return zero, mismatch(key, data, fmt.Sprintf("%T", &zero)[1:])
+	}
+	return v, nil
+}
+
+func mismatch(key string, data any, want string) error {
+	return fmt.Errorf("%w: %q holds %T, not %s", ErrTypeMismatch, key, data, want)
+}
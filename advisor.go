@@ -0,0 +1,131 @@
+package addcache
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	advisorReservoirSize = 512
+	advisorMaxTracked    = 16384
+)
+
+// TTLRecommendation summarizes observed re-access intervals of a key prefix.
+// RecommendedTTL covers 90 percent of the observed intervals, so most repeated lookups find the entry.
+type TTLRecommendation struct {
+	Prefix         string
+	Samples        int
+	MedianInterval time.Duration
+	P90Interval    time.Duration
+	RecommendedTTL time.Duration
+}
+
+// ttlAdvisor samples a stable subset of keys and records the time between their accesses per prefix.
+type ttlAdvisor struct {
+	threshold uint64
+	mu        sync.Mutex
+	lastSeen  map[string]time.Time
+	prefixes  map[string]*intervalReservoir
+}
+
+type intervalReservoir struct {
+	seen      int
+	intervals []time.Duration
+}
+
+// WithTTLAdvisor samples the given fraction of keys to power Recommendations.
+// Keys are grouped by the part before the first default delimiter.
+func WithTTLAdvisor(sampleRate float64) Option {
+	return func(s *storage) {
+		if sampleRate <= 0 {
+			return
+		}
+		threshold := uint64(math.MaxUint64)
+		if sampleRate < 1 {
+			threshold = uint64(sampleRate * math.MaxUint64)
+		}
+		s.advisor = &ttlAdvisor{
+			threshold: threshold,
+			lastSeen:  make(map[string]time.Time),
+			prefixes:  make(map[string]*intervalReservoir),
+		}
+	}
+}
+
+// observe records an access of the key when it is part of the sample.
+func (s *storage) observe(key string, now time.Time) {
+	a := s.advisor
+	if a == nil || s.hasher.Sum64(key) > a.threshold {
+		return
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	last, ok := a.lastSeen[key]
+	if !ok && len(a.lastSeen) >= advisorMaxTracked {
+		a.lastSeen = make(map[string]time.Time)
+	}
+	a.lastSeen[key] = now
+	if !ok {
+		return
+	}
+
+	prefix := keyPrefix(key)
+	reservoir, ok := a.prefixes[prefix]
+	if !ok {
+		reservoir = &intervalReservoir{}
+		a.prefixes[prefix] = reservoir
+	}
+	reservoir.add(now.Sub(last), s.hasher.Sum64(key)^uint64(now.UnixNano()))
+}
+
+// add keeps a uniform sample of all intervals using reservoir sampling, random drives the replacement.
+func (r *intervalReservoir) add(interval time.Duration, random uint64) {
+	r.seen++
+	if len(r.intervals) < advisorReservoirSize {
+		r.intervals = append(r.intervals, interval)
+		return
+	}
+	if slot := random % uint64(r.seen); slot < advisorReservoirSize {
+		r.intervals[slot] = interval
+	}
+}
+
+// Recommendations returns TTL suggestions per sampled prefix, nil without WithTTLAdvisor.
+func (s *storage) Recommendations() []TTLRecommendation {
+	a := s.advisor
+	if a == nil {
+		return nil
+	}
+
+	a.mu.Lock()
+	recommendations := make([]TTLRecommendation, 0, len(a.prefixes))
+	for prefix, reservoir := range a.prefixes {
+		intervals := append([]time.Duration(nil), reservoir.intervals...)
+		sort.Slice(intervals, func(i, j int) bool { return intervals[i] < intervals[j] })
+		p90 := intervals[len(intervals)*9/10]
+		recommendations = append(recommendations, TTLRecommendation{
+			Prefix:         prefix,
+			Samples:        reservoir.seen,
+			MedianInterval: intervals[len(intervals)/2],
+			P90Interval:    p90,
+			RecommendedTTL: p90.Round(time.Second) + time.Second,
+		})
+	}
+	a.mu.Unlock()
+
+	sort.Slice(recommendations, func(i, j int) bool {
+		return recommendations[i].Prefix < recommendations[j].Prefix
+	})
+	return recommendations
+}
+
+func keyPrefix(key string) string {
+	if i := strings.Index(key, defaultDelimiter); i >= 0 {
+		return key[:i]
+	}
+	return key
+}
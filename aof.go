@@ -0,0 +1,447 @@
+package addcache
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/cipher"
+	"encoding/binary"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// AOFSyncPolicy decides when appended operations reach the disk.
+type AOFSyncPolicy int
+
+const (
+	// AOFSyncEverySecond flushes and syncs the log once per second, at most a second of writes is lost on a crash.
+	AOFSyncEverySecond AOFSyncPolicy = iota
+	// AOFSyncAlways syncs after every operation.
+	AOFSyncAlways
+)
+
+const (
+	aofOpSet    = "set"
+	aofOpDelete = "del"
+	aofOpEpoch  = "epoch"
+	// aofOpLockToken records the last fencing token of TryLock, so tokens keep growing after a restart.
+	aofOpLockToken = "lock-token"
+	// aofOpExpireGroup records ExpireGroupAt with the group as key, the instant as set time and the keys as data.
+	aofOpExpireGroup = "expire-group"
+
+	aofMinCompactRecords = 1024
+	// maxAOFFrame bounds the length of a record read from the log, longer ones mean a corrupt log.
+	maxAOFFrame = 1 << 30
+	// maxAOFFailures bounds the encoding failures kept until the next sync reports them.
+	maxAOFFailures = 1024
+)
+
+const AOFErrorOperation OperationType = "AOFError"
+
+// AOFErrorEvent is passed as data to AOFError hooks for an operation the append-only log could
+// not encode, e.g. a value of a type not registered with gob. The operation took effect in the
+// cache but is missing from the log, later operations are still appended. The hooks run on the
+// next sync of the log.
+type AOFErrorEvent struct {
+	Key string
+	Op  string
+	Err error
+}
+
+// AOFRecord is one operation of the append-only log.
+type AOFRecord struct {
+	Op         string
+	Time       time.Time
+	Key        string
+	Data       any
+	SetTime    time.Time
+	TTL        time.Duration
+	Persistent bool
+	Held       bool
+}
+
+type appendOnlyLog struct {
+	aead    cipher.AEAD
+	path    string
+	policy  AOFSyncPolicy
+	mu      sync.Mutex
+	file    *os.File
+	writer  *bufio.Writer
+	records int
+	// err latches a failed write or sync, the log stops appending as its tail may be torn.
+	err    error
+	failed []AOFErrorEvent
+}
+
+// WithAppendOnlyLog records every write and removal in the file at path and replays it on creation.
+// The log is compacted in the background once it holds twice as many records as live entries,
+// values must be gob encodable and custom types registered with gob.Register, operations that
+// fail to encode are skipped and reported to the AOFError hooks.
+func WithAppendOnlyLog(path string, policy AOFSyncPolicy) Option {
+	return func(s *storage) {
+		s.aof = &appendOnlyLog{path: path, policy: policy}
+	}
+}
+
+// openAppendOnlyLog replays the log into the cache and opens it for appending.
+func (s *storage) openAppendOnlyLog() {
+	l := s.aof
+	l.aead = s.aead
+	event := RestoreEvent{Path: l.path, Restored: s.now()}
+	records, valid, err := readAOF(l.path, l.aead)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		event.Err = err
+	}
+
+	now := s.now()
+	s.mu.Lock()
+	for _, record := range records {
+		switch record.Op {
+		case aofOpSet:
+			entry := record.entry()
+			data, encodeErr := s.encodeValue(record.Key, entry.data)
+			if encodeErr != nil {
+				continue
+			}
+			entry.data = data
+			entry.epoch = s.epochClock
+			entry.version = s.data[record.Key].version + 1
+			entry.meta = &entryMeta{created: entry.setTime}
+			entry.cost = s.namespaceCost(record.Key, entry.data)
+			if previous, ok := s.data[record.Key]; ok {
+				s.countNamespaceLocked(record.Key, previous, -1)
+			}
+			s.countNamespaceLocked(record.Key, entry, 1)
+			s.data[record.Key] = entry
+			s.readMostly.store(record.Key, entry)
+			s.track(record.Key)
+		case aofOpDelete:
+			s.deleteLocked(record.Key)
+		case aofOpEpoch:
+			s.bumpEpochLocked(record.Key)
+		case aofOpLockToken:
+			s.seedLockTokensLocked(record.Data)
+		case aofOpExpireGroup:
+			if keys, ok := record.Data.([]string); ok {
+				s.expireGroupLocked(record.Key, record.SetTime, keys)
+			}
+		}
+	}
+	for key, sd := range s.data {
+		if s.expiredLocked(key, sd, now) {
+			s.deleteLocked(key)
+		}
+	}
+	event.Entries = len(s.data)
+	s.mu.Unlock()
+	if event.Err != nil {
+		// leave a log that could not be decoded, e.g. under another key, untouched
+		l.err = event.Err
+		s.processHooks(RestoreOperation, l.path, event)
+		return
+	}
+
+	file, err := os.OpenFile(l.path, os.O_CREATE|os.O_WRONLY, 0o600)
+	if err == nil {
+		// drop a torn record left by a crash before appending behind it
+		if err = file.Truncate(valid); err == nil {
+			_, err = file.Seek(valid, io.SeekStart)
+		}
+	}
+	if err != nil {
+		l.err = err
+		event.Err = err
+	} else {
+		l.file = file
+		l.writer = bufio.NewWriter(file)
+		l.records = len(records)
+	}
+	s.processHooks(RestoreOperation, l.path, event)
+}
+
+func (r AOFRecord) entry() storageData {
+	return storageData{
+		isPersistence:  r.Persistent,
+		held:           r.Held,
+		setTime:        r.SetTime,
+		expireDuration: r.TTL,
+		data:           r.Data,
+	}
+}
+
+func (s *storage) aofSetRecord(key string, sd storageData, now time.Time) AOFRecord {
+	return AOFRecord{
+		Op:         aofOpSet,
+		Time:       now,
+		Key:        key,
+		Data:       s.valueOf(sd.data),
+		SetTime:    sd.setTime,
+		TTL:        sd.expireDuration,
+		Persistent: sd.isPersistence,
+		Held:       sd.held,
+	}
+}
+
+// putLocked is the single place entries are written, the caller holds the write lock.
+func (s *storage) putLocked(key string, sd storageData) {
+	s.markWrittenLocked(key)
+	if previous, ok := s.data[key]; ok {
+		s.countPriorityLocked(previous.priority, -1)
+		s.countNamespaceLocked(key, previous, -1)
+	}
+	sd.cost = s.namespaceCost(key, sd.data)
+	s.countPriorityLocked(sd.priority, 1)
+	s.countNamespaceLocked(key, sd, 1)
+	s.data[key] = sd
+	s.readMostly.store(key, sd)
+	if s.aof != nil {
+		s.aof.append(s.aofSetRecord(key, sd, s.now()))
+	}
+}
+
+func (l *appendOnlyLog) append(record AOFRecord) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.writer == nil || l.err != nil {
+		return
+	}
+	frame, err := encodeAOFRecord(l.aead, record)
+	if err != nil {
+		if len(l.failed) < maxAOFFailures {
+			l.failed = append(l.failed, AOFErrorEvent{Key: record.Key, Op: record.Op, Err: err})
+		}
+		return
+	}
+	if _, l.err = l.writer.Write(frame); l.err != nil {
+		return
+	}
+	l.records++
+	if l.policy == AOFSyncAlways {
+		l.err = l.syncLocked()
+	}
+}
+
+func (l *appendOnlyLog) sync() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.writer == nil {
+		return l.err
+	}
+	if err := l.syncLocked(); err != nil {
+		l.err = err
+	}
+	return l.err
+}
+
+// takeFailures returns the encoding failures since the last call.
+func (l *appendOnlyLog) takeFailures() []AOFErrorEvent {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	failed := l.failed
+	l.failed = nil
+	return failed
+}
+
+// syncAOF syncs the log and reports the operations it could not encode.
+func (s *storage) syncAOF() error {
+	err := s.aof.sync()
+	s.reportAOFFailures()
+	return err
+}
+
+// reportAOFFailures runs the AOFError hooks of the operations the log could not encode.
+func (s *storage) reportAOFFailures() {
+	for _, failure := range s.aof.takeFailures() {
+		s.processHooks(AOFErrorOperation, failure.Key, failure)
+	}
+}
+
+func (l *appendOnlyLog) syncLocked() error {
+	if err := l.writer.Flush(); err != nil {
+		return err
+	}
+	return l.file.Sync()
+}
+
+func (l *appendOnlyLog) close() error {
+	err := l.sync()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.file != nil {
+		if closeErr := l.file.Close(); err == nil {
+			err = closeErr
+		}
+		l.file, l.writer = nil, nil
+	}
+	return err
+}
+
+func (s *storage) appendOnlyLogLoop(t Ticker) {
+	defer t.Stop()
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-t.C():
+			_ = s.syncAOF()
+			s.compactAOF()
+		}
+	}
+}
+
+// writeAOFStateLocked writes the records of the state beside the entries to a compacted log: the
+// last fencing token and the live expire groups. The caller holds the write lock.
+func (s *storage) writeAOFStateLocked(writer io.Writer, now time.Time) error {
+	var records []AOFRecord
+	if s.lockTokens > 0 {
+		records = append(records, AOFRecord{Op: aofOpLockToken, Time: now, Data: s.lockTokens})
+	}
+	for group, g := range s.expireGroups {
+		if !g.at.After(now) {
+			continue
+		}
+		keys := make([]string, 0, len(g.keys))
+		for key := range g.keys {
+			keys = append(keys, key)
+		}
+		records = append(records, AOFRecord{Op: aofOpExpireGroup, Time: now, Key: group, SetTime: g.at, Data: keys})
+	}
+	for _, record := range records {
+		frame, err := encodeAOFRecord(s.aof.aead, record)
+		if err != nil {
+			return err
+		}
+		if _, err = writer.Write(frame); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// compactAOF rewrites the log from the live entries once it grew to twice their number.
+// It holds the write lock of the cache, readers and writers are blocked while the compacted
+// log is written.
+func (s *storage) compactAOF() {
+	l := s.aof
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.writer == nil || l.err != nil || l.records < aofMinCompactRecords || l.records < 2*len(s.data) {
+		return
+	}
+
+	tmpPath := l.path + ".compact"
+	file, err := os.OpenFile(tmpPath, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o600)
+	if err != nil {
+		return
+	}
+	writer := bufio.NewWriter(file)
+	now := s.now()
+	if err = s.writeAOFStateLocked(writer, now); err != nil {
+		file.Close()
+		os.Remove(tmpPath)
+		return
+	}
+	for key, sd := range s.data {
+		if s.expiredLocked(key, sd, now) {
+			continue
+		}
+		var frame []byte
+		if frame, err = encodeAOFRecord(l.aead, s.aofSetRecord(key, sd, now)); err != nil {
+			// the entry is not in the log either, its failure was reported when it was written
+			err = nil
+			continue
+		}
+		if _, err = writer.Write(frame); err != nil {
+			break
+		}
+	}
+	if err == nil {
+		err = writer.Flush()
+	}
+	if err == nil {
+		err = file.Sync()
+	}
+	if err == nil {
+		err = os.Rename(tmpPath, l.path)
+	}
+	if err != nil {
+		file.Close()
+		os.Remove(tmpPath)
+		return
+	}
+
+	_ = l.writer.Flush()
+	l.file.Close()
+	l.file, l.writer = file, bufio.NewWriterSize(file, 4096)
+	l.records = len(s.data)
+}
+
+// encodeAOFRecord returns the frame of a record, the gob encoded record sealed with aead when
+// set, prefixed with its length so a torn tail can be detected on replay.
+func encodeAOFRecord(aead cipher.AEAD, record AOFRecord) ([]byte, error) {
+	var buffer bytes.Buffer
+	if err := gob.NewEncoder(&buffer).Encode(record); err != nil {
+		return nil, err
+	}
+	payload := buffer.Bytes()
+	if aead != nil {
+		var err error
+		if payload, err = seal(aead, payload); err != nil {
+			return nil, err
+		}
+	}
+	frame := make([]byte, 4, 4+len(payload))
+	binary.BigEndian.PutUint32(frame, uint32(len(payload)))
+	return append(frame, payload...), nil
+}
+
+// readAOF returns the complete records of the log and the offset after the last one.
+func readAOF(path string, aead cipher.AEAD) ([]AOFRecord, int64, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer file.Close()
+	return decodeAOF(file, aead)
+}
+
+func decodeAOF(r io.Reader, aead cipher.AEAD) ([]AOFRecord, int64, error) {
+	reader := bufio.NewReader(r)
+	var records []AOFRecord
+	var offset int64
+	for {
+		var size [4]byte
+		if _, err := io.ReadFull(reader, size[:]); err != nil {
+			return records, offset, nil
+		}
+		length := binary.BigEndian.Uint32(size[:])
+		if length > maxAOFFrame {
+			return records, offset, fmt.Errorf("%w: record of %d bytes", ErrLogCorrupt, length)
+		}
+		// the length is not trusted before the record is there, a torn tail allocates what it holds
+		var buffer bytes.Buffer
+		if n, err := io.CopyN(&buffer, reader, int64(length)); err != nil || n < int64(length) {
+			return records, offset, nil
+		}
+		payload := buffer.Bytes()
+		frame := len(payload)
+		if aead != nil {
+			var err error
+			if payload, err = open(aead, payload); err != nil {
+				return records, offset, err
+			}
+		}
+		var record AOFRecord
+		if err := gob.NewDecoder(bytes.NewReader(payload)).Decode(&record); err != nil {
+			return records, offset, err
+		}
+		records = append(records, record)
+		offset += int64(len(size) + frame)
+	}
+}
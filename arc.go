@@ -0,0 +1,148 @@
+package addcache
+
+import (
+	"container/list"
+	"sync"
+)
+
+// arcList names the four lists of the ARC policy.
+type arcList int
+
+const (
+	arcRecent arcList = iota
+	arcFrequent
+	arcRecentGhost
+	arcFrequentGhost
+)
+
+type arcEntry struct {
+	list    arcList
+	element *list.Element
+}
+
+// arcPolicy implements the adaptive replacement cache of Megiddo and Modha. Resident keys seen
+// once live in the recent list, keys seen again in the frequent list. Removed keys are remembered
+// in ghost lists, a write of a ghost shifts the target size of the recent list towards the list
+// that would have kept it, which adapts the policy between recency and frequency.
+type arcPolicy struct {
+	mu       sync.Mutex
+	capacity int
+	target   int
+	lists    [4]*list.List
+	entries  map[string]arcEntry
+}
+
+// NewARCPolicy evicts with the adaptive replacement cache algorithm, which resists scans that
+// flush an LRU cache and keeps hot keys like LFU without pinning formerly hot ones. Capacity is
+// the entry limit of the cache and bounds the ghost lists remembering evicted keys.
+func NewARCPolicy(capacity int) EvictionPolicy {
+	p := &arcPolicy{capacity: capacity, entries: make(map[string]arcEntry)}
+	for i := range p.lists {
+		p.lists[i] = list.New()
+	}
+	return p
+}
+
+func (p *arcPolicy) OnSet(key string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	entry, ok := p.entries[key]
+	switch {
+	case !ok:
+		p.pushLocked(key, arcRecent)
+		p.trimGhostsLocked()
+	case entry.list == arcRecentGhost:
+		p.target = minInt(p.capacity, p.target+maxInt(p.lists[arcFrequentGhost].Len()/p.lists[arcRecentGhost].Len(), 1))
+		p.moveLocked(key, arcFrequent)
+	case entry.list == arcFrequentGhost:
+		p.target = maxInt(0, p.target-maxInt(p.lists[arcRecentGhost].Len()/p.lists[arcFrequentGhost].Len(), 1))
+		p.moveLocked(key, arcFrequent)
+	default:
+		p.moveLocked(key, arcFrequent)
+	}
+}
+
+func (p *arcPolicy) OnGet(key string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if entry, ok := p.entries[key]; ok && (entry.list == arcRecent || entry.list == arcFrequent) {
+		p.moveLocked(key, arcFrequent)
+	}
+}
+
+// OnRemove turns a resident key into a ghost of its list.
+func (p *arcPolicy) OnRemove(key string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	entry, ok := p.entries[key]
+	if !ok {
+		return
+	}
+	switch entry.list {
+	case arcRecent:
+		p.moveLocked(key, arcRecentGhost)
+	case arcFrequent:
+		p.moveLocked(key, arcFrequentGhost)
+	}
+	p.trimGhostsLocked()
+}
+
+// Victim takes the least recently used key of the recent list while it exceeds its target size,
+// of the frequent list otherwise, and falls back to the other list.
+func (p *arcPolicy) Victim(evictable func(key string) bool) (string, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	order := []arcList{arcFrequent, arcRecent}
+	if p.lists[arcRecent].Len() > 0 && (p.lists[arcRecent].Len() > p.target || p.lists[arcFrequent].Len() == 0) {
+		order = []arcList{arcRecent, arcFrequent}
+	}
+	for _, l := range order {
+		for element := p.lists[l].Back(); element != nil; element = element.Prev() {
+			if key := element.Value.(string); evictable(key) {
+				return key, true
+			}
+		}
+	}
+	return "", false
+}
+
+func (p *arcPolicy) pushLocked(key string, l arcList) {
+	p.entries[key] = arcEntry{list: l, element: p.lists[l].PushFront(key)}
+}
+
+func (p *arcPolicy) moveLocked(key string, l arcList) {
+	entry := p.entries[key]
+	p.lists[entry.list].Remove(entry.element)
+	p.pushLocked(key, l)
+}
+
+func (p *arcPolicy) dropOldestLocked(l arcList) {
+	if oldest := p.lists[l].Back(); oldest != nil {
+		p.lists[l].Remove(oldest)
+		delete(p.entries, oldest.Value.(string))
+	}
+}
+
+// trimGhostsLocked bounds the recent keys and ghosts to the capacity and all keys to twice of it.
+func (p *arcPolicy) trimGhostsLocked() {
+	for p.lists[arcRecentGhost].Len() > 0 && p.lists[arcRecent].Len()+p.lists[arcRecentGhost].Len() > p.capacity {
+		p.dropOldestLocked(arcRecentGhost)
+	}
+	for p.lists[arcFrequentGhost].Len() > 0 && len(p.entries) > 2*p.capacity {
+		p.dropOldestLocked(arcFrequentGhost)
+	}
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
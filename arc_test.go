@@ -0,0 +1,66 @@
+package addcache
+
+import (
+	"bufio"
+	"os"
+	"strings"
+	"testing"
+)
+
+// readTrace reads a trace of testdata, one key per line, lines starting with # are comments.
+func readTrace(t testing.TB, name string) []string {
+	t.Helper()
+	file, err := os.Open("testdata/" + name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	var keys []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if line := strings.TrimSpace(scanner.Text()); line != "" && !strings.HasPrefix(line, "#") {
+			keys = append(keys, line)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatal(err)
+	}
+	return keys
+}
+
+// hitRatio replays keys against a cache of capacity entries evicting with policy, a miss sets the key.
+func hitRatio(keys []string, capacity int, policy EvictionPolicy) float64 {
+	cache := NewCache(WithMaxEntries(capacity), WithEvictionPolicy(policy))
+	defer cache.Close()
+	hits := 0
+	for _, key := range keys {
+		if _, err := cache.Get(key); err == nil {
+			hits++
+		} else {
+			cache.Set(key, true)
+		}
+	}
+	return float64(hits) / float64(len(keys))
+}
+
+func TestARCHitRatio(t *testing.T) {
+	tests := []struct {
+		trace    string
+		capacity int
+		// advantage is the least ARC has to beat LRU by.
+		advantage float64
+	}{
+		{trace: "zipf.trace", capacity: 100, advantage: 0.02},
+		{trace: "zipf.trace", capacity: 400, advantage: 0.02},
+		{trace: "scan.trace", capacity: 150, advantage: 0.1},
+	}
+	for _, tt := range tests {
+		keys := readTrace(t, tt.trace)
+		arc := hitRatio(keys, tt.capacity, NewARCPolicy(tt.capacity))
+		lru := hitRatio(keys, tt.capacity, NewLRUPolicy())
+		t.Logf("%s, %d entries: ARC %.3f, LRU %.3f", tt.trace, tt.capacity, arc, lru)
+		if arc < lru+tt.advantage {
+			t.Errorf("%s, %d entries: ARC hit ratio %.3f, want at least %.3f over LRU %.3f", tt.trace, tt.capacity, arc, tt.advantage, lru)
+		}
+	}
+}
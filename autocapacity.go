@@ -0,0 +1,170 @@
+package addcache
+
+import (
+	"container/list"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	autoCapacityGrow      = 0.10
+	autoCapacityShrink    = 0.05
+	autoCapacityTolerance = 0.02
+)
+
+// autoCapacity moves the entry limit within bounds to keep the hit ratio near the target. It
+// shadows a cache one growth step larger with a ghost list of the most recently evicted keys, a
+// miss of a ghost is a hit the larger cache would have had.
+type autoCapacity struct {
+	minEntries int
+	maxEntries int
+	target     float64
+	interval   time.Duration
+
+	lastHits   int64
+	lastMisses int64
+
+	mu        sync.Mutex
+	ghosts    *list.List
+	ghostKeys map[string]*list.Element
+	ghostHits int64
+}
+
+// WithAutoCapacity lets the cache adjust its entry limit between minEntries and maxEntries
+// every interval, so memory is only spent where it buys hits. The keys evicted last are kept
+// as ghosts, misses of ghosts estimate the hits a larger limit would add. The limit grows while
+// the hit ratio of the last interval is below target and ghosts were missed, misses of keys
+// never cached do not buy memory, and shrinks while the hit ratio minus that estimate is still
+// comfortably above target. It implies WithMaxEntries, an explicit limit is used as the starting
+// point. Misses take a mutex of the controller to look up the ghosts.
+func WithAutoCapacity(minEntries, maxEntries int, target float64, interval time.Duration) Option {
+	return func(s *storage) {
+		if minEntries <= 0 || maxEntries < minEntries || interval <= 0 {
+			return
+		}
+		start := maxEntries
+		if s.limited() {
+			start = s.capacity.max()
+		}
+		if start < minEntries {
+			start = minEntries
+		}
+		if start > maxEntries {
+			start = maxEntries
+		}
+		thresholds := s.capacity
+		WithMaxEntries(start)(s)
+		if thresholds != nil {
+			s.capacity.thresholds = thresholds.thresholds
+		}
+		s.autoCapacity = &autoCapacity{
+			minEntries: minEntries,
+			maxEntries: maxEntries,
+			target:     target,
+			interval:   interval,
+			ghosts:     list.New(),
+			ghostKeys:  make(map[string]*list.Element),
+		}
+	}
+}
+
+func (s *storage) autoCapacityLoop(t Ticker) {
+	defer t.Stop()
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-t.C():
+			s.adjustCapacity()
+		}
+	}
+}
+
+// growth is the number of entries the limit grows by at once, the ghost list shadows that many.
+func (a *autoCapacity) growth(current int) int {
+	return int(float64(current)*autoCapacityGrow) + 1
+}
+
+// evicted remembers a key evicted for the entry limit as ghost, the caller holds the write lock of the cache.
+func (a *autoCapacity) evicted(key string, current int) {
+	if a == nil {
+		return
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if element, ok := a.ghostKeys[key]; ok {
+		a.ghosts.MoveToFront(element)
+	} else {
+		a.ghostKeys[key] = a.ghosts.PushFront(key)
+	}
+	for a.ghosts.Len() > a.growth(current) {
+		delete(a.ghostKeys, a.ghosts.Remove(a.ghosts.Back()).(string))
+	}
+}
+
+// missed counts a miss of a ghost as hit of the larger cache and forgets the ghost.
+func (a *autoCapacity) missed(key string) {
+	if a == nil {
+		return
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if element, ok := a.ghostKeys[key]; ok {
+		a.ghosts.Remove(element)
+		delete(a.ghostKeys, key)
+		a.ghostHits++
+	}
+}
+
+// takeGhostHits returns the ghost hits since the last call.
+func (a *autoCapacity) takeGhostHits() int64 {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	ghostHits := a.ghostHits
+	a.ghostHits = 0
+	return ghostHits
+}
+
+func (s *storage) adjustCapacity() {
+	a := s.autoCapacity
+	hits := atomic.LoadInt64(&s.counters.hits)
+	misses := atomic.LoadInt64(&s.counters.misses)
+	ghostHits := a.takeGhostHits()
+	windowHits, windowMisses := hits-a.lastHits, misses-a.lastMisses
+	a.lastHits, a.lastMisses = hits, misses
+	if windowHits+windowMisses == 0 {
+		return
+	}
+
+	ratio := float64(windowHits) / float64(windowHits+windowMisses)
+	gain := float64(ghostHits) / float64(windowHits+windowMisses)
+	current := s.capacity.max()
+	next := current
+	switch {
+	case ratio < a.target:
+		// grow only when the shadowed larger cache would have hit more often
+		if ghostHits > 0 {
+			next = current + a.growth(current)
+		}
+	case ratio-gain > a.target+autoCapacityTolerance:
+		// the hits a step fewer entries lose are taken to be at least those a step more would add
+		next = current - int(float64(current)*autoCapacityShrink) - 1
+	}
+	if next < a.minEntries {
+		next = a.minEntries
+	}
+	if next > a.maxEntries {
+		next = a.maxEntries
+	}
+	if next == current {
+		return
+	}
+
+	s.mu.Lock()
+	atomic.StoreInt64(&s.capacity.maxEntries, int64(next))
+	removals := s.evictLocked("")
+	s.queueRemovalsLocked(removals...)
+	s.mu.Unlock()
+	s.finishRemovals(removals...)
+}
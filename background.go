@@ -0,0 +1,64 @@
+//go:build !addcache_nobackground && !tinygo && !wasm
+
+package addcache
+
+import "time"
+
+// backgroundTasks tells whether the cache runs goroutines of its own, see background_none.go.
+const backgroundTasks = true
+
+// startBackground starts the cleanup loop and the periodic work of the enabled options.
+func (s *storage) startBackground(cleanupInterval time.Duration) {
+	// tickers are created before the goroutines start so a fake clock advanced right after
+	// NewCache already drives them
+	s.wg.Add(1)
+	go func(t Ticker) {
+		defer s.wg.Done()
+		s.cleanupLoop(t)
+	}(s.clock.NewTicker(cleanupInterval))
+	if s.autoCapacity != nil {
+		s.wg.Add(1)
+		go func(t Ticker) {
+			defer s.wg.Done()
+			s.autoCapacityLoop(t)
+		}(s.clock.NewTicker(s.autoCapacity.interval))
+	}
+	if s.persistence != nil && s.persistence.interval > 0 {
+		s.wg.Add(1)
+		go func(t Ticker) {
+			defer s.wg.Done()
+			s.persistenceLoop(t)
+		}(s.clock.NewTicker(s.persistence.interval))
+	}
+	if s.aof != nil {
+		s.wg.Add(1)
+		go func(t Ticker) {
+			defer s.wg.Done()
+			s.appendOnlyLogLoop(t)
+		}(s.clock.NewTicker(time.Second))
+	}
+	if s.compaction != nil {
+		s.wg.Add(1)
+		go func(t Ticker) {
+			defer s.wg.Done()
+			s.compactionLoop(t)
+		}(s.clock.NewTicker(s.compaction.interval))
+	}
+	if s.writeBehind != nil {
+		s.wg.Add(1)
+		go func(t Ticker) {
+			defer s.wg.Done()
+			s.writeBehindLoop(t)
+		}(s.clock.NewTicker(s.writeBehind.interval))
+	}
+	if s.memoryPressure != nil {
+		s.wg.Add(1)
+		go func(t Ticker) {
+			defer s.wg.Done()
+			s.memoryPressureLoop(t)
+		}(s.clock.NewTicker(s.memoryPressure.interval))
+	}
+}
+
+// maintain has nothing to do, the loops of startBackground do the periodic work.
+func (s *storage) maintain() {}
@@ -0,0 +1,39 @@
+//go:build addcache_nobackground || tinygo || wasm
+
+package addcache
+
+import "time"
+
+// Builds for TinyGo and WASM, or with the addcache_nobackground tag, start no goroutines of
+// their own. Expired entries are dropped on access and by Sweep, which also performs the work
+// of the background loops: syncing and compacting the append-only log, writing the snapshot
+// file, flushing write-behind writes, tuning the automatic capacity and dropping best effort
+// entries under memory pressure. WithHookWorkers and WithComputeTimeout are ignored, hooks and
+// compute functions run on the calling goroutine, and external policy decisions are refreshed
+// by Sweep. Write-behind batches that fill up are flushed by the write adding the last entry.
+// SetCleanupInterval, PauseCleanup and ResumeCleanup have no effect.
+const backgroundTasks = false
+
+func (s *storage) startBackground(cleanupInterval time.Duration) {}
+
+func (s *storage) maintain() {
+	if s.aof != nil {
+		_ = s.syncAOF()
+		s.compactAOF()
+	}
+	if s.persistence != nil && s.persistence.interval > 0 {
+		_ = s.persist(false)
+	}
+	if s.autoCapacity != nil {
+		s.adjustCapacity()
+	}
+	if s.memoryPressure != nil {
+		s.relieveMemoryPressure()
+	}
+	if s.writeBehind != nil {
+		s.flushWriteBehind()
+	}
+	if s.externalPolicy != nil {
+		s.refreshDueDecisions()
+	}
+}
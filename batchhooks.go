@@ -0,0 +1,79 @@
+package addcache
+
+// ExpireOperation is the operation of batch hooks receiving expired entries, see SetBatchHook.
+const ExpireOperation OperationType = "Expire"
+
+// ExpiredEntry is an entry removed because it expired, Value is the data it held.
+type ExpiredEntry struct {
+	Key   string
+	Value any
+}
+
+// BatchHandlerFunc receives the entries of one operation at once, e.g. all entries a cleanup
+// pass expired. It runs like a HandlerFunc, on the hook workers keyed by the empty key when
+// WithHookWorkers is set.
+type BatchHandlerFunc func(entries []ExpiredEntry)
+
+type registeredBatchHook struct {
+	id      HookID
+	handler BatchHandlerFunc
+}
+
+// SetBatchHook registers handlers receiving the entries of an operation in batches and returns
+// the id removing them again with RemoveHook. ExpireOperation is the only batched operation,
+// its handlers get one call per cleanup pass or Sweep with every entry it expired, and single
+// entries expiring on access. The per key Delete hooks still run.
+func (h *Hooks) SetBatchHook(operationType OperationType, handlerFunctions ...BatchHandlerFunc) HookID {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.batchHandlers == nil {
+		h.batchHandlers = make(map[OperationType][]registeredBatchHook)
+	}
+	h.lastID++
+	for _, handlerFunction := range handlerFunctions {
+		h.batchHandlers[operationType] = append(h.batchHandlers[operationType], registeredBatchHook{id: h.lastID, handler: handlerFunction})
+	}
+	return h.lastID
+}
+
+// RunBatch calls the batch handlers of the operation type in registration order on the calling goroutine.
+func (h *Hooks) RunBatch(operationType OperationType, entries []ExpiredEntry) {
+	h.mu.RLock()
+	hooks := h.batchHandlers[operationType]
+	h.mu.RUnlock()
+	for _, hook := range hooks {
+		h.safeCall(operationType, "", func() {
+			hook.handler(entries)
+		})
+	}
+}
+
+// hasBatchHooks tells whether batch handlers are registered, so callers can skip building batches.
+func (h *Hooks) hasBatchHooks(operationType OperationType) bool {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return len(h.batchHandlers[operationType]) > 0
+}
+
+func (s *storage) SetBatchHook(operationType OperationType, handlerFunctions ...BatchHandlerFunc) HookID {
+	return s.hooks.SetBatchHook(operationType, handlerFunctions...)
+}
+
+// notifyExpiredBatch runs the ExpireOperation batch hooks with the expired entries among removals.
+func (s *storage) notifyExpiredBatch(removals []removal) {
+	if !s.hooks.hasBatchHooks(ExpireOperation) {
+		return
+	}
+	var entries []ExpiredEntry
+	for _, r := range removals {
+		if r.reason == RemovalExpired {
+			entries = append(entries, ExpiredEntry{Key: r.key, Value: s.valueOf(r.entry.data)})
+		}
+	}
+	if len(entries) == 0 {
+		return
+	}
+	s.dispatchHooks(ExpireOperation, "", func() {
+		s.hooks.RunBatch(ExpireOperation, entries)
+	})
+}
@@ -0,0 +1,159 @@
+package addcache
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+)
+
+// LoadResult is the value a BatchLoader found for one key, a TTL of zero or less persists it.
+type LoadResult struct {
+	Value any
+	TTL   time.Duration
+}
+
+// BatchLoader loads many keys missing in the cache in one round trip, e.g. a single SQL IN
+// query. Keys left out of the result are not known to the source.
+type BatchLoader interface {
+	LoadBatch(ctx context.Context, keys []string) (map[string]LoadResult, error)
+}
+
+// RegisterBatchLoader makes GetMulti load the keys starting with prefix it misses with a single
+// LoadBatch call per prefix. The loader of the longest matching prefix is used, keys no batch
+// loader matches are loaded one by one like Get does. Batch loads are not coalesced with
+// concurrent loads of the same keys, WithNegativeTTL and WithLoaderCircuitBreaker apply. A nil
+// loader unregisters the prefix.
+func (s *storage) RegisterBatchLoader(prefix string, loader BatchLoader) {
+	s.loaders.mu.Lock()
+	defer s.loaders.mu.Unlock()
+	if loader == nil {
+		delete(s.loaders.batches, prefix)
+		return
+	}
+	if s.loaders.batches == nil {
+		s.loaders.batches = make(map[string]BatchLoader)
+	}
+	s.loaders.batches[prefix] = loader
+}
+
+// matchBatch returns the prefix and batch loader of the longest prefix of key.
+func (l *loaders) matchBatch(key string) (string, BatchLoader) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	var loader BatchLoader
+	match := ""
+	longest := -1
+	for prefix, candidate := range l.batches {
+		if len(prefix) > longest && strings.HasPrefix(key, prefix) {
+			match, loader, longest = prefix, candidate, len(prefix)
+		}
+	}
+	return match, loader
+}
+
+// GetMulti reads the keys like Get and returns the values found by key, keys missing in the
+// cache and unknown to the loaders are left out. Missing keys are loaded with one call per
+// batch loader, see RegisterBatchLoader. The error is the first failure of a load, the values
+// found are returned along with it.
+func (s *storage) GetMulti(keys []string) (map[string]any, error) {
+	found := make(map[string]any, len(keys))
+	expired := make(map[string]storageData)
+	var missing []string
+	for _, key := range keys {
+		if _, ok := found[key]; ok {
+			continue
+		}
+		if _, ok := expired[key]; ok {
+			continue
+		}
+		sd, _, err := s.lookup(key)
+		if errors.Is(err, ErrCacheKeyNotFound) {
+			expired[key] = sd
+			missing = append(missing, key)
+			continue
+		}
+		if err == nil {
+			found[key], err = s.decodeValue(sd.data)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var firstErr error
+	failed := func(err error) {
+		if firstErr == nil && err != nil && !errors.Is(err, ErrCacheKeyNotFound) {
+			firstErr = err
+		}
+	}
+	var prefixes []string
+	batches := make(map[string][]string)
+	loaders := make(map[string]BatchLoader)
+	for _, key := range missing {
+		prefix, loader := s.loaders.matchBatch(key)
+		if loader == nil {
+			data, _, err := s.loadMissing(key, expired[key])
+			if err == nil {
+				found[key] = data
+			}
+			failed(err)
+			continue
+		}
+		if _, ok := loaders[prefix]; !ok {
+			prefixes = append(prefixes, prefix)
+			loaders[prefix] = loader
+		}
+		if !s.loaders.knownMissing(key, s.now()) {
+			batches[prefix] = append(batches[prefix], key)
+		}
+	}
+	for _, prefix := range prefixes {
+		if len(batches[prefix]) > 0 {
+			failed(s.loadBatch(loaders[prefix], batches[prefix], expired, found))
+		}
+	}
+	return found, firstErr
+}
+
+// loadBatch loads keys with a single LoadBatch call and adds the values to found. A failed call
+// falls back to the expired entries of the keys, see WithLoaderCircuitBreaker, and is only
+// reported when a key has none.
+func (s *storage) loadBatch(loader BatchLoader, keys []string, expired map[string]storageData, found map[string]any) error {
+	guards := make(map[string]*loadGuard, len(keys))
+	for _, key := range keys {
+		guards[key] = s.beginLoad(key)
+	}
+	defer func() {
+		for key, guard := range guards {
+			s.endLoad(key, guard)
+		}
+	}()
+	loaded, err := s.guardLoad(keys[0], func() (any, error) {
+		return loader.LoadBatch(context.Background(), keys)
+	})
+	if err != nil {
+		var unanswered error
+		for _, key := range keys {
+			if !s.staleFallback(expired[key], err) {
+				unanswered = err
+				continue
+			}
+			if data, decodeErr := s.decodeValue(expired[key].data); decodeErr == nil {
+				found[key] = data
+			}
+		}
+		return unanswered
+	}
+	results, _ := loaded.(map[string]LoadResult)
+	for _, key := range keys {
+		result, ok := results[key]
+		if !ok {
+			s.rememberMissing(key, ErrCacheKeyNotFound, guards[key])
+			continue
+		}
+		s.storeLoaded(key, s.loadedEntry(result.Value, result.TTL), guards[key])
+		found[key] = result.Value
+	}
+	return nil
+}
@@ -0,0 +1,57 @@
+package addcache
+
+import "time"
+
+// BinaryKeyCache addresses a Cache with byte slice keys such as protobuf encoded composite keys
+// or raw hashes, without base64 or hex encoding them first. Keys are plain Go strings internally,
+// which hold arbitrary bytes, so a binary key and the string of the same bytes name the same entry.
+// Text based views of the cache, e.g. ExportCSV, Keys or the Redis protocol server, show the raw bytes.
+type BinaryKeyCache struct {
+	cache Cache
+}
+
+// BinaryKeys returns a view of cache taking byte slice keys, the cache stays owned by the caller.
+func BinaryKeys(cache Cache) *BinaryKeyCache {
+	return &BinaryKeyCache{cache: cache}
+}
+
+// Cache returns the underlying cache.
+func (b *BinaryKeyCache) Cache() Cache {
+	return b.cache
+}
+
+func (b *BinaryKeyCache) Set(key []byte, data any) {
+	b.cache.Set(string(key), data)
+}
+
+func (b *BinaryKeyCache) SetPersistent(key []byte, data any) {
+	b.cache.SetPersistent(string(key), data)
+}
+
+func (b *BinaryKeyCache) SetEx(key []byte, data any, duration time.Duration) {
+	b.cache.SetEx(string(key), data, duration)
+}
+
+func (b *BinaryKeyCache) Get(key []byte) (any, error) {
+	return b.cache.Get(string(key))
+}
+
+func (b *BinaryKeyCache) Delete(key []byte) {
+	b.cache.Delete(string(key))
+}
+
+func (b *BinaryKeyCache) GetAndDelete(key []byte) (any, error) {
+	return b.cache.GetAndDelete(string(key))
+}
+
+func (b *BinaryKeyCache) GetAndSet(key []byte, newData any) (any, error) {
+	return b.cache.GetAndSet(string(key), newData)
+}
+
+func (b *BinaryKeyCache) Increment(key []byte, delta int64) (int64, error) {
+	return b.cache.Increment(string(key), delta)
+}
+
+func (b *BinaryKeyCache) Decrement(key []byte, delta int64) (int64, error) {
+	return b.cache.Decrement(string(key), delta)
+}
@@ -0,0 +1,176 @@
+package addcache
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+const BreakerOperation OperationType = "Breaker"
+
+// defaultMaxStaleAge bounds how long past their expiration entries are kept for the stale fallback.
+const defaultMaxStaleAge = time.Hour
+
+// BreakerState is the state of the loader circuit breaker, see WithLoaderCircuitBreaker.
+type BreakerState string
+
+const (
+	// BreakerClosed lets every load through.
+	BreakerClosed BreakerState = "closed"
+	// BreakerOpen fails loads without calling the loader until the cool-down passed.
+	BreakerOpen BreakerState = "open"
+	// BreakerHalfOpen lets a single probe load through, its outcome closes or reopens the breaker.
+	BreakerHalfOpen BreakerState = "half-open"
+)
+
+// BreakerEvent is passed as data to Breaker hooks when the breaker changes state, the key is the
+// key whose load caused the change.
+type BreakerEvent struct {
+	From     BreakerState
+	To       BreakerState
+	Failures int
+}
+
+type breaker struct {
+	threshold int
+	coolDown  time.Duration
+
+	mu       sync.Mutex
+	state    BreakerState
+	failures int
+	openedAt time.Time
+	probing  bool
+}
+
+// WithLoaderCircuitBreaker opens a circuit breaker after failures consecutive failed loads of
+// RegisterLoader loaders or the store of WithWriteThrough, errors other than ErrCacheKeyNotFound
+// count as failure. An open breaker fails loads with ErrCircuitOpen for coolDown, then lets one
+// probe through whose outcome closes or reopens it. While loads fail or the breaker is open,
+// reads are answered with the expired entry of the key if it is still there, GetResult reports
+// it as Stale. Expired entries of keys a loader or the store can load again are kept for up to
+// an hour past their expiration, see WithMaxStaleAge: reads leave them to the cleanup, which
+// removes them only while the breaker is closed. Entries expired longer ago, keys nothing loads
+// and entries invalidated by BumpEpoch are removed as usual. State changes are reported to
+// Breaker hooks.
+func WithLoaderCircuitBreaker(failures int, coolDown time.Duration) Option {
+	return func(s *storage) {
+		if failures <= 0 || coolDown <= 0 {
+			return
+		}
+		s.breaker = &breaker{threshold: failures, coolDown: coolDown, state: BreakerClosed}
+	}
+}
+
+// WithMaxStaleAge sets how long past their expiration the entries of WithLoaderCircuitBreaker are
+// kept for the stale fallback, an hour by default.
+func WithMaxStaleAge(age time.Duration) Option {
+	return func(s *storage) {
+		if age > 0 {
+			s.maxStaleAge = age
+		}
+	}
+}
+
+// allow reports whether a load may call the loader, moving an open breaker past its cool-down to half-open.
+func (b *breaker) allow(now time.Time) (bool, *BreakerEvent) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch b.state {
+	case BreakerOpen:
+		if now.Before(b.openedAt.Add(b.coolDown)) {
+			return false, nil
+		}
+		b.probing = true
+		return true, b.moveLocked(BreakerHalfOpen)
+	case BreakerHalfOpen:
+		if b.probing {
+			return false, nil
+		}
+		b.probing = true
+	}
+	return true, nil
+}
+
+// record accounts the outcome of a load the breaker allowed.
+func (b *breaker) record(err error, now time.Time) *BreakerEvent {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.probing = false
+	if err == nil || errors.Is(err, ErrCacheKeyNotFound) {
+		b.failures = 0
+		if b.state != BreakerClosed {
+			return b.moveLocked(BreakerClosed)
+		}
+		return nil
+	}
+	b.failures++
+	if b.state == BreakerHalfOpen || b.failures >= b.threshold {
+		b.openedAt = now
+		if b.state != BreakerOpen {
+			return b.moveLocked(BreakerOpen)
+		}
+	}
+	return nil
+}
+
+func (b *breaker) moveLocked(to BreakerState) *BreakerEvent {
+	event := &BreakerEvent{From: b.state, To: to, Failures: b.failures}
+	b.state = to
+	return event
+}
+
+// keepsExpired tells the cleanup to leave the entries of retainsStaleLocked in place for the
+// stale fallback while loads are failing.
+func (b *breaker) keepsExpired() bool {
+	if b == nil {
+		return false
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state != BreakerClosed
+}
+
+// retainsStaleLocked tells whether an expired entry is left in place for the stale fallback, the
+// caller holds the lock of the cache.
+func (s *storage) retainsStaleLocked(key string, sd storageData, now time.Time) bool {
+	if s.breaker == nil || sd.isPersistence || s.staleLocked(key, sd) {
+		return false
+	}
+	maxAge := s.maxStaleAge
+	if maxAge == 0 {
+		maxAge = defaultMaxStaleAge
+	}
+	if now.Sub(sd.setTime.Add(sd.expireDuration)) >= maxAge {
+		return false
+	}
+	return s.backing.store != nil || s.loaders.match(key) != nil
+}
+
+// guardLoad runs fetch unless the breaker is open and accounts its outcome, a panicking fetch
+// counts as failure so a probe cannot leave the breaker half-open for good.
+func (s *storage) guardLoad(key string, fetch func() (any, error)) (data any, err error) {
+	if s.breaker == nil {
+		return fetch()
+	}
+	allowed, event := s.breaker.allow(s.now())
+	s.breakerMoved(key, event)
+	if !allowed {
+		return nil, ErrCircuitOpen
+	}
+	err = ErrLoaderPanicked
+	defer func() {
+		s.breakerMoved(key, s.breaker.record(err, s.now()))
+	}()
+	return fetch()
+}
+
+func (s *storage) breakerMoved(key string, event *BreakerEvent) {
+	if event != nil {
+		s.processHooks(BreakerOperation, key, *event)
+	}
+}
+
+// staleFallback tells whether a failed load is answered with the expired entry of the key.
+func (s *storage) staleFallback(expired storageData, err error) bool {
+	return s.breaker != nil && expired.data != nil && err != nil && !errors.Is(err, ErrCacheKeyNotFound)
+}
@@ -1,9 +1,14 @@
 package addcache
 
 import (
+	"context"
+	"crypto/cipher"
 	"errors"
+	"io"
+	"net/http"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -12,23 +17,116 @@ const (
 	defaultCleanup   time.Duration = 30 * time.Second
 )
 
-var ErrCacheKeyNotFound = errors.New("exception.cache.key.not-found")
-
 // Cache implementation core structure
 type Cache interface {
 	Set(key string, data any)
+	SetPersistent(key string, data any)
 	SetEx(key string, data any, duration time.Duration)
 	Get(key string) (any, error)
 	Delete(key string)
+	GetAndDelete(key string) (any, error)
+	GetAndSet(key string, newData any) (any, error)
+	Increment(key string, delta int64) (int64, error)
+	Decrement(key string, delta int64) (int64, error)
 	CreateKey(args ...string) string
 	CreateKeyWithDelimiter(delimiter string, args ...string) string
 	StopCleanup()
-	SetHook(operationType OperationType, handlerFunctions ...HandlerFunc)
+	Close() error
+	SetHook(operationType OperationType, handlerFunctions ...HandlerFunc) HookID
+	RemoveHook(operationType OperationType, id HookID)
+	ClearHooks(operationType OperationType)
+}
+
+// LocalCache is the in memory Cache with instance level introspection.
+type LocalCache interface {
+	Cache
+	SetBatchHook(operationType OperationType, handlerFunctions ...BatchHandlerFunc) HookID
+	Name() string
+	Stats() Stats
+	NamespaceStats(namespace string) (Stats, bool)
+	LockKey(key string) (unlock func())
+	GetOrCompute(key string, duration time.Duration, compute func() (any, error)) (any, error)
+	RegisterLoader(prefix string, loader LoaderFunc)
+	RegisterBatchLoader(prefix string, loader BatchLoader)
+	GetMulti(keys []string) (map[string]any, error)
+	OnEvicted(evictedFunctions ...EvictedFunc)
+	Recommendations() []TTLRecommendation
+	CompressionStats() []CompressionStat
+	Hold(key string) error
+	Release(key string) error
+	SaveTo(w io.Writer) error
+	LoadFrom(r io.Reader) error
+	ImportFrom(ctx context.Context, endpoint, token string, client *http.Client) error
+	Warm(ctx context.Context, loader func(emit func(key string, value any, ttl time.Duration)) error) error
+	ExpireGroupAt(group string, at time.Time, keys ...string) error
+	SwapGeneration(prefix string, newEntries map[string]any) error
+	BumpEpoch(prefix string) uint64
+	Epoch(prefix string) uint64
+	SpaceUsage() SpaceUsage
+	MemoryUsage() MemoryReport
+	SetReadOnly(readOnly bool)
+	SetCleanupInterval(interval time.Duration)
+	PauseCleanup()
+	ResumeCleanup()
+	GetResult(key string) (Result, error)
+	GetScan(key string, dest any) error
+	Inspect(key string) (EntryInfo, error)
+	TopKeys(n int) []KeyStat
+	ExportCSV(w io.Writer) error
+	Keys(prefix string) []string
+	Handler() http.Handler
+	DebugHandler() http.Handler
+	Sweep()
+	TryLock(key string, ttl time.Duration) (Lock, bool)
+	Expire(key string, ttl time.Duration) error
+	HSet(key string, fieldValues map[string]any) (int, error)
+	HGet(key string, field string) (any, error)
+	HGetAll(key string) (map[string]any, error)
+	HDel(key string, fields ...string) (int, error)
+	LPush(key string, values ...any) (int, error)
+	RPush(key string, values ...any) (int, error)
+	LPop(key string) (any, error)
+	RPop(key string) (any, error)
+	BLPop(key string, timeout time.Duration) (any, error)
+	BRPop(key string, timeout time.Duration) (any, error)
+	LRange(key string, start, stop int) ([]any, error)
+	LTrim(key string, start, stop int) error
+	SAdd(key string, members ...string) (int, error)
+	SRem(key string, members ...string) (int, error)
+	SMembers(key string) ([]string, error)
+	SIsMember(key string, member string) (bool, error)
+	SCard(key string) (int, error)
+	ZAdd(key string, scores map[string]float64) (int, error)
+	ZIncrBy(key string, member string, delta float64) (float64, error)
+	ZRem(key string, members ...string) (int, error)
+	ZRange(key string, start, stop int) ([]ZMember, error)
+	ZRevRange(key string, start, stop int) ([]ZMember, error)
+	ZRank(key string, member string) (int, error)
+	ZRevRank(key string, member string) (int, error)
+	ZScore(key string, member string) (float64, error)
+	Watch(pattern string) (<-chan Event, CancelFunc)
+	Subscribe(key string) (<-chan any, CancelFunc)
+	Pin(key string)
+	Unpin(key string)
+	SetWithPriority(key string, data any, ttl time.Duration, priority Priority)
+	SetBestEffort(key string, data any, ttl time.Duration)
 }
 
+// HandlerFunc is called after the operation took effect, handlers of one operation type run in
+// registration order. Hooks run on the calling goroutine unless WithHookWorkers is set, in which
+// case hooks of the same key still run in order. A panicking handler is recovered, reported to
+// the HookErrorHandler and does not prevent the remaining handlers from running.
 type HandlerFunc func(key string, data any)
 type OperationType string
 
+// HookID identifies the handlers registered by one SetHook call.
+type HookID uint64
+
+type registeredHook struct {
+	id      HookID
+	handler HandlerFunc
+}
+
 const (
 	DeleteOperation OperationType = "Delete"
 	CreateOperation OperationType = "Create"
@@ -36,77 +134,346 @@ const (
 
 // local handling of cache implementation
 type storage struct {
-	stop  chan struct{}
-	wg    sync.WaitGroup
-	mu    sync.RWMutex
-	data  map[string]storageData
-	hooks map[OperationType][]HandlerFunc
+	counters             statsCounters
+	name                 string
+	hasher               Hasher
+	keyLocks             *keyLocks
+	stop                 chan struct{}
+	wg                   sync.WaitGroup
+	mu                   sync.RWMutex
+	data                 map[string]storageData
+	hooksMu              sync.RWMutex
+	hooks                Hooks
+	evicted              []EvictedFunc
+	hookPool             *hookPool
+	hookWorkers          *int
+	capacity             *capacityLimit
+	hookErr              HookErrorHandler
+	prefixLimits         []*prefixLimit
+	advisor              *ttlAdvisor
+	closeOnce            sync.Once
+	autoCapacity         *autoCapacity
+	defaultTTL           time.Duration
+	decisionLog          *decisionLog
+	jitter               *ttlJitter
+	retention            []retentionRule
+	snapshotFormat       SnapshotFormat
+	persistence          *persistence
+	expireGroups         map[string]*expireGroup
+	groupOf              map[string]string
+	aof                  *appendOnlyLog
+	epochs               map[string]prefixEpoch
+	epochLengths         []int
+	epochClock           uint64
+	lockTokens           uint64
+	codec                Codec
+	snapshotCodec        Codec
+	compaction           *compaction
+	compression          *compression
+	compressionThreshold float64
+	closed               int32
+	readOnly             int32
+	maxValueSize         int
+	computeTimeout       time.Duration
+	aead                 cipher.AEAD
+	optionErr            error
+	copier               func(any) any
+	externalPolicy       *externalPolicy
+	adminToken           string
+	maxListLength        int
+	listWaiters          map[string]*listWaiter
+	watchers             []*watcher
+	watchBuffer          int
+	subscribers          map[string][]*subscriber
+	notifications        notifications
+	evictionPolicy       EvictionPolicy
+	pinned               map[string]bool
+	priorities           map[Priority]int
+	memoryPressure       *memoryPressure
+	readMostly           *readMostly
+	clock                Clock
+	hotKeys              *hotKeyTracker
+	namespaces           *namespaces
+	cleanup              cleanupControl
+	expirationSamples    int
+	backing              backingStore
+	writeBehindAttempts  int
+	writeBehind          *writeBehind
+	loaders              loaders
+	maxStaleAge          time.Duration
+	breaker              *breaker
+	loadGuards           map[string]*loadGuard
+	sizer                Sizer
 }
 
 type storageData struct {
 	isPersistence  bool
+	held           bool
 	setTime        time.Time
 	expireDuration time.Duration
+	epoch          uint64
+	version        uint64
+	priority       Priority
+	bestEffort     bool
+	meta           *entryMeta
+	cost           int64
 	data           any
 }
 
-func NewCache() Cache {
-	return NewCacheWithCleanup(defaultCleanup)
+// entryMeta is shared by all versions of a key from its creation on, reads update it atomically.
+type entryMeta struct {
+	hits       int64
+	lastAccess int64
+	created    time.Time
 }
 
-func NewCacheWithCleanup(cleanupInterval time.Duration) Cache {
+func NewCache(options ...Option) LocalCache {
+	return NewCacheWithCleanup(defaultCleanup, options...)
+}
+
+func NewCacheWithCleanup(cleanupInterval time.Duration, options ...Option) LocalCache {
 	storage := storage{
-		stop:  make(chan struct{}),
-		data:  make(map[string]storageData),
-		hooks: make(map[OperationType][]HandlerFunc),
+		hasher:  NewMaphashHasher(),
+		clock:   SystemClock{},
+		cleanup: cleanupControl{intervals: make(chan time.Duration, 1)},
+		stop:    make(chan struct{}),
+		data:    make(map[string]storageData),
+		hookErr: logHookError,
+	}
+	for _, option := range options {
+		option(&storage)
+	}
+	if storage.optionErr != nil {
+		// files written under the failed option must not be read or overwritten without it
+		storage.persistence, storage.aof = nil, nil
+	}
+	storage.hooks.ErrorHandler = storage.hookErr
+	storage.keyLocks = newKeyLocks(defaultKeyLockStripes, storage.hasher)
+	if storage.hookWorkers != nil && backgroundTasks {
+		storage.hookPool = newHookPool(*storage.hookWorkers, storage.hasher)
 	}
+	if storage.evictionPolicy != nil && storage.capacity != nil {
+		storage.capacity.policy = storage.evictionPolicy
+	}
+	if storage.persistence != nil {
+		storage.restoreFromFile()
+	}
+	if storage.aof != nil {
+		storage.openAppendOnlyLog()
+	}
+	register(&storage)
 
-	storage.wg.Add(1)
-	go func(cleanupInterval time.Duration) {
-		defer storage.wg.Done()
-		storage.cleanupLoop(cleanupInterval)
-	}(cleanupInterval)
+	storage.startBackground(cleanupInterval)
 
 	return &storage
 }
 
+// Set persists the data, or expires it after the WithDefaultTTL duration when configured.
 func (s *storage) Set(key string, data any) {
-	s.data[key] = storageData{
+	s.store(key, s.newEntry(data, s.now()))
+}
+
+// SetPersistent stores the data without expiration regardless of WithDefaultTTL.
+func (s *storage) SetPersistent(key string, data any) {
+	s.store(key, storageData{
 		isPersistence:  true,
-		setTime:        time.Now(),
+		setTime:        s.now(),
 		expireDuration: 0,
 		data:           data,
-	}
-	s.processHooks(CreateOperation, key, data)
+	})
 }
 
 func (s *storage) SetEx(key string, data any, duration time.Duration) {
-	s.data[key] = storageData{
+	s.store(key, storageData{
 		isPersistence:  false,
-		setTime:        time.Now(),
-		expireDuration: duration,
+		setTime:        s.now(),
+		expireDuration: s.jitter.apply(duration),
 		data:           data,
-	}
-	s.processHooks(CreateOperation, key, data)
+	})
 }
 
 func (s *storage) Get(key string) (any, error) {
-	if value, ok := s.data[key]; ok {
-		if s.removeIfExpired(key, value) {
-			return nil, ErrCacheKeyNotFound
-		}
-		return value.data, nil
+	value, _, err := s.lookup(key)
+	if errors.Is(err, ErrCacheKeyNotFound) {
+		data, _, err := s.loadMissing(key, value)
+		return data, err
 	}
-	return nil, ErrCacheKeyNotFound
+	if err != nil {
+		return nil, err
+	}
+	return s.decodeValue(value.data)
 }
 
+// lookup finds the live entry of a read and accounts it, stale reports an entry on hold that
+// would have expired or been invalidated otherwise. An expired entry is returned along with
+// ErrCacheKeyNotFound for the stale fallback of loads.
+func (s *storage) lookup(key string) (value storageData, stale bool, err error) {
+	if err := s.readable(); err != nil {
+		return storageData{}, false, err
+	}
+	now := s.now()
+	s.observe(key, now)
+	s.hotKeys.observe(key, now)
+	var ok, expired, done bool
+	if s.readMostly != nil {
+		value, ok, expired, stale, done = s.readMostly.load(key, now)
+	}
+	if !done {
+		s.mu.RLock()
+		value, ok = s.data[key]
+		expired = ok && s.expiredLocked(key, value, now)
+		stale = ok && value.held && (value.deadlinePassed(now) || s.bumpedSinceLocked(key, value))
+		s.mu.RUnlock()
+	}
+	if !ok {
+		s.countersOf(key).miss()
+		s.autoCapacity.missed(key)
+		return storageData{}, false, ErrCacheKeyNotFound
+	}
+	if expired {
+		s.countersOf(key).miss()
+		s.expire(key)
+		return value, false, ErrCacheKeyNotFound
+	}
+	s.countersOf(key).hit()
+	if value.meta != nil {
+		atomic.AddInt64(&value.meta.hits, 1)
+		atomic.StoreInt64(&value.meta.lastAccess, now.UnixNano())
+	}
+	s.touch(key)
+	return value, stale, nil
+}
+
+// Delete removes the key unless it is retained by a hold or minimum lifetime.
 func (s *storage) Delete(key string) {
-	if data, ok := s.data[key]; ok {
-		delete(s.data, key)
-		s.processHooks(DeleteOperation, key, data.data)
+	now := s.now()
+	if s.writable() != nil || s.retained(key, now) || s.deleteThrough(key) != nil {
+		return
+	}
+	s.mu.Lock()
+	data, ok := s.data[key]
+	ok = ok && !s.protected(key, data, now)
+	deleted := removal{key: key, entry: data, reason: RemovalDeleted}
+	if ok {
+		s.deleteLocked(key)
+		s.queueRemovalsLocked(deleted)
+	}
+	s.mu.Unlock()
+	if ok {
+		s.finishRemovals(deleted)
+	}
+}
+
+// GetAndDelete removes the key and returns the data it held.
+// Retained keys are left in place and reported with ErrCacheKeyRetained.
+func (s *storage) GetAndDelete(key string) (any, error) {
+	if err := s.writable(); err != nil {
+		return nil, err
+	}
+	now := s.now()
+	if s.retained(key, now) {
+		return nil, ErrCacheKeyRetained
+	}
+	if err := s.deleteThrough(key); err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	value, ok := s.data[key]
+	if ok && s.protected(key, value, now) {
+		s.mu.Unlock()
+		return nil, ErrCacheKeyRetained
 	}
+	expired := ok && s.expiredLocked(key, value, now)
+	deleted := removal{key: key, entry: value, reason: RemovalDeleted}
+	if expired {
+		deleted.reason = RemovalExpired
+	}
+	if ok {
+		s.deleteLocked(key)
+		s.queueRemovalsLocked(deleted)
+	}
+	s.mu.Unlock()
+	if !ok {
+		s.countersOf(key).miss()
+		return nil, ErrCacheKeyNotFound
+	}
+	s.finishRemovals(deleted)
+	if expired {
+		s.countersOf(key).miss()
+		return nil, ErrCacheKeyNotFound
+	}
+	s.countersOf(key).hit()
+	return s.decodeValue(value.data)
 }
 
+// GetAndSet stores newData like Set and returns the data it replaced.
+// ErrCacheKeyNotFound is returned when there was nothing to replace, newData is stored anyway.
+func (s *storage) GetAndSet(key string, newData any) (any, error) {
+	now := s.now()
+	previous, ok, err := s.store(key, s.newEntry(newData, now))
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrCacheKeyNotFound
+	}
+	return s.decodeValue(previous.data)
+}
+
+// Increment adds delta to the integer stored under the key and returns the result.
+// Missing keys start at zero and are stored like Set, existing keys keep their expiration.
+func (s *storage) Increment(key string, delta int64) (int64, error) {
+	if err := s.writable(); err != nil {
+		s.countersOf(key).reject()
+		return 0, err
+	}
+	now := s.now()
+	s.mu.Lock()
+	value, existed := s.data[key]
+	existed = existed && !s.expiredLocked(key, value, now)
+	if !existed {
+		value = s.newEntry(int64(0), now)
+	}
+	data, err := s.decodeValue(value.data)
+	if err != nil {
+		s.mu.Unlock()
+		return 0, err
+	}
+	current, ok := toInt64(data)
+	if !ok {
+		s.mu.Unlock()
+		return 0, ErrCacheValueNotInteger
+	}
+	current += delta
+	if value.data, err = s.encodeValue(key, current); err != nil {
+		s.mu.Unlock()
+		return 0, err
+	}
+	_, _, removals, err := s.insertLocked(key, value)
+	if err == nil {
+		s.queueRemovalsLocked(removals...)
+		s.queueWriteLocked(key, current, existed)
+	}
+	entries := len(s.data)
+	s.mu.Unlock()
+	if err != nil {
+		s.countersOf(key).reject()
+		return 0, err
+	}
+	s.countersOf(key).set()
+	s.finishRemovals(removals...)
+	s.notifyWrite(key, current)
+	s.checkPressure(key, entries)
+	s.checkQuota(key)
+	return current, nil
+}
+
+// Decrement subtracts delta from the integer stored under the key, see Increment.
+func (s *storage) Decrement(key string, delta int64) (int64, error) {
+	return s.Increment(key, -delta)
+}
+
+// CreateKey joins args with ":" as they are, see KeyBuilder for keys from arguments that may contain it.
 func (s *storage) CreateKey(args ...string) string {
 	return s.CreateKeyWithDelimiter(defaultDelimiter, args...)
 }
@@ -115,52 +482,413 @@ func (s *storage) CreateKeyWithDelimiter(delimiter string, args ...string) strin
 	return strings.Join(args, delimiter)
 }
 
+// newEntry builds the entry written by Set, honoring WithDefaultTTL.
+func (s *storage) newEntry(data any, now time.Time) storageData {
+	if s.defaultTTL > 0 {
+		return storageData{
+			isPersistence:  false,
+			setTime:        now,
+			expireDuration: s.jitter.apply(s.defaultTTL),
+			data:           data,
+		}
+	}
+	return storageData{
+		isPersistence:  true,
+		setTime:        now,
+		expireDuration: 0,
+		data:           data,
+	}
+}
+
+// StopCleanup stops the background cleanup, the cache stays usable but expired entries are only
+// removed when accessed.
+//
+// Deprecated: use Close, which also releases hook workers.
 func (s *storage) StopCleanup() {
-	s.stop <- struct{}{}
+	_ = s.shutdown()
+}
+
+// Close stops the background goroutines, writes the final snapshot when WithPersistence is set,
+// syncs the append-only log and waits for queued hooks to finish. It is safe to call multiple times,
+// afterwards operations fail with ErrCacheClosed and writes without error result are ignored.
+func (s *storage) Close() error {
+	atomic.StoreInt32(&s.closed, 1)
+	s.wakeListWaiters()
+	return s.shutdown()
+}
+
+// SetReadOnly rejects writes and removals with ErrReadOnly, or ignores them for methods without
+// error result, until it is called with false. Expiration and eviction carry on.
+func (s *storage) SetReadOnly(readOnly bool) {
+	var flag int32
+	if readOnly {
+		flag = 1
+	}
+	atomic.StoreInt32(&s.readOnly, flag)
+}
+
+func (s *storage) readable() error {
+	if atomic.LoadInt32(&s.closed) == 1 {
+		return ErrCacheClosed
+	}
+	return nil
 }
 
-func (s *storage) SetHook(operationType OperationType, handlerFunctions ...HandlerFunc) {
-	if handlers, ok := s.hooks[operationType]; ok {
-		for _, handlerFunction := range handlerFunctions {
-			handlers = append(handlers, handlerFunction)
+func (s *storage) writable() error {
+	if err := s.readable(); err != nil {
+		return err
+	}
+	if s.optionErr != nil {
+		return s.optionErr
+	}
+	if atomic.LoadInt32(&s.readOnly) == 1 {
+		return ErrReadOnly
+	}
+	return nil
+}
+
+func (s *storage) shutdown() error {
+	var err error
+	s.closeOnce.Do(func() {
+		unregister(s)
+		close(s.stop)
+		if s.externalPolicy != nil {
+			s.stopExternalPolicy()
 		}
-	} else {
-		s.hooks[operationType] = handlerFunctions
-	}
-}
-
-func (s *storage) cleanupLoop(interval time.Duration) {
-	t := time.NewTicker(interval)
-	defer t.Stop()
-	for {
-		select {
-		case <-s.stop:
-			return
-		case <-t.C:
-			s.mu.Lock()
-			for key, sd := range s.data {
-				s.removeIfExpired(key, sd)
+		s.wg.Wait()
+		if s.writeBehind != nil {
+			s.drainWriteBehind()
+		}
+		if s.persistence != nil {
+			err = s.persist(true)
+		}
+		if s.aof != nil {
+			if aofErr := s.aof.close(); err == nil {
+				err = aofErr
 			}
-			s.mu.Unlock()
+			s.reportAOFFailures()
+		}
+		if s.hookPool != nil {
+			s.hookPool.stop()
+		}
+		s.closeWatchers()
+	})
+	return err
+}
+
+func (s *storage) Name() string {
+	return s.name
+}
+
+// SetHook appends handlers for the operation type, the returned id unregisters them with RemoveHook.
+func (s *storage) SetHook(operationType OperationType, handlerFunctions ...HandlerFunc) HookID {
+	return s.hooks.SetHook(operationType, handlerFunctions...)
+}
+
+func (s *storage) RemoveHook(operationType OperationType, id HookID) {
+	s.hooks.RemoveHook(operationType, id)
+}
+
+func (s *storage) ClearHooks(operationType OperationType) {
+	s.hooks.ClearHooks(operationType)
+}
+
+// store writes the entry through to the backing store and then to the cache, see storeLocal.
+// Writes the cache would reject do not reach the backing store.
+func (s *storage) store(key string, sd storageData) (storageData, bool, error) {
+	data := sd.data
+	err := s.writable()
+	if err == nil {
+		sd.data, err = s.encodeValue(key, data)
+	}
+	if err == nil {
+		err = s.admissible(key, sd)
+	}
+	if err == nil {
+		err = s.writeThrough(key, data)
+	}
+	if err != nil {
+		s.countersOf(key).reject()
+		return storageData{}, false, err
+	}
+	return s.storeEncoded(key, data, sd, nil)
+}
+
+// storeLocal writes the entry and notifies hooks, the entry it replaced is returned and
+// reported as live unless it had expired. Writes rejected by a prefix limit or a hold
+// are counted and reported as error.
+func (s *storage) storeLocal(key string, sd storageData) (storageData, bool, error) {
+	return s.storeLocalGuarded(key, sd, nil)
+}
+
+// storeLocalGuarded is storeLocal for loaded values, nothing is stored and errLoadSuperseded is
+// returned when the key was written while it was loaded, see beginLoad.
+func (s *storage) storeLocalGuarded(key string, sd storageData, guard *loadGuard) (storageData, bool, error) {
+	data := sd.data
+	err := s.writable()
+	if err == nil {
+		sd.data, err = s.encodeValue(key, data)
+	}
+	if err != nil {
+		s.countersOf(key).reject()
+		return storageData{}, false, err
+	}
+	return s.storeEncoded(key, data, sd, guard)
+}
+
+// storeEncoded is storeLocalGuarded for an entry holding the encoded data.
+func (s *storage) storeEncoded(key string, data any, sd storageData, guard *loadGuard) (storageData, bool, error) {
+	s.loaders.forgetMissing(key)
+	s.loaders.forgetLoad(key)
+	s.mu.Lock()
+	if guard != nil && guard.written {
+		s.mu.Unlock()
+		return storageData{}, false, errLoadSuperseded
+	}
+	previous, replaced, removals, err := s.insertLocked(key, sd)
+	expired := replaced && s.expiredLocked(key, previous, sd.setTime)
+	if err == nil {
+		if expired {
+			removals = append(removals, removal{key: key, entry: previous, reason: RemovalExpired})
+		} else if replaced {
+			removals = append(removals, removal{key: key, entry: previous, reason: RemovalReplaced})
 		}
+		s.queueRemovalsLocked(removals...)
+		s.queueWriteLocked(key, data, replaced && !expired)
+	}
+	entries := len(s.data)
+	s.mu.Unlock()
+	if err != nil {
+		s.countersOf(key).reject()
+		s.checkQuota(key)
+		return storageData{}, false, err
+	}
+	s.observe(key, sd.setTime)
+	s.countersOf(key).set()
+	s.finishRemovals(removals...)
+	s.notifyWrite(key, data)
+	s.checkPressure(key, entries)
+	s.checkQuota(key)
+	return previous, replaced && !expired, nil
+}
+
+// insertLocked writes the entry and evicts what no longer fits, the caller holds the write lock.
+// Nothing is written when an error is returned.
+func (s *storage) insertLocked(key string, sd storageData) (storageData, bool, []removal, error) {
+	if previous, ok := s.data[key]; ok && previous.held {
+		return storageData{}, false, nil, ErrCacheKeyRetained
+	}
+	if !s.admitPolicyLocked(key) {
+		return storageData{}, false, nil, ErrAdmissionDenied
+	}
+	if !s.admitNamespaceLocked(key, sd) {
+		return storageData{}, false, nil, ErrCapacityExceeded
+	}
+	removals, admitted := s.admitPrefixesLocked(key)
+	if !admitted {
+		return storageData{}, false, nil, ErrCapacityExceeded
+	}
+	previous, replaced := s.data[key]
+	sd.epoch = s.epochClock
+	sd.version = previous.version + 1
+	sd.meta = previous.meta
+	if sd.meta == nil {
+		sd.meta = &entryMeta{created: sd.setTime}
+	}
+	s.putLocked(key, s.applyRetention(key, s.applyExpireGroup(key, sd)))
+	s.track(key)
+	removals = append(removals, s.enforceCostLocked(key)...)
+	return previous, replaced, append(removals, s.evictLocked(key)...), nil
+}
+
+// admissible checks the entry against the holds and admission rules of insertLocked before it is
+// written through, without evicting anything. A write racing with it can still be rejected by
+// insertLocked.
+func (s *storage) admissible(key string, sd storageData) error {
+	if s.backing.store == nil {
+		return nil
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if previous, ok := s.data[key]; ok && previous.held {
+		return ErrCacheKeyRetained
+	}
+	if !s.admitPolicyLocked(key) {
+		return ErrAdmissionDenied
+	}
+	if ns := s.namespaceOf(key); ns != nil && ns.limit != nil && ns.maxCost > 0 && s.namespaceCost(key, sd.data) > ns.maxCost {
+		return ErrCapacityExceeded
 	}
+	if s.prefixRejectsLocked(key) {
+		return ErrCapacityExceeded
+	}
+	return nil
+}
+
+// retained reports whether Delete leaves the key in place, checked before the delete is written through.
+func (s *storage) retained(key string, now time.Time) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	sd, ok := s.data[key]
+	return ok && s.protected(key, sd, now)
 }
 
-func (s *storage) removeIfExpired(key string, sd storageData) bool {
+// deleteLocked drops the key from the data and all bookkeeping, the caller holds the write lock.
+func (s *storage) deleteLocked(key string) {
+	s.markWrittenLocked(key)
+	if sd, ok := s.data[key]; ok {
+		s.countPriorityLocked(sd.priority, -1)
+		s.countNamespaceLocked(key, sd, -1)
+	}
+	delete(s.data, key)
+	s.readMostly.delete(key)
+	s.untrack(key)
+	if s.aof != nil {
+		s.aof.append(AOFRecord{Op: aofOpDelete, Time: s.now(), Key: key})
+	}
+}
+
+// track records a written key with the eviction policy, the caller holds the write lock.
+func (s *storage) track(key string) {
+	if s.limited() {
+		s.capacity.policy.OnSet(key)
+	}
+	for _, limit := range s.prefixLimits {
+		if strings.HasPrefix(key, limit.prefix) {
+			limit.keys.add(key)
+		}
+	}
+}
+
+// touch records a read of the key with the eviction policy.
+func (s *storage) touch(key string) {
+	if s.limited() {
+		s.capacity.policy.OnGet(key)
+	}
+	for _, limit := range s.prefixLimits {
+		if strings.HasPrefix(key, limit.prefix) {
+			limit.keys.touch(key)
+		}
+	}
+}
+
+func (s *storage) untrack(key string) {
+	if s.limited() {
+		s.capacity.policy.OnRemove(key)
+	}
+	for _, limit := range s.prefixLimits {
+		if strings.HasPrefix(key, limit.prefix) {
+			limit.keys.remove(key)
+		}
+	}
+}
+
+// Sweep removes expired entries right away instead of waiting for the cleanup interval.
+// In builds without background tasks it also performs the periodic work of the options.
+func (s *storage) Sweep() {
+	s.removeExpired()
+	s.maintain()
+}
+
+func (s *storage) removeExpired() {
+	now := s.now()
+	s.loaders.dropExpiredMissing(now)
+	s.loaders.dropPassedLoads(now)
+	var removals []removal
+	keepStale := s.breaker.keepsExpired()
+	s.mu.Lock()
+	for key, sd := range s.data {
+		if s.expiredLocked(key, sd, now) && !(keepStale && s.retainsStaleLocked(key, sd, now)) {
+			s.deleteLocked(key)
+			removals = append(removals, removal{key: key, entry: sd, reason: RemovalExpired})
+		}
+	}
+	s.dropPassedGroupsLocked(now)
+	s.queueRemovalsLocked(removals...)
+	s.mu.Unlock()
+	s.finishRemovals(removals...)
+}
+
+// expire removes the key if it is still expired once the write lock is held. With a loader
+// circuit breaker entries kept for the stale fallback are left to the cleanup.
+func (s *storage) expire(key string) {
+	now := s.now()
+	s.mu.Lock()
+	sd, ok := s.data[key]
+	ok = ok && s.expiredLocked(key, sd, now) && !s.retainsStaleLocked(key, sd, now)
+	expired := removal{key: key, entry: sd, reason: RemovalExpired}
+	if ok {
+		s.deleteLocked(key)
+		s.queueRemovalsLocked(expired)
+	}
+	s.mu.Unlock()
+	if ok {
+		s.finishRemovals(expired)
+	}
+}
+
+// remainingTTL reports the time left for an expiring key, false for missing or persistent keys.
+func (s *storage) remainingTTL(key string) (time.Duration, bool) {
+	s.mu.RLock()
+	sd, ok := s.data[key]
+	s.mu.RUnlock()
+	if !ok || sd.isPersistence {
+		return 0, false
+	}
+	return sd.setTime.Add(sd.expireDuration).Sub(s.now()), true
+}
+
+func (sd storageData) isExpired(now time.Time) bool {
+	return !sd.held && sd.deadlinePassed(now)
+}
+
+func (sd storageData) deadlinePassed(now time.Time) bool {
 	if sd.isPersistence {
 		return false
 	}
-	if sd.setTime.Add(sd.expireDuration).Unix() <= time.Now().Unix() {
-		s.Delete(key)
-		return true
+	return sd.setTime.Add(sd.expireDuration).Unix() <= now.Unix()
+}
+
+func toInt64(data any) (int64, bool) {
+	switch v := data.(type) {
+	case int64:
+		return v, true
+	case int:
+		return int64(v), true
+	case int32:
+		return int64(v), true
+	case int16:
+		return int64(v), true
+	case int8:
+		return int64(v), true
+	case uint32:
+		return int64(v), true
+	case uint16:
+		return int64(v), true
+	case uint8:
+		return int64(v), true
 	}
-	return false
+	return 0, false
 }
 
 func (s *storage) processHooks(operationType OperationType, key string, data any) {
-	if handlerFunctions, ok := s.hooks[operationType]; ok {
-		for _, handlerFunction := range handlerFunctions {
-			handlerFunction(key, data)
-		}
+	s.dispatchHooks(operationType, key, func() {
+		s.runHooks(operationType, key, data)
+	})
+}
+
+// dispatchHooks runs hook work inline or on the hook workers when configured, work dropped at a
+// full queue is reported to the HookErrorHandler.
+func (s *storage) dispatchHooks(operationType OperationType, key string, task func()) {
+	if s.hookPool == nil {
+		task()
+		return
+	}
+	if !s.hookPool.dispatch(key, task) {
+		s.hookErr(operationType, key, ErrHookQueueFull)
 	}
 }
+
+func (s *storage) runHooks(operationType OperationType, key string, data any) {
+	s.hooks.Run(operationType, key, data)
+}
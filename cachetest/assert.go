@@ -0,0 +1,69 @@
+package cachetest
+
+import (
+	"reflect"
+	"testing"
+)
+
+// AssertSet fails the test unless key was written by Set, SetPersistent, SetEx or GetAndSet
+// with a value deeply equal to value.
+func AssertSet(t testing.TB, r *Recorder, key string, value any) {
+	t.Helper()
+	for _, operation := range r.OperationsOn(key) {
+		if isWrite(operation.Op) && reflect.DeepEqual(operation.Value, value) {
+			return
+		}
+	}
+	t.Errorf("cachetest: %q was not set to %#v, operations: %v", key, value, r.OperationsOn(key))
+}
+
+// AssertNotSet fails the test if key was written.
+func AssertNotSet(t testing.TB, r *Recorder, key string) {
+	t.Helper()
+	for _, operation := range r.OperationsOn(key) {
+		if isWrite(operation.Op) {
+			t.Errorf("cachetest: %q was set to %#v", key, operation.Value)
+			return
+		}
+	}
+}
+
+// AssertHit fails the test unless a Get of key found it.
+func AssertHit(t testing.TB, r *Recorder, key string) {
+	t.Helper()
+	if !r.read(key, true) {
+		t.Errorf("cachetest: no Get hit on %q, operations: %v", key, r.OperationsOn(key))
+	}
+}
+
+// AssertMiss fails the test unless a Get of key missed it.
+func AssertMiss(t testing.TB, r *Recorder, key string) {
+	t.Helper()
+	if !r.read(key, false) {
+		t.Errorf("cachetest: no Get miss on %q, operations: %v", key, r.OperationsOn(key))
+	}
+}
+
+// AssertDeleted fails the test unless key was deleted by Delete or GetAndDelete.
+func AssertDeleted(t testing.TB, r *Recorder, key string) {
+	t.Helper()
+	for _, operation := range r.OperationsOn(key) {
+		if operation.Op == OpDelete || operation.Op == OpGetAndDelete {
+			return
+		}
+	}
+	t.Errorf("cachetest: %q was not deleted, operations: %v", key, r.OperationsOn(key))
+}
+
+func (r *Recorder) read(key string, hit bool) bool {
+	for _, operation := range r.OperationsOn(key) {
+		if operation.Op == OpGet && operation.Hit == hit {
+			return true
+		}
+	}
+	return false
+}
+
+func isWrite(op Op) bool {
+	return op == OpSet || op == OpSetPersistent || op == OpSetEx || op == OpGetAndSet
+}
@@ -0,0 +1,100 @@
+// Package cachetest helps unit testing code that uses addcache: Recorder is an in-memory Cache
+// recording every operation for the Assert helpers, FakeClock lets tests expire entries without
+// waiting for real time to pass.
+package cachetest
+
+import (
+	"sync"
+	"time"
+
+	"github.com/addit-digital/addcache"
+)
+
+// FakeClock is an addcache.Clock that only moves when it is advanced. Pass it to
+// addcache.WithClock, entries expire as soon as Advance moves past their TTL.
+type FakeClock struct {
+	mu      sync.Mutex
+	now     time.Time
+	tickers []*fakeTicker
+}
+
+// NewFakeClock returns a clock standing at now.
+func NewFakeClock(now time.Time) *FakeClock {
+	return &FakeClock{now: now}
+}
+
+func (c *FakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *FakeClock) NewTicker(d time.Duration) addcache.Ticker {
+	if d <= 0 {
+		panic("cachetest: non-positive interval for NewTicker")
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	t := &fakeTicker{
+		clock:    c,
+		interval: d,
+		next:     c.now.Add(d),
+		c:        make(chan time.Time),
+		stop:     make(chan struct{}),
+	}
+	c.tickers = append(c.tickers, t)
+	return t
+}
+
+// Advance moves the clock forward by d and fires the tickers that became due, once each like a
+// time.Ticker dropping ticks for slow receivers. It returns once every due tick was received, so
+// a second Advance also waits for the background work of the first to finish.
+func (c *FakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	c.now = c.now.Add(d)
+	now := c.now
+	var due []*fakeTicker
+	for _, t := range c.tickers {
+		if !t.next.After(now) {
+			due = append(due, t)
+			for !t.next.After(now) {
+				t.next = t.next.Add(t.interval)
+			}
+		}
+	}
+	c.mu.Unlock()
+	for _, t := range due {
+		select {
+		case t.c <- now:
+		case <-t.stop:
+		}
+	}
+}
+
+type fakeTicker struct {
+	clock    *FakeClock
+	interval time.Duration
+	next     time.Time
+	c        chan time.Time
+	stop     chan struct{}
+	once     sync.Once
+}
+
+func (t *fakeTicker) C() <-chan time.Time {
+	return t.c
+}
+
+func (t *fakeTicker) Stop() {
+	t.once.Do(func() {
+		close(t.stop)
+		c := t.clock
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		for i, ticker := range c.tickers {
+			if ticker == t {
+				c.tickers = append(c.tickers[:i], c.tickers[i+1:]...)
+				break
+			}
+		}
+	})
+}
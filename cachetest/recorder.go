@@ -0,0 +1,163 @@
+package cachetest
+
+import (
+	"sync"
+	"time"
+
+	"github.com/addit-digital/addcache"
+)
+
+// Op names the Cache method an Operation recorded.
+type Op string
+
+const (
+	OpSet           Op = "Set"
+	OpSetPersistent Op = "SetPersistent"
+	OpSetEx         Op = "SetEx"
+	OpGet           Op = "Get"
+	OpDelete        Op = "Delete"
+	OpGetAndDelete  Op = "GetAndDelete"
+	OpGetAndSet     Op = "GetAndSet"
+	OpIncrement     Op = "Increment"
+	OpDecrement     Op = "Decrement"
+)
+
+// Operation is one recorded call. Value is the value written, or the value read on a hit, and
+// Hit tells whether a read found the key.
+type Operation struct {
+	Op    Op
+	Key   string
+	Value any
+	TTL   time.Duration
+	Hit   bool
+	Err   error
+}
+
+// Recorder is an in-memory addcache.Cache recording every keyed operation. It is backed by a
+// real cache running on Clock, so expirations happen when the test advances the clock.
+type Recorder struct {
+	cache addcache.LocalCache
+	Clock *FakeClock
+
+	mu         sync.Mutex
+	operations []Operation
+}
+
+var _ addcache.Cache = (*Recorder)(nil)
+
+// NewRecorder returns a Recorder whose clock starts at the current time, options configure the
+// backing cache.
+func NewRecorder(options ...addcache.Option) *Recorder {
+	clock := NewFakeClock(time.Now())
+	options = append([]addcache.Option{addcache.WithClock(clock)}, options...)
+	return &Recorder{cache: addcache.NewCache(options...), Clock: clock}
+}
+
+// Operations returns the operations recorded so far in call order.
+func (r *Recorder) Operations() []Operation {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]Operation(nil), r.operations...)
+}
+
+// OperationsOn returns the operations recorded on key in call order.
+func (r *Recorder) OperationsOn(key string) []Operation {
+	var operations []Operation
+	for _, operation := range r.Operations() {
+		if operation.Key == key {
+			operations = append(operations, operation)
+		}
+	}
+	return operations
+}
+
+// Reset forgets the recorded operations, the cache contents stay.
+func (r *Recorder) Reset() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.operations = nil
+}
+
+func (r *Recorder) record(operation Operation) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.operations = append(r.operations, operation)
+}
+
+func (r *Recorder) Set(key string, data any) {
+	r.cache.Set(key, data)
+	r.record(Operation{Op: OpSet, Key: key, Value: data})
+}
+
+func (r *Recorder) SetPersistent(key string, data any) {
+	r.cache.SetPersistent(key, data)
+	r.record(Operation{Op: OpSetPersistent, Key: key, Value: data})
+}
+
+func (r *Recorder) SetEx(key string, data any, duration time.Duration) {
+	r.cache.SetEx(key, data, duration)
+	r.record(Operation{Op: OpSetEx, Key: key, Value: data, TTL: duration})
+}
+
+func (r *Recorder) Get(key string) (any, error) {
+	data, err := r.cache.Get(key)
+	r.record(Operation{Op: OpGet, Key: key, Value: data, Hit: err == nil, Err: err})
+	return data, err
+}
+
+func (r *Recorder) Delete(key string) {
+	r.cache.Delete(key)
+	r.record(Operation{Op: OpDelete, Key: key})
+}
+
+func (r *Recorder) GetAndDelete(key string) (any, error) {
+	data, err := r.cache.GetAndDelete(key)
+	r.record(Operation{Op: OpGetAndDelete, Key: key, Value: data, Hit: err == nil, Err: err})
+	return data, err
+}
+
+func (r *Recorder) GetAndSet(key string, newData any) (any, error) {
+	data, err := r.cache.GetAndSet(key, newData)
+	r.record(Operation{Op: OpGetAndSet, Key: key, Value: newData, Hit: err == nil, Err: err})
+	return data, err
+}
+
+func (r *Recorder) Increment(key string, delta int64) (int64, error) {
+	value, err := r.cache.Increment(key, delta)
+	r.record(Operation{Op: OpIncrement, Key: key, Value: value, Err: err})
+	return value, err
+}
+
+func (r *Recorder) Decrement(key string, delta int64) (int64, error) {
+	value, err := r.cache.Decrement(key, delta)
+	r.record(Operation{Op: OpDecrement, Key: key, Value: value, Err: err})
+	return value, err
+}
+
+func (r *Recorder) CreateKey(args ...string) string {
+	return r.cache.CreateKey(args...)
+}
+
+func (r *Recorder) CreateKeyWithDelimiter(delimiter string, args ...string) string {
+	return r.cache.CreateKeyWithDelimiter(delimiter, args...)
+}
+
+func (r *Recorder) StopCleanup() {
+	r.cache.StopCleanup()
+}
+
+func (r *Recorder) Close() error {
+	return r.cache.Close()
+}
+
+func (r *Recorder) SetHook(operationType addcache.OperationType, handlerFunctions ...addcache.HandlerFunc) addcache.HookID {
+	return r.cache.SetHook(operationType, handlerFunctions...)
+}
+
+func (r *Recorder) RemoveHook(operationType addcache.OperationType, id addcache.HookID) {
+	r.cache.RemoveHook(operationType, id)
+}
+
+func (r *Recorder) ClearHooks(operationType addcache.OperationType) {
+	r.cache.ClearHooks(operationType)
+}
@@ -0,0 +1,120 @@
+package addcache
+
+import (
+	"sort"
+	"sync"
+	"sync/atomic"
+)
+
+const PressureOperation OperationType = "Pressure"
+
+var defaultPressureThresholds = []float64{0.8, 0.9, 0.95}
+
+// PressureEvent is passed as data to Pressure hooks when usage crosses a threshold upwards.
+type PressureEvent struct {
+	Threshold float64
+	Entries   int
+	Capacity  int
+}
+
+// capacityLimit keeps keys in the order of its eviction policy to pick victims.
+type capacityLimit struct {
+	maxEntries int64
+	policy     EvictionPolicy
+
+	mu            sync.Mutex
+	thresholds    []float64
+	pressureLevel int
+}
+
+// WithMaxEntries bounds the number of entries, least recently used entries are evicted beyond it
+// unless WithEvictionPolicy selects another policy.
+// Pressure hooks fire at 80, 90 and 95 percent of the capacity unless WithPressureThresholds is given.
+func WithMaxEntries(maxEntries int) Option {
+	return func(s *storage) {
+		if maxEntries <= 0 {
+			return
+		}
+		thresholds := defaultPressureThresholds
+		if s.capacity != nil {
+			thresholds = s.capacity.thresholds
+		}
+		s.capacity = &capacityLimit{
+			maxEntries: int64(maxEntries),
+			policy:     NewLRUPolicy(),
+			thresholds: thresholds,
+		}
+	}
+}
+
+// WithPressureThresholds sets the capacity fractions at which Pressure hooks fire, see WithMaxEntries.
+func WithPressureThresholds(thresholds ...float64) Option {
+	return func(s *storage) {
+		sorted := append([]float64(nil), thresholds...)
+		sort.Float64s(sorted)
+		if s.capacity == nil {
+			s.capacity = &capacityLimit{thresholds: sorted}
+			return
+		}
+		s.capacity.thresholds = sorted
+	}
+}
+
+func (s *storage) limited() bool {
+	return s.capacity != nil && s.capacity.max() > 0
+}
+
+// max is read atomically because the capacity controller may adjust it.
+func (c *capacityLimit) max() int {
+	return int(atomic.LoadInt64(&c.maxEntries))
+}
+
+// evictLocked drops the victims of the eviction policy above the capacity, the caller holds the write lock.
+// Lower priorities go first and the key just written is only evicted when nothing else can be.
+func (s *storage) evictLocked(written string) []removal {
+	if !s.limited() {
+		return nil
+	}
+	var removals []removal
+	for len(s.data) > s.capacity.max() {
+		key, ok := s.priorityVictimLocked(func(key string) bool {
+			return key != written && s.evictable(key)
+		})
+		if !ok {
+			key, ok = s.priorityVictimLocked(s.evictable)
+		}
+		if !ok {
+			break
+		}
+		sd := s.data[key]
+		s.deleteLocked(key)
+		s.autoCapacity.evicted(key, s.capacity.max())
+		removals = append(removals, removal{key: key, entry: sd, reason: RemovalEvicted})
+	}
+	return removals
+}
+
+// checkPressure fires Pressure hooks for every threshold crossed since the last write.
+func (s *storage) checkPressure(key string, entries int) {
+	if !s.limited() {
+		return
+	}
+	c := s.capacity
+	maxEntries := c.max()
+	usage := float64(entries) / float64(maxEntries)
+	level := sort.Search(len(c.thresholds), func(i int) bool {
+		return c.thresholds[i] > usage
+	})
+
+	c.mu.Lock()
+	previous := c.pressureLevel
+	c.pressureLevel = level
+	c.mu.Unlock()
+	for i := previous; i < level; i++ {
+		s.processHooks(PressureOperation, key, PressureEvent{
+			Threshold: c.thresholds[i],
+			Entries:   entries,
+			Capacity:  maxEntries,
+		})
+	}
+}
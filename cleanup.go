@@ -0,0 +1,59 @@
+package addcache
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// cleanupControl passes runtime changes to the cleanup loop, which owns its ticker.
+type cleanupControl struct {
+	paused    int32
+	mu        sync.Mutex
+	intervals chan time.Duration
+}
+
+// SetCleanupInterval changes the interval of the background cleanup, the cleanup loop replaces its
+// ticker before the next pass. Non-positive intervals are ignored.
+func (s *storage) SetCleanupInterval(interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+	c := &s.cleanup
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	// only the latest interval matters when the loop did not pick up the previous one yet
+	select {
+	case <-c.intervals:
+	default:
+	}
+	c.intervals <- interval
+}
+
+// PauseCleanup skips the background cleanup passes, e.g. during traffic spikes or bulk loads,
+// until ResumeCleanup. Expired entries still read as missing and Sweep still removes them.
+func (s *storage) PauseCleanup() {
+	atomic.StoreInt32(&s.cleanup.paused, 1)
+}
+
+// ResumeCleanup continues the background cleanup paused by PauseCleanup with the next tick.
+func (s *storage) ResumeCleanup() {
+	atomic.StoreInt32(&s.cleanup.paused, 0)
+}
+
+func (s *storage) cleanupLoop(t Ticker) {
+	defer func() { t.Stop() }()
+	for {
+		select {
+		case <-s.stop:
+			return
+		case interval := <-s.cleanup.intervals:
+			t.Stop()
+			t = s.clock.NewTicker(interval)
+		case <-t.C():
+			if atomic.LoadInt32(&s.cleanup.paused) == 0 {
+				s.cleanupPass()
+			}
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package addcache
+
+import "time"
+
+// Clock is the source of time for expirations and the periodic background work, tests replace
+// it with a clock they advance by hand such as cachetest.FakeClock.
+type Clock interface {
+	Now() time.Time
+	NewTicker(d time.Duration) Ticker
+}
+
+// Ticker delivers the ticks of a Clock like time.Ticker.
+type Ticker interface {
+	C() <-chan time.Time
+	Stop()
+}
+
+// SystemClock is the wall clock of the time package, it is the default.
+type SystemClock struct{}
+
+func (SystemClock) Now() time.Time {
+	return time.Now()
+}
+
+func (SystemClock) NewTicker(d time.Duration) Ticker {
+	return systemTicker{time.NewTicker(d)}
+}
+
+type systemTicker struct {
+	ticker *time.Ticker
+}
+
+func (t systemTicker) C() <-chan time.Time {
+	return t.ticker.C
+}
+
+func (t systemTicker) Stop() {
+	t.ticker.Stop()
+}
+
+// WithClock replaces the default SystemClock. Timeouts of blocking calls such as BLPop and
+// WithComputeTimeout keep running on the wall clock.
+func WithClock(clock Clock) Option {
+	return func(s *storage) {
+		if clock != nil {
+			s.clock = clock
+		}
+	}
+}
+
+func (s *storage) now() time.Time {
+	return s.clock.Now()
+}
@@ -0,0 +1,210 @@
+// Package cluster keeps the local caches of several processes coherent: every write and
+// removal is broadcast as an invalidation over a Transport and the other processes drop their
+// copy of the key. Transports for Redis pub/sub and NATS live in the redisadapter and
+// natstransport modules.
+package cluster
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/addit-digital/addcache"
+)
+
+// Operations carried by an Invalidation.
+const (
+	OperationSet    = "set"
+	OperationDelete = "del"
+)
+
+// Invalidation is the message broadcast for a changed key.
+type Invalidation struct {
+	Origin    string `json:"origin"`
+	Operation string `json:"op"`
+	Key       string `json:"key"`
+}
+
+// Transport delivers published payloads to every subscribed process, including the publisher.
+type Transport interface {
+	Publish(payload []byte) error
+	// Subscribe calls handler for every payload until the returned function is called.
+	Subscribe(handler func(payload []byte)) (cancel func(), err error)
+}
+
+// NamespacedTransport is implemented by transports routing invalidations by key namespace,
+// the part of the key before the first ":", or "" for keys without one. Cache publishes through
+// PublishNamespace when the transport implements it.
+type NamespacedTransport interface {
+	Transport
+	PublishNamespace(namespace string, payload []byte) error
+}
+
+// ErrorHandler receives failed publications and undecodable messages.
+type ErrorHandler func(err error)
+
+// Option configures New.
+type Option func(c *Cache)
+
+// WithNodeID names the process in its invalidations, a random id is used by default.
+// Messages carrying the own id are ignored.
+func WithNodeID(id string) Option {
+	return func(c *Cache) {
+		c.nodeID = id
+	}
+}
+
+// WithErrorHandler replaces the default handler, which logs errors.
+func WithErrorHandler(handler ErrorHandler) Option {
+	return func(c *Cache) {
+		if handler != nil {
+			c.onError = handler
+		}
+	}
+}
+
+// Cache is an addcache.Cache broadcasting invalidations for its writes and removals and
+// dropping keys invalidated by other processes. Methods not overridden act on the local cache only.
+type Cache struct {
+	addcache.Cache
+	transport   Transport
+	nodeID      string
+	onError     ErrorHandler
+	unsubscribe func()
+	closeOnce   sync.Once
+}
+
+var _ addcache.Cache = (*Cache)(nil)
+
+// New subscribes to transport and wraps cache, Close closes both the subscription and the cache.
+func New(cache addcache.Cache, transport Transport, options ...Option) (*Cache, error) {
+	c := &Cache{
+		Cache:     cache,
+		transport: transport,
+		nodeID:    randomID(),
+		onError:   logError,
+	}
+	for _, option := range options {
+		option(c)
+	}
+	unsubscribe, err := transport.Subscribe(c.receive)
+	if err != nil {
+		return nil, err
+	}
+	c.unsubscribe = unsubscribe
+	return c, nil
+}
+
+func randomID() string {
+	var id [8]byte
+	if _, err := rand.Read(id[:]); err != nil {
+		return time.Now().Format(time.RFC3339Nano)
+	}
+	return hex.EncodeToString(id[:])
+}
+
+func logError(err error) {
+	log.Printf("addcache/cluster: %v", err)
+}
+
+// NodeID returns the id the process publishes its invalidations under.
+func (c *Cache) NodeID() string {
+	return c.nodeID
+}
+
+func (c *Cache) Set(key string, data any) {
+	c.Cache.Set(key, data)
+	c.publish(OperationSet, key)
+}
+
+func (c *Cache) SetPersistent(key string, data any) {
+	c.Cache.SetPersistent(key, data)
+	c.publish(OperationSet, key)
+}
+
+func (c *Cache) SetEx(key string, data any, duration time.Duration) {
+	c.Cache.SetEx(key, data, duration)
+	c.publish(OperationSet, key)
+}
+
+func (c *Cache) Delete(key string) {
+	c.Cache.Delete(key)
+	c.publish(OperationDelete, key)
+}
+
+func (c *Cache) GetAndDelete(key string) (any, error) {
+	data, err := c.Cache.GetAndDelete(key)
+	if err == nil {
+		c.publish(OperationDelete, key)
+	}
+	return data, err
+}
+
+func (c *Cache) GetAndSet(key string, newData any) (any, error) {
+	data, err := c.Cache.GetAndSet(key, newData)
+	if err == nil {
+		c.publish(OperationSet, key)
+	}
+	return data, err
+}
+
+func (c *Cache) Increment(key string, delta int64) (int64, error) {
+	value, err := c.Cache.Increment(key, delta)
+	if err == nil {
+		c.publish(OperationSet, key)
+	}
+	return value, err
+}
+
+func (c *Cache) Decrement(key string, delta int64) (int64, error) {
+	value, err := c.Cache.Decrement(key, delta)
+	if err == nil {
+		c.publish(OperationSet, key)
+	}
+	return value, err
+}
+
+// Close stops receiving invalidations and closes the local cache.
+func (c *Cache) Close() error {
+	c.closeOnce.Do(c.unsubscribe)
+	return c.Cache.Close()
+}
+
+func (c *Cache) publish(operation string, key string) {
+	payload, err := json.Marshal(Invalidation{Origin: c.nodeID, Operation: operation, Key: key})
+	if err == nil {
+		if namespaced, ok := c.transport.(NamespacedTransport); ok {
+			err = namespaced.PublishNamespace(Namespace(key), payload)
+		} else {
+			err = c.transport.Publish(payload)
+		}
+	}
+	if err != nil {
+		c.onError(err)
+	}
+}
+
+// Namespace returns the namespace of key as passed to NamespacedTransport.
+func Namespace(key string) string {
+	if i := strings.Index(key, ":"); i >= 0 {
+		return key[:i]
+	}
+	return ""
+}
+
+// receive drops the local copy of keys invalidated by other processes.
+func (c *Cache) receive(payload []byte) {
+	var invalidation Invalidation
+	if err := json.Unmarshal(payload, &invalidation); err != nil {
+		c.onError(err)
+		return
+	}
+	if invalidation.Origin == c.nodeID {
+		return
+	}
+	c.Cache.Delete(invalidation.Key)
+}
@@ -0,0 +1,192 @@
+package addcache
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"reflect"
+)
+
+// Codec turns values into bytes and back, it is used by snapshots and the serialization mode.
+type Codec interface {
+	Marshal(v any) ([]byte, error)
+	Unmarshal(data []byte, v any) error
+}
+
+// JSONCodec encodes with encoding/json.
+type JSONCodec struct{}
+
+func (JSONCodec) Marshal(v any) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (JSONCodec) Unmarshal(data []byte, v any) error {
+	return json.Unmarshal(data, v)
+}
+
+// GobCodec encodes with encoding/gob, values held in interfaces must be registered with gob.Register.
+type GobCodec struct{}
+
+func (GobCodec) Marshal(v any) ([]byte, error) {
+	var buffer bytes.Buffer
+	if err := gob.NewEncoder(&buffer).Encode(v); err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
+
+func (GobCodec) Unmarshal(data []byte, v any) error {
+	return gob.NewDecoder(bytes.NewReader(data)).Decode(v)
+}
+
+// encodedValue is a value stored in its serialized or compressed form.
+type encodedValue struct {
+	kind       encodedKind
+	typ        reflect.Type
+	raw        []byte
+	compressed bool
+	encrypted  bool
+}
+
+type encodedKind uint8
+
+const (
+	encodedCodec encodedKind = iota
+	encodedBytes
+	encodedString
+)
+
+// WithSerialization stores values marshaled with codec, so callers mutating a value after Set
+// or after Get never change the cached copy. Get decodes into a new value of the stored type.
+// Values the codec rejects are not stored and counted as rejections.
+func WithSerialization(codec Codec) Option {
+	return func(s *storage) {
+		s.codec = codec
+	}
+}
+
+// encodeValue converts data stored under key into its stored form and enforces WithMaxValueSize.
+func (s *storage) encodeValue(key string, data any) (any, error) {
+	encoded, err := s.encode(key, data)
+	if err == nil && s.maxValueSize > 0 && storedSize(encoded) > s.maxValueSize {
+		return nil, ErrValueTooLarge
+	}
+	return encoded, err
+}
+
+func (s *storage) encode(key string, data any) (any, error) {
+	var encoded encodedValue
+	switch v := data.(type) {
+	case []byte:
+		if s.compressible(len(v)) || s.aead != nil {
+			encoded = encodedValue{kind: encodedBytes, raw: v}
+		}
+	case string:
+		if s.compressible(len(v)) || s.aead != nil {
+			encoded = encodedValue{kind: encodedString, raw: []byte(v)}
+		}
+	}
+	if encoded.raw == nil {
+		if s.codec == nil || data == nil {
+			return s.copyValue(data), nil
+		}
+		raw, err := s.codec.Marshal(data)
+		if err != nil {
+			return nil, err
+		}
+		encoded = encodedValue{kind: encodedCodec, typ: reflect.TypeOf(data), raw: raw}
+	}
+	var err error
+	if s.compressible(len(encoded.raw)) {
+		if encoded, err = s.compress(key, encoded); err != nil {
+			return nil, err
+		}
+	}
+	if s.aead != nil {
+		if encoded, err = s.encrypt(encoded); err != nil {
+			return nil, err
+		}
+	}
+	return encoded, nil
+}
+
+// storedSize returns the bytes held by a stored value, zero for types that are not measurable.
+func storedSize(data any) int {
+	switch v := data.(type) {
+	case []byte:
+		return len(v)
+	case string:
+		return len(v)
+	case encodedValue:
+		return len(v.raw)
+	}
+	return 0
+}
+
+// decodeValue converts stored data back into the value handed to Set.
+func (s *storage) decodeValue(data any) (any, error) {
+	encoded, ok := data.(encodedValue)
+	if !ok {
+		return s.copyValue(data), nil
+	}
+	raw, err := s.plainBytes(encoded)
+	if err != nil {
+		return nil, err
+	}
+	switch encoded.kind {
+	case encodedBytes:
+		if !encoded.compressed && !encoded.encrypted {
+			raw = append([]byte(nil), raw...)
+		}
+		return raw, nil
+	case encodedString:
+		return string(raw), nil
+	}
+	target := reflect.New(encoded.typ)
+	if err := s.codec.Unmarshal(raw, target.Interface()); err != nil {
+		return nil, err
+	}
+	return target.Elem().Interface(), nil
+}
+
+// WithCopyOnWrite stores a copy of every value made by copier and hands out copies on Get, so
+// mutations by callers never reach the cached value. WithSerialization isolates values without a copier.
+func WithCopyOnWrite(copier func(any) any) Option {
+	return func(s *storage) {
+		s.copier = copier
+	}
+}
+
+func (s *storage) copyValue(data any) any {
+	if s.copier == nil || data == nil {
+		return data
+	}
+	return s.copier(data)
+}
+
+// valueOf decodes stored data for callbacks and persistence, data that fails to decode is reported as nil.
+func (s *storage) valueOf(data any) any {
+	value, err := s.decodeValue(data)
+	if err != nil {
+		return nil
+	}
+	return value
+}
+
+// plainBytes returns the serialized bytes of a value, decrypted and decompressed. They alias the
+// stored bytes when the value is neither.
+func (s *storage) plainBytes(encoded encodedValue) ([]byte, error) {
+	raw := encoded.raw
+	var err error
+	if encoded.encrypted {
+		if raw, err = open(s.aead, raw); err != nil {
+			return nil, err
+		}
+	}
+	if encoded.compressed {
+		if raw, err = s.compression.codec.Decompress(raw); err != nil {
+			return nil, err
+		}
+	}
+	return raw, nil
+}
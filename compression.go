@@ -0,0 +1,224 @@
+package addcache
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"sort"
+	"sync"
+)
+
+const (
+	// compressionWindow values of a prefix decide whether it keeps being compressed.
+	compressionWindow = 32
+	// compressionProbeInterval is the share of values of a skipped prefix compressed anyway, one
+	// in that many, so a prefix starting to compress well is compressed again.
+	compressionProbeInterval = 64
+	// compressionMaxPrefixes bounds the tracked prefixes, further ones share the empty prefix.
+	compressionMaxPrefixes      = 1024
+	defaultCompressionThreshold = 0.9
+)
+
+// CompressionCodec compresses stored bytes, implementations must be safe for concurrent use.
+type CompressionCodec interface {
+	Compress(data []byte) ([]byte, error)
+	Decompress(data []byte) ([]byte, error)
+}
+
+// GzipCompression compresses with compress/gzip at Level, zero selects gzip.DefaultCompression.
+type GzipCompression struct {
+	Level int
+}
+
+func (g GzipCompression) Compress(data []byte) ([]byte, error) {
+	level := g.Level
+	if level == 0 {
+		level = gzip.DefaultCompression
+	}
+	var buffer bytes.Buffer
+	writer, err := gzip.NewWriterLevel(&buffer, level)
+	if err != nil {
+		return nil, err
+	}
+	if _, err = writer.Write(data); err != nil {
+		return nil, err
+	}
+	if err = writer.Close(); err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
+
+func (GzipCompression) Decompress(data []byte) ([]byte, error) {
+	reader, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	return io.ReadAll(reader)
+}
+
+// CompressionStat summarizes the compression of the values of a key prefix, the part of their
+// keys before the first default delimiter, see CompressionStats.
+type CompressionStat struct {
+	Prefix string
+	// Compressed counts the values run through the codec, Skipped those stored as they are
+	// because the prefix compressed poorly.
+	Compressed int64
+	Skipped    int64
+	// OriginalBytes and StoredBytes sum the sizes of the compressed values before and after.
+	OriginalBytes int64
+	StoredBytes   int64
+	// Disabled reports whether values of the prefix are currently skipped.
+	Disabled bool
+}
+
+// Ratio returns the stored share of the original bytes, 1 before any value was compressed.
+func (st CompressionStat) Ratio() float64 {
+	if st.OriginalBytes == 0 {
+		return 1
+	}
+	return float64(st.StoredBytes) / float64(st.OriginalBytes)
+}
+
+type compression struct {
+	codec   CompressionCodec
+	minSize int
+
+	mu       sync.Mutex
+	prefixes map[string]*prefixCompression
+}
+
+// prefixCompression tracks a prefix, the window sums the values since the last decision.
+type prefixCompression struct {
+	stat           CompressionStat
+	windowValues   int
+	windowOriginal int64
+	windowStored   int64
+	sinceProbe     int
+}
+
+// WithCompression stores []byte and string values of at least minSize bytes compressed with codec
+// and decompresses them on Get. With WithSerialization the encoded form of any value is compressed.
+// Values that do not shrink are kept as they are. The ratio achieved is tracked per key prefix,
+// prefixes whose values keep more than 90 percent of their size, like images or tokens, stop
+// being compressed apart from an occasional probe, see WithCompressionThreshold.
+func WithCompression(codec CompressionCodec, minSize int) Option {
+	return func(s *storage) {
+		s.compression = &compression{codec: codec, minSize: minSize, prefixes: make(map[string]*prefixCompression)}
+	}
+}
+
+// WithCompressionThreshold sets the ratio of stored to original bytes from which the values of a
+// prefix are no longer compressed, 0.9 by default. A ratio above 1 compresses every value.
+func WithCompressionThreshold(ratio float64) Option {
+	return func(s *storage) {
+		if ratio > 0 {
+			s.compressionThreshold = ratio
+		}
+	}
+}
+
+// CompressionStats returns the compression ratios of the key prefixes sorted by prefix, nil
+// without WithCompression.
+func (s *storage) CompressionStats() []CompressionStat {
+	if s.compression == nil {
+		return nil
+	}
+	c := s.compression
+	c.mu.Lock()
+	stats := make([]CompressionStat, 0, len(c.prefixes))
+	for _, p := range c.prefixes {
+		stats = append(stats, p.stat)
+	}
+	c.mu.Unlock()
+	sort.Slice(stats, func(i, j int) bool {
+		return stats[i].Prefix < stats[j].Prefix
+	})
+	return stats
+}
+
+// prefixLocked returns the tracking of the prefix of key, the caller holds c.mu.
+func (c *compression) prefixLocked(key string) *prefixCompression {
+	prefix := keyPrefix(key)
+	p, ok := c.prefixes[prefix]
+	if !ok && len(c.prefixes) >= compressionMaxPrefixes {
+		prefix = ""
+		p, ok = c.prefixes[prefix]
+	}
+	if !ok {
+		p = &prefixCompression{stat: CompressionStat{Prefix: prefix}}
+		c.prefixes[prefix] = p
+	}
+	return p
+}
+
+// attempt reports whether a value of key is compressed, values of disabled prefixes are skipped
+// apart from every compressionProbeInterval-th.
+func (c *compression) attempt(key string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	p := c.prefixLocked(key)
+	if !p.stat.Disabled {
+		return true
+	}
+	if p.sinceProbe++; p.sinceProbe < compressionProbeInterval {
+		p.stat.Skipped++
+		return false
+	}
+	p.sinceProbe = 0
+	return true
+}
+
+// record adds a compressed value of key and decides about its prefix once the window is full.
+func (c *compression) record(key string, original, stored int, threshold float64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	p := c.prefixLocked(key)
+	p.stat.Compressed++
+	p.stat.OriginalBytes += int64(original)
+	p.stat.StoredBytes += int64(stored)
+	p.windowValues++
+	p.windowOriginal += int64(original)
+	p.windowStored += int64(stored)
+	if p.windowValues < compressionWindow {
+		return
+	}
+	p.stat.Disabled = float64(p.windowStored) >= threshold*float64(p.windowOriginal)
+	p.windowValues, p.windowOriginal, p.windowStored = 0, 0, 0
+}
+
+func (s *storage) compressible(size int) bool {
+	return s.compression != nil && size >= s.compression.minSize
+}
+
+// compress replaces the raw bytes of a value by their compressed form when that saves space and
+// the prefix of key still compresses well.
+func (s *storage) compress(key string, encoded encodedValue) (encodedValue, error) {
+	if !s.compression.attempt(key) {
+		return s.uncompressed(encoded), nil
+	}
+	compressed, err := s.compression.codec.Compress(encoded.raw)
+	if err != nil {
+		return encodedValue{}, err
+	}
+	threshold := s.compressionThreshold
+	if threshold == 0 {
+		threshold = defaultCompressionThreshold
+	}
+	s.compression.record(key, len(encoded.raw), minInt(len(compressed), len(encoded.raw)), threshold)
+	if len(compressed) >= len(encoded.raw) {
+		return s.uncompressed(encoded), nil
+	}
+	encoded.raw = compressed
+	encoded.compressed = true
+	return encoded, nil
+}
+
+// uncompressed keeps a value as it is, copying bytes the caller still owns.
+func (s *storage) uncompressed(encoded encodedValue) encodedValue {
+	if encoded.kind == encodedBytes && s.aead == nil {
+		encoded.raw = append([]byte(nil), encoded.raw...)
+	}
+	return encoded
+}
@@ -0,0 +1,127 @@
+package addcache
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync/atomic"
+	"text/tabwriter"
+	"time"
+)
+
+const debugTopKeys = 20
+
+// ttlBuckets are the upper bounds of the TTL histogram of DebugHandler.
+var ttlBuckets = []time.Duration{time.Second, 10 * time.Second, time.Minute, 10 * time.Minute, time.Hour, 24 * time.Hour}
+
+// DebugHandler serves a plain text report of the cache internals in the manner of net/http/pprof:
+// how the entries spread over the key lock stripes, the biggest keys by estimated size, a
+// histogram of the remaining TTLs and the registered hooks. The query parameter top sets the
+// number of keys listed, 20 by default. The report names keys and needs no token, mount it on
+// an internal listener only.
+func (s *storage) DebugHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		top := debugTopKeys
+		if n, err := strconv.Atoi(r.URL.Query().Get("top")); err == nil && n >= 0 {
+			top = n
+		}
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		s.writeDebugReport(w, top)
+	})
+}
+
+func (s *storage) writeDebugReport(w io.Writer, top int) {
+	type sizedKey struct {
+		key  string
+		data any
+		size int
+	}
+	now := s.now()
+	stripes := make([]int, len(s.keyLocks.stripes))
+	histogram := make([]int, len(ttlBuckets)+1)
+	var keys []sizedKey
+	var expired, persistent int
+
+	s.mu.RLock()
+	entries := len(s.data)
+	for key, sd := range s.data {
+		stripes[s.hasher.Sum64(key)%uint64(len(stripes))]++
+		keys = append(keys, sizedKey{key: key, data: sd.data})
+		switch {
+		case sd.isPersistence:
+			persistent++
+		case s.expiredLocked(key, sd, now):
+			expired++
+		default:
+			remaining := sd.setTime.Add(sd.expireDuration).Sub(now)
+			bucket := sort.Search(len(ttlBuckets), func(i int) bool { return remaining <= ttlBuckets[i] })
+			histogram[bucket]++
+		}
+	}
+	s.mu.RUnlock()
+	// values are sized without the lock, a sizer of WithSizer may be slow
+	for i := range keys {
+		keys[i].size = s.sizeOf(keys[i].key, keys[i].data)
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(tw, "addcache %s: %d entries\n", s.name, entries)
+
+	fmt.Fprintf(tw, "\nstripes: %d\n", len(stripes))
+	fmt.Fprintf(tw, "collisions\t%d\n", atomic.LoadInt64(&s.keyLocks.collisions))
+	if entries > 0 {
+		minimum, maximum := stripes[0], stripes[0]
+		for _, n := range stripes {
+			if n < minimum {
+				minimum = n
+			}
+			if n > maximum {
+				maximum = n
+			}
+		}
+		mean := float64(entries) / float64(len(stripes))
+		fmt.Fprintf(tw, "min\t%d\nmax\t%d (%.2fx mean)\nmean\t%.2f\n", minimum, maximum, float64(maximum)/mean, mean)
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].size != keys[j].size {
+			return keys[i].size > keys[j].size
+		}
+		return keys[i].key < keys[j].key
+	})
+	if top > len(keys) {
+		top = len(keys)
+	}
+	fmt.Fprintf(tw, "\nbiggest keys:\n")
+	for _, k := range keys[:top] {
+		fmt.Fprintf(tw, "%d bytes\t%q\n", k.size, k.key)
+	}
+
+	fmt.Fprintf(tw, "\nttl:\n")
+	for i, bound := range ttlBuckets {
+		fmt.Fprintf(tw, "<= %s\t%d\n", bound, histogram[i])
+	}
+	fmt.Fprintf(tw, "> %s\t%d\n", ttlBuckets[len(ttlBuckets)-1], histogram[len(ttlBuckets)])
+	fmt.Fprintf(tw, "persistent\t%d\nexpired\t%d\n", persistent, expired)
+
+	fmt.Fprintf(tw, "\nhooks:\n")
+	counts := s.hooks.registered()
+	operations := make([]string, 0, len(counts))
+	for operationType := range counts {
+		operations = append(operations, string(operationType))
+	}
+	sort.Strings(operations)
+	for _, operation := range operations {
+		count := counts[OperationType(operation)]
+		fmt.Fprintf(tw, "%s\t%d handlers\t%d batch handlers\n", operation, count[0], count[1])
+	}
+	s.hooksMu.RLock()
+	fmt.Fprintf(tw, "OnEvicted\t%d handlers\n", len(s.evicted))
+	s.hooksMu.RUnlock()
+	if s.hookPool != nil {
+		fmt.Fprintf(tw, "workers\t%d\n", len(s.hookPool.queues))
+	}
+	_ = tw.Flush()
+}
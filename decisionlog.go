@@ -0,0 +1,81 @@
+package addcache
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+)
+
+// Decision names the rule that removed an entry in the decision log.
+type Decision string
+
+const (
+	DecisionTTL      Decision = "ttl"
+	DecisionPolicy   Decision = "policy"
+	DecisionQuota    Decision = "quota"
+	DecisionManual   Decision = "manual"
+	DecisionReplaced Decision = "replaced"
+	DecisionMemory   Decision = "memory"
+)
+
+// DecisionRecord is one line of the decision log.
+type DecisionRecord struct {
+	Time     time.Time `json:"time"`
+	Cache    string    `json:"cache,omitempty"`
+	Key      string    `json:"key"`
+	Reason   string    `json:"reason"`
+	Decision Decision  `json:"decision"`
+	AgeMs    int64     `json:"age_ms"`
+	TTLMs    int64     `json:"ttl_ms,omitempty"`
+}
+
+type decisionLog struct {
+	mu      sync.Mutex
+	encoder *json.Encoder
+}
+
+// WithDecisionLog streams a JSON line per removed entry to w, explaining why it left the cache.
+// Writes are serialized, write errors are dropped so w should handle its own failures.
+func WithDecisionLog(w io.Writer) Option {
+	return func(s *storage) {
+		s.decisionLog = &decisionLog{encoder: json.NewEncoder(w)}
+	}
+}
+
+func (r removal) decisionOf() Decision {
+	if r.decision != "" {
+		return r.decision
+	}
+	switch r.reason {
+	case RemovalExpired:
+		return DecisionTTL
+	case RemovalEvicted:
+		return DecisionPolicy
+	case RemovalReplaced:
+		return DecisionReplaced
+	}
+	return DecisionManual
+}
+
+func (s *storage) logDecision(r removal) {
+	if s.decisionLog == nil {
+		return
+	}
+	now := s.now()
+	record := DecisionRecord{
+		Time:     now,
+		Cache:    s.name,
+		Key:      r.key,
+		Reason:   r.reason.String(),
+		Decision: r.decisionOf(),
+		AgeMs:    now.Sub(r.entry.setTime).Milliseconds(),
+	}
+	if !r.entry.isPersistence {
+		record.TTLMs = r.entry.expireDuration.Milliseconds()
+	}
+
+	s.decisionLog.mu.Lock()
+	_ = s.decisionLog.encoder.Encode(record)
+	s.decisionLog.mu.Unlock()
+}
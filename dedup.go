@@ -0,0 +1,71 @@
+package addcache
+
+import "time"
+
+type recentLoad struct {
+	data  any
+	until time.Time
+}
+
+// WithLoadDedupWindow lets reads missing a key within window after a successful load of it
+// share the loaded value instead of loading again, even when the entry was deleted or
+// invalidated in the meantime. This keeps invalidation storms from turning into load storms.
+// Writing the key ends the window early.
+func WithLoadDedupWindow(window time.Duration) Option {
+	return func(s *storage) {
+		if window > 0 {
+			s.loaders.dedupWindow = window
+		}
+	}
+}
+
+// recentlyLoaded returns the value of a load of key that finished within the window.
+func (l *loaders) recentlyLoaded(key string, now time.Time) (any, bool) {
+	if l.dedupWindow == 0 {
+		return nil, false
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	recent, ok := l.recent[key]
+	if !ok || !now.Before(recent.until) {
+		return nil, false
+	}
+	return recent.data, true
+}
+
+// rememberLoad starts the window of a successful load.
+func (l *loaders) rememberLoad(key string, data any, now time.Time) {
+	if l.dedupWindow == 0 {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.recent == nil {
+		l.recent = make(map[string]recentLoad)
+	}
+	l.recent[key] = recentLoad{data: data, until: now.Add(l.dedupWindow)}
+}
+
+// forgetLoad ends the window of a key written to the cache.
+func (l *loaders) forgetLoad(key string) {
+	if l.dedupWindow == 0 {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.recent, key)
+}
+
+// dropPassedLoads drops the loads whose window passed at now, called by the cleanup passes.
+func (l *loaders) dropPassedLoads(now time.Time) {
+	if l.dedupWindow == 0 {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for key, recent := range l.recent {
+		if !now.Before(recent.until) {
+			delete(l.recent, key)
+		}
+	}
+}
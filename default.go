@@ -0,0 +1,28 @@
+package addcache
+
+import "sync"
+
+var (
+	defaultMu    sync.Mutex
+	defaultCache Cache
+)
+
+// Default returns the process wide cache, creating it with NewCache on first use.
+func Default() Cache {
+	defaultMu.Lock()
+	defer defaultMu.Unlock()
+	if defaultCache == nil {
+		defaultCache = NewCache()
+	}
+	return defaultCache
+}
+
+// SetDefault replaces the process wide cache returned by Default.
+// The previous instance is returned so the caller can stop it, nil when none was created yet.
+func SetDefault(cache Cache) Cache {
+	defaultMu.Lock()
+	defer defaultMu.Unlock()
+	previous := defaultCache
+	defaultCache = cache
+	return previous
+}
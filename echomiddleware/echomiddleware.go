@@ -0,0 +1,93 @@
+// Package echomiddleware caches Echo responses in an addcache.Cache with the rules of the
+// httpcache package:
+//
+//	cached := echomiddleware.New(cache)
+//	e.GET("/products", listProducts, cached.TTL(time.Minute))
+//	e.GET("/news", listNews, cached.TTL(10*time.Second))
+package echomiddleware
+
+import (
+	"time"
+
+	"github.com/addit-digital/addcache"
+	"github.com/addit-digital/addcache/httpcache"
+	"github.com/labstack/echo/v5"
+)
+
+// KeyFunc derives the cache key of a request.
+type KeyFunc func(c *echo.Context) string
+
+// Option configures New.
+type Option func(m *Middleware)
+
+// WithKeyFunc replaces the httpcache key of method, host, URL and Vary headers.
+func WithKeyFunc(keyFunc KeyFunc) Option {
+	return func(m *Middleware) {
+		m.keyFunc = keyFunc
+	}
+}
+
+// WithOptions configures the underlying httpcache.Middleware, e.g. with httpcache.WithVary.
+func WithOptions(options ...httpcache.Option) Option {
+	return func(m *Middleware) {
+		m.options = append(m.options, options...)
+	}
+}
+
+// WithTTL caches responses without max-age for ttl, by default they are not cached.
+func WithTTL(ttl time.Duration) Option {
+	return func(m *Middleware) {
+		m.ttl = ttl
+	}
+}
+
+// Middleware creates Echo middleware caching responses.
+type Middleware struct {
+	cache   *httpcache.Middleware
+	options []httpcache.Option
+	keyFunc KeyFunc
+	ttl     time.Duration
+}
+
+// New caches responses in cache, which stays owned by the caller.
+func New(cache addcache.Cache, options ...Option) *Middleware {
+	m := &Middleware{}
+	for _, option := range options {
+		option(m)
+	}
+	m.cache = httpcache.New(cache, m.options...)
+	return m
+}
+
+// Middleware caches responses without max-age for the WithTTL duration.
+func (m *Middleware) Middleware() echo.MiddlewareFunc {
+	return m.TTL(m.ttl)
+}
+
+// TTL caches responses without max-age for ttl, for routes with their own TTL.
+func (m *Middleware) TTL(ttl time.Duration) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c *echo.Context) error {
+			key := m.key(c)
+			if m.cache.ServeKey(c.Response(), c.Request(), key) {
+				return nil
+			}
+			original := c.Response()
+			recorder := m.cache.CaptureKey(original, c.Request(), key)
+			c.SetResponse(recorder)
+			defer c.SetResponse(original)
+			if err := next(c); err != nil {
+				return err
+			}
+			recorder.FinishWithTTL(ttl)
+			return nil
+		}
+	}
+}
+
+func (m *Middleware) key(c *echo.Context) string {
+	if m.keyFunc != nil {
+		return m.keyFunc(c)
+	}
+	return m.cache.Key(c.Request())
+}
@@ -0,0 +1,10 @@
+module github.com/addit-digital/addcache/echomiddleware
+
+go 1.25.0
+
+require (
+	github.com/addit-digital/addcache v0.0.0
+	github.com/labstack/echo/v5 v5.3.1
+)
+
+replace github.com/addit-digital/addcache => ../
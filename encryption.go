@@ -0,0 +1,61 @@
+package addcache
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"fmt"
+	"io"
+)
+
+// WithEncryption encrypts stored values, snapshots and the append-only log with AES-GCM under key,
+// which must be 16, 24 or 32 bytes long. With another length writes and snapshots fail with
+// ErrInvalidOption and the snapshot file and log are left alone. In memory only []byte and string
+// values are encrypted, other types need WithSerialization.
+func WithEncryption(key []byte) Option {
+	return func(s *storage) {
+		block, err := aes.NewCipher(key)
+		var aead cipher.AEAD
+		if err == nil {
+			aead, err = cipher.NewGCM(block)
+		}
+		if err != nil {
+			s.optionErr = fmt.Errorf("%w: encryption: %v", ErrInvalidOption, err)
+			return
+		}
+		s.aead = aead
+	}
+}
+
+// seal encrypts plaintext under a random nonce and returns the nonce followed by the ciphertext.
+func seal(aead cipher.AEAD, plaintext []byte) ([]byte, error) {
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(plaintext)+aead.Overhead())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+// open reverses seal, tampered data and a wrong key are reported as ErrSnapshotCorrupt.
+func open(aead cipher.AEAD, sealed []byte) ([]byte, error) {
+	if len(sealed) < aead.NonceSize() {
+		return nil, ErrSnapshotCorrupt
+	}
+	nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	plaintext, err := aead.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return nil, ErrSnapshotCorrupt
+	}
+	return plaintext, nil
+}
+
+// encrypt seals the raw bytes of a value.
+func (s *storage) encrypt(encoded encodedValue) (encodedValue, error) {
+	sealed, err := seal(s.aead, encoded.raw)
+	if err != nil {
+		return encodedValue{}, err
+	}
+	encoded.raw = sealed
+	encoded.encrypted = true
+	return encoded, nil
+}
@@ -0,0 +1,81 @@
+package addcache
+
+import (
+	"sort"
+	"time"
+)
+
+// prefixEpoch is the epoch of a prefix and the value of the epoch clock when it was bumped.
+type prefixEpoch struct {
+	epoch    uint64
+	bumpedAt uint64
+}
+
+// BumpEpoch invalidates every entry written under prefix so far and returns the new epoch of the prefix.
+// It runs in constant time, invalidated entries read as missing and are reclaimed by the cleanup.
+// Entries on hold stay readable, an empty prefix flushes the whole cache. Read only and closed caches keep their epochs.
+func (s *storage) BumpEpoch(prefix string) uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.writable() != nil {
+		return s.epochs[prefix].epoch
+	}
+	if s.aof != nil {
+		s.aof.append(AOFRecord{Op: aofOpEpoch, Time: s.now(), Key: prefix})
+	}
+	return s.bumpEpochLocked(prefix)
+}
+
+// bumpEpochLocked advances the epoch of prefix, the caller holds the write lock.
+func (s *storage) bumpEpochLocked(prefix string) uint64 {
+	if s.epochs == nil {
+		s.epochs = make(map[string]prefixEpoch)
+	}
+	current, ok := s.epochs[prefix]
+	if !ok {
+		s.epochLengths = append(s.epochLengths, len(prefix))
+		sort.Ints(s.epochLengths)
+	}
+	s.epochClock++
+	current.epoch++
+	current.bumpedAt = s.epochClock
+	s.epochs[prefix] = current
+	s.publishEpochsLocked()
+	return current.epoch
+}
+
+// Epoch returns the current epoch of prefix, zero until it is bumped.
+func (s *storage) Epoch(prefix string) uint64 {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.epochs[prefix].epoch
+}
+
+// staleLocked reports whether an epoch covering the key invalidated the entry, entries on hold
+// never are. The caller holds the lock.
+func (s *storage) staleLocked(key string, sd storageData) bool {
+	return !sd.held && s.bumpedSinceLocked(key, sd)
+}
+
+// bumpedSinceLocked reports whether an epoch covering the key was bumped after the entry was written.
+// Only the lengths of bumped prefixes are probed, the caller holds the lock.
+func (s *storage) bumpedSinceLocked(key string, sd storageData) bool {
+	return bumpedSince(s.epochs, s.epochLengths, key, sd)
+}
+
+func bumpedSince(epochs map[string]prefixEpoch, lengths []int, key string, sd storageData) bool {
+	for _, length := range lengths {
+		if length > len(key) {
+			break
+		}
+		if current, ok := epochs[key[:length]]; ok && current.bumpedAt > sd.epoch {
+			return true
+		}
+	}
+	return false
+}
+
+// expiredLocked reports whether the entry expired or was invalidated by an epoch, the caller holds the lock.
+func (s *storage) expiredLocked(key string, sd storageData, now time.Time) bool {
+	return sd.isExpired(now) || s.staleLocked(key, sd)
+}
@@ -0,0 +1,73 @@
+package addcache
+
+import "errors"
+
+// ErrCache is wrapped by every error of the package, errors.Is(err, ErrCache) tells cache failures apart.
+var ErrCache = errors.New("exception.cache")
+
+// The error values below are stable API, compare them with errors.Is as they may be wrapped with details.
+var (
+	// ErrCacheKeyNotFound is returned for missing and expired keys.
+	ErrCacheKeyNotFound = newError("exception.cache.key.not-found", ErrCache)
+	// ErrNegativeCached is the ErrCacheKeyNotFound of keys a loader recently did not know, see WithNegativeTTL.
+	ErrNegativeCached = newError("exception.cache.key.negative-cached", ErrCacheKeyNotFound)
+	// ErrCacheClosed is returned by operations on a cache after Close.
+	ErrCacheClosed = newError("exception.cache.closed", ErrCache)
+	// ErrCapacityExceeded is returned for writes rejected by a limit such as WithPrefixLimit.
+	ErrCapacityExceeded = newError("exception.cache.capacity.exceeded", ErrCache)
+	// ErrAdmissionDenied is the ErrCapacityExceeded of new keys an external policy did not admit, see WithExternalPolicy.
+	ErrAdmissionDenied = newError("exception.cache.admission.denied", ErrCapacityExceeded)
+	// ErrValueTooLarge is returned for values larger than WithMaxValueSize.
+	ErrValueTooLarge = newError("exception.cache.value.too-large", ErrCache)
+	// ErrTypeMismatch is returned when the stored value does not have the type an operation needs.
+	ErrTypeMismatch = newError("exception.cache.value.type-mismatch", ErrCache)
+	// ErrCacheValueNotInteger is the ErrTypeMismatch of Increment and Decrement.
+	ErrCacheValueNotInteger = newError("exception.cache.value.not-integer", ErrTypeMismatch)
+	// ErrTimeout is returned when an operation gave up waiting, see WithComputeTimeout.
+	ErrTimeout = newError("exception.cache.timeout", ErrCache)
+	// ErrScoreNotANumber is returned for sorted set scores that are or would become NaN.
+	ErrScoreNotANumber = newError("exception.cache.score.not-a-number", ErrCache)
+	// ErrReadOnly is returned by writes while the cache is switched to read only with SetReadOnly.
+	ErrReadOnly = newError("exception.cache.read-only", ErrCache)
+	// ErrCacheKeyRetained is returned for writes and removals of keys protected by a hold or retention rule.
+	ErrCacheKeyRetained = newError("exception.cache.key.retained", ErrCache)
+	// ErrLockNotHeld is returned when releasing or extending a lock that expired or was taken over, see TryLock.
+	ErrLockNotHeld = newError("exception.cache.lock.not-held", ErrCache)
+	// ErrBackingStore is returned when the BackingStore of WithWriteThrough failed under the FailClosed policy.
+	ErrBackingStore = newError("exception.cache.backing-store", ErrCache)
+	// ErrCircuitOpen is returned for loads skipped while the loader circuit breaker is open, see WithLoaderCircuitBreaker.
+	ErrCircuitOpen = newError("exception.cache.circuit-open", ErrCache)
+	// ErrKeyOutsidePrefix is returned by SwapGeneration for keys not starting with the prefix.
+	ErrKeyOutsidePrefix = newError("exception.cache.key.outside-prefix", ErrCache)
+	// ErrSnapshotInvalid is returned for snapshots that can not be decoded.
+	ErrSnapshotInvalid = newError("exception.cache.snapshot.invalid", ErrCache)
+	// ErrSnapshotCorrupt is returned for snapshot files failing their checksum.
+	ErrSnapshotCorrupt = newError("exception.cache.snapshot.corrupt", ErrCache)
+	// ErrLogCorrupt is returned for append-only logs holding a record of an impossible length.
+	ErrLogCorrupt = newError("exception.cache.aof.corrupt", ErrCache)
+	// ErrHookQueueFull is passed to the HookErrorHandler for hooks dropped at a full worker queue.
+	ErrHookQueueFull = newError("exception.cache.hook.queue-full", ErrCache)
+	// ErrInvalidOption wraps the error of an option that could not be applied, the cache fails
+	// writes and snapshots with it.
+	ErrInvalidOption = newError("exception.cache.option.invalid", ErrCache)
+	// ErrLoaderPanicked is returned to the misses waiting for a load whose loader panicked.
+	ErrLoaderPanicked = newError("exception.cache.loader.panicked", ErrCache)
+)
+
+// cacheError is a sentinel wrapping the more general sentinel it refines.
+type cacheError struct {
+	message string
+	parent  error
+}
+
+func newError(message string, parent error) error {
+	return &cacheError{message: message, parent: parent}
+}
+
+func (e *cacheError) Error() string {
+	return e.message
+}
+
+func (e *cacheError) Unwrap() error {
+	return e.parent
+}
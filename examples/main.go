@@ -10,8 +10,8 @@ func main() {
 	// NewCache method creates cache with default values
 	cache := addcache.NewCache()
 
-	// Cleaning of memory can be stopped manually
-	defer cache.StopCleanup()
+	// Close stops the background cleanup and waits for pending hooks
+	defer cache.Close()
 
 	// CreateKey creates key with semicolon delimited
 	userKey := cache.CreateKey("user", "12")
@@ -26,7 +26,8 @@ func main() {
 	// Getting data from cache with key
 	user, err := cache.Get(userKey)
 	if err != nil {
-		fmt.Errorf("cache error - %v", err)
+		fmt.Printf("cache error - %v\n", err)
+		return
 	}
 	fmt.Print(user.(User))
 
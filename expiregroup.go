@@ -0,0 +1,82 @@
+package addcache
+
+import "time"
+
+type expireGroup struct {
+	at   time.Time
+	keys map[string]struct{}
+}
+
+// ExpireGroupAt schedules the keys to expire together at the given instant under the named group.
+// Keys join the group even when missing, so data written to a member before the instant expires
+// with it too. Calling it again for the group adds keys and moves the instant for all members.
+// Read only and closed caches report their error, the append-only log records the group.
+func (s *storage) ExpireGroupAt(group string, at time.Time, keys ...string) error {
+	if err := s.writable(); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.aof != nil {
+		s.aof.append(AOFRecord{Op: aofOpExpireGroup, Time: s.now(), Key: group, SetTime: at, Data: keys})
+	}
+	s.expireGroupLocked(group, at, keys)
+	return nil
+}
+
+// expireGroupLocked adds the keys to the group and moves the expiration of its entries to at, the
+// caller holds the write lock.
+func (s *storage) expireGroupLocked(group string, at time.Time, keys []string) {
+	if s.expireGroups == nil {
+		s.expireGroups = make(map[string]*expireGroup)
+		s.groupOf = make(map[string]string)
+	}
+	g, ok := s.expireGroups[group]
+	if !ok {
+		g = &expireGroup{keys: make(map[string]struct{})}
+		s.expireGroups[group] = g
+	}
+	g.at = at
+	for _, key := range keys {
+		if previous, ok := s.groupOf[key]; ok && previous != group {
+			delete(s.expireGroups[previous].keys, key)
+		}
+		g.keys[key] = struct{}{}
+		s.groupOf[key] = group
+	}
+	s.readMostly.beginBatch()
+	defer s.readMostly.endBatch()
+	for key := range g.keys {
+		if sd, ok := s.data[key]; ok {
+			s.putLocked(key, s.applyRetention(key, sd.expiringAt(at)))
+		}
+	}
+}
+
+// applyExpireGroup moves the expiration of a written entry to the instant of its group, the caller holds the write lock.
+func (s *storage) applyExpireGroup(key string, sd storageData) storageData {
+	group, ok := s.groupOf[key]
+	if !ok || !s.expireGroups[group].at.After(sd.setTime) {
+		return sd
+	}
+	return sd.expiringAt(s.expireGroups[group].at)
+}
+
+// dropPassedGroupsLocked forgets groups whose instant has passed, the caller holds the write lock.
+func (s *storage) dropPassedGroupsLocked(now time.Time) {
+	for name, g := range s.expireGroups {
+		if g.at.After(now) {
+			continue
+		}
+		for key := range g.keys {
+			delete(s.groupOf, key)
+		}
+		delete(s.expireGroups, name)
+	}
+}
+
+func (sd storageData) expiringAt(at time.Time) storageData {
+	sd.isPersistence = false
+	sd.expireDuration = at.Sub(sd.setTime)
+	return sd
+}
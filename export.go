@@ -0,0 +1,58 @@
+package addcache
+
+import (
+	"encoding/csv"
+	"io"
+	"sort"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+// ExportCSV writes one row per live entry with key, prefix, remaining TTL in seconds, approximate
+// size in bytes and the hits since the key was created, sorted by key. The TTL of persistent
+// entries is left empty.
+func (s *storage) ExportCSV(w io.Writer) error {
+	if err := s.readable(); err != nil {
+		return err
+	}
+	type row struct {
+		key string
+		sd  storageData
+	}
+	now := s.now()
+	s.mu.RLock()
+	rows := make([]row, 0, len(s.data))
+	for key, sd := range s.data {
+		if !s.expiredLocked(key, sd, now) {
+			rows = append(rows, row{key: key, sd: sd})
+		}
+	}
+	s.mu.RUnlock()
+	sort.Slice(rows, func(i, j int) bool { return rows[i].key < rows[j].key })
+
+	writer := csv.NewWriter(w)
+	if err := writer.Write([]string{"key", "prefix", "ttl_seconds", "size_bytes", "hits"}); err != nil {
+		return err
+	}
+	for _, r := range rows {
+		ttl := ""
+		if !r.sd.isPersistence {
+			remaining := r.sd.setTime.Add(r.sd.expireDuration).Sub(now)
+			if remaining < 0 {
+				remaining = 0
+			}
+			ttl = strconv.FormatInt(int64(remaining/time.Second), 10)
+		}
+		var hits int64
+		if r.sd.meta != nil {
+			hits = atomic.LoadInt64(&r.sd.meta.hits)
+		}
+		record := []string{r.key, keyPrefix(r.key), ttl, strconv.Itoa(s.sizeOf(r.key, r.sd.data)), strconv.FormatInt(hits, 10)}
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
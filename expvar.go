@@ -0,0 +1,12 @@
+package addcache
+
+import "expvar"
+
+// PublishExpvar publishes the Stats of cache under name in the expvar package, so /debug/vars
+// reports hits, misses, entries, evictions and the other counters. The stats are read on every
+// scrape. Like expvar.Publish it panics when name is already in use.
+func PublishExpvar(cache LocalCache, name string) {
+	expvar.Publish(name, expvar.Func(func() any {
+		return cache.Stats()
+	}))
+}
@@ -0,0 +1,202 @@
+package addcache
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// evictionCandidates is the number of least recently used keys an external policy chooses the victim from.
+const evictionCandidates = 8
+
+// PolicyDecision is the verdict of a PolicyService for the keys of one prefix.
+type PolicyDecision struct {
+	// Admit tells whether new keys of the prefix may be written, updates of existing keys are always admitted.
+	Admit bool `json:"admit"`
+	// EvictionPriority orders eviction candidates, keys of prefixes with a higher priority are evicted first.
+	EvictionPriority int `json:"evictionPriority"`
+}
+
+// PolicyService makes admission and eviction decisions outside the process, e.g. for a whole fleet.
+type PolicyService interface {
+	Decide(prefix string) (PolicyDecision, error)
+}
+
+// PolicyServiceFunc adapts a function to PolicyService.
+type PolicyServiceFunc func(prefix string) (PolicyDecision, error)
+
+func (f PolicyServiceFunc) Decide(prefix string) (PolicyDecision, error) {
+	return f(prefix)
+}
+
+type externalPolicy struct {
+	service     PolicyService
+	decisionTTL time.Duration
+	mu          sync.Mutex
+	decisions   map[string]cachedDecision
+	// due holds the prefixes waiting for Sweep in builds without background tasks, stopped
+	// keeps Close from waiting for refreshes started after it.
+	due     []string
+	stopped bool
+}
+
+type cachedDecision struct {
+	decision   PolicyDecision
+	fetched    time.Time
+	refreshing bool
+}
+
+// fallbackDecision applies until the service answered for a prefix or when it never does.
+var fallbackDecision = PolicyDecision{Admit: true}
+
+// WithExternalPolicy delegates admission of new keys and the choice between eviction candidates
+// to service, per key prefix. Decisions are cached for decisionTTL and refreshed in the background,
+// writes never wait for the service: until it answered and while it fails, the last decision or
+// admitting with priority 0 applies. Keys denied admission are rejected with ErrAdmissionDenied.
+func WithExternalPolicy(service PolicyService, decisionTTL time.Duration) Option {
+	return func(s *storage) {
+		s.externalPolicy = &externalPolicy{
+			service:     service,
+			decisionTTL: decisionTTL,
+			decisions:   make(map[string]cachedDecision),
+		}
+	}
+}
+
+// decide returns the cached decision of the prefix and schedules a refresh when it is missing or
+// stale. It is called with the write lock of the cache held, so the service is never asked here:
+// the refresh runs on a goroutine of its own, or in builds without background tasks on Sweep.
+func (s *storage) decide(prefix string) PolicyDecision {
+	p := s.externalPolicy
+	now := s.now()
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	cached, ok := p.decisions[prefix]
+	if !ok {
+		cached.decision = fallbackDecision
+	}
+	if cached.refreshing || ok && now.Sub(cached.fetched) < p.decisionTTL || p.stopped {
+		return cached.decision
+	}
+	cached.refreshing = true
+	p.decisions[prefix] = cached
+	if !backgroundTasks {
+		p.due = append(p.due, prefix)
+		return cached.decision
+	}
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		s.refreshDecision(prefix)
+	}()
+	return cached.decision
+}
+
+// refreshDueDecisions asks the service for the prefixes decide scheduled.
+func (s *storage) refreshDueDecisions() {
+	p := s.externalPolicy
+	p.mu.Lock()
+	due := p.due
+	p.due = nil
+	p.mu.Unlock()
+	for _, prefix := range due {
+		s.refreshDecision(prefix)
+	}
+}
+
+// stopExternalPolicy stops scheduling refreshes, Close waits for those running.
+func (s *storage) stopExternalPolicy() {
+	p := s.externalPolicy
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.stopped = true
+}
+
+func (s *storage) refreshDecision(prefix string) {
+	p := s.externalPolicy
+	decision, err := p.service.Decide(prefix)
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	cached := p.decisions[prefix]
+	cached.refreshing = false
+	// a failed refresh keeps the previous decision and is retried on the next write
+	if err == nil {
+		cached.decision = decision
+		cached.fetched = s.now()
+	}
+	p.decisions[prefix] = cached
+}
+
+// admitPolicyLocked consults the external policy for a new key, the caller holds the write lock.
+func (s *storage) admitPolicyLocked(key string) bool {
+	if s.externalPolicy == nil {
+		return true
+	}
+	if _, ok := s.data[key]; ok {
+		return true
+	}
+	return s.decide(keyPrefix(key)).Admit
+}
+
+// evictionVictimLocked picks the key to evict among those accepted by evictable, the caller holds the write lock.
+// Without an external policy it is the victim of the eviction policy, with one and the default
+// LRU policy it is the candidate of the highest priority among the oldest few, the older one on a tie.
+func (s *storage) evictionVictimLocked(evictable func(key string) bool) (string, bool) {
+	lru, isLRU := s.capacity.policy.(lruPolicy)
+	if s.externalPolicy == nil || !isLRU {
+		return s.capacity.policy.Victim(evictable)
+	}
+	candidates := lru.oldestN(evictionCandidates, evictable)
+	if len(candidates) == 0 {
+		return "", false
+	}
+	victim, priority := candidates[0], s.decide(keyPrefix(candidates[0])).EvictionPriority
+	for _, key := range candidates[1:] {
+		if p := s.decide(keyPrefix(key)).EvictionPriority; p > priority {
+			victim, priority = key, p
+		}
+	}
+	return victim, true
+}
+
+// HTTPPolicyService asks a policy endpoint with GET <url>?prefix=<prefix>
+// and expects a JSON encoded PolicyDecision in a 200 response.
+type HTTPPolicyService struct {
+	URL    string
+	Client *http.Client
+}
+
+// NewHTTPPolicyService returns a PolicyService for the endpoint, a nil client uses one with a 5 second timeout.
+func NewHTTPPolicyService(endpoint string, client *http.Client) *HTTPPolicyService {
+	if client == nil {
+		client = &http.Client{Timeout: 5 * time.Second}
+	}
+	return &HTTPPolicyService{URL: endpoint, Client: client}
+}
+
+func (h *HTTPPolicyService) Decide(prefix string) (PolicyDecision, error) {
+	endpoint, err := url.Parse(h.URL)
+	if err != nil {
+		return PolicyDecision{}, err
+	}
+	query := endpoint.Query()
+	query.Set("prefix", prefix)
+	endpoint.RawQuery = query.Encode()
+
+	response, err := h.Client.Get(endpoint.String())
+	if err != nil {
+		return PolicyDecision{}, err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return PolicyDecision{}, fmt.Errorf("policy service: unexpected status %s", response.Status)
+	}
+	var decision PolicyDecision
+	if err := json.NewDecoder(response.Body).Decode(&decision); err != nil {
+		return PolicyDecision{}, err
+	}
+	return decision, nil
+}
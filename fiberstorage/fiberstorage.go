@@ -0,0 +1,138 @@
+// Package fiberstorage implements the Fiber storage interface on an addcache.Cache, e.g. as
+// backend of the session, cache and limiter middleware:
+//
+//	store := session.NewStore(session.Config{Storage: fiberstorage.New(cache)})
+package fiberstorage
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/addit-digital/addcache"
+	"github.com/gofiber/fiber/v3"
+)
+
+const defaultKeyPrefix = "fiber"
+
+// Option configures New.
+type Option func(s *Storage)
+
+// WithKeyPrefix sets the prefix of the keys, "fiber" by default. Reset removes the keys under it.
+func WithKeyPrefix(prefix string) Option {
+	return func(s *Storage) {
+		s.keyPrefix = prefix
+	}
+}
+
+// Storage keeps the values of Fiber middleware in a cache.
+type Storage struct {
+	cache     addcache.Cache
+	keyPrefix string
+}
+
+var _ fiber.Storage = (*Storage)(nil)
+
+// New stores values in cache, which stays owned by the caller.
+func New(cache addcache.Cache, options ...Option) *Storage {
+	s := &Storage{cache: cache, keyPrefix: defaultKeyPrefix}
+	for _, option := range options {
+		option(s)
+	}
+	return s
+}
+
+// Get returns nil without error for missing keys.
+func (s *Storage) Get(key string) ([]byte, error) {
+	if key == "" {
+		return nil, nil
+	}
+	data, err := s.cache.Get(s.key(key))
+	if errors.Is(err, addcache.ErrCacheKeyNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	raw, ok := data.([]byte)
+	if !ok {
+		return nil, addcache.ErrTypeMismatch
+	}
+	return raw, nil
+}
+
+func (s *Storage) GetWithContext(ctx context.Context, key string) ([]byte, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return s.Get(key)
+}
+
+// Set stores the value for exp, zero stores it without expiration. Empty keys and values are ignored.
+// Fiber's session middleware saves sessions with their idle timeout, which slides their expiration.
+func (s *Storage) Set(key string, val []byte, exp time.Duration) error {
+	if key == "" || len(val) == 0 {
+		return nil
+	}
+	if exp > 0 {
+		s.cache.SetEx(s.key(key), append([]byte(nil), val...), exp)
+		return nil
+	}
+	s.cache.SetPersistent(s.key(key), append([]byte(nil), val...))
+	return nil
+}
+
+func (s *Storage) SetWithContext(ctx context.Context, key string, val []byte, exp time.Duration) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return s.Set(key, val, exp)
+}
+
+func (s *Storage) Delete(key string) error {
+	if key == "" {
+		return nil
+	}
+	s.cache.Delete(s.key(key))
+	return nil
+}
+
+func (s *Storage) DeleteWithContext(ctx context.Context, key string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return s.Delete(key)
+}
+
+// Reset removes the keys under the prefix. It needs a cache with BumpEpoch or Keys such as
+// addcache.LocalCache, other caches report errors.ErrUnsupported.
+func (s *Storage) Reset() error {
+	prefix := s.keyPrefix + ":"
+	switch cache := s.cache.(type) {
+	case interface{ BumpEpoch(prefix string) uint64 }:
+		cache.BumpEpoch(prefix)
+	case interface{ Keys(prefix string) []string }:
+		for _, key := range cache.Keys(prefix) {
+			s.cache.Delete(key)
+		}
+	default:
+		return errors.ErrUnsupported
+	}
+	return nil
+}
+
+func (s *Storage) ResetWithContext(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return s.Reset()
+}
+
+// Close is a no-op, the cache is owned by the caller.
+func (s *Storage) Close() error {
+	return nil
+}
+
+func (s *Storage) key(key string) string {
+	return s.keyPrefix + ":" + key
+}
@@ -0,0 +1,113 @@
+package addcache
+
+import (
+	"fmt"
+	"strings"
+)
+
+// SwapGeneration atomically replaces every entry under prefix with newEntries, readers see
+// either the old or the new generation. Keys of newEntries are full keys starting with prefix,
+// they are stored like Set. Entries retained by a hold or minimum lifetime survive the swap.
+// All values are encoded before the swap, a value rejected by the codec, a quota or admission
+// fails the swap and leaves the old generation in place.
+func (s *storage) SwapGeneration(prefix string, newEntries map[string]any) error {
+	for key := range newEntries {
+		if !strings.HasPrefix(key, prefix) {
+			return fmt.Errorf("%w: %q", ErrKeyOutsidePrefix, key)
+		}
+	}
+
+	if err := s.writable(); err != nil {
+		return err
+	}
+	encoded := make(map[string]any, len(newEntries))
+	for key, data := range newEntries {
+		value, err := s.encodeValue(key, data)
+		if err != nil {
+			return fmt.Errorf("%w: %q", err, key)
+		}
+		encoded[key] = value
+	}
+	now := s.now()
+	var removals []removal
+	saved := make(map[string]savedEntry)
+	updated := make(map[string]bool, len(encoded))
+
+	s.mu.Lock()
+	for key := range newEntries {
+		if sd, ok := s.data[key]; ok && sd.held {
+			s.mu.Unlock()
+			return fmt.Errorf("%w: %q", ErrCacheKeyRetained, key)
+		}
+	}
+	s.readMostly.beginBatch()
+	for key, sd := range s.data {
+		if _, replaced := newEntries[key]; replaced || !strings.HasPrefix(key, prefix) || s.protected(key, sd, now) {
+			continue
+		}
+		s.deleteLocked(key)
+		saved[key] = savedEntry{sd: sd, ok: true}
+		removals = append(removals, removal{key: key, entry: sd, reason: RemovalDeleted, decision: DecisionReplaced})
+	}
+	for key, value := range encoded {
+		before, existed := s.data[key]
+		previous, replaced, evicted, err := s.insertLocked(key, s.newEntry(value, now))
+		if err != nil {
+			s.rollbackSwapLocked(saved)
+			s.readMostly.endBatch()
+			s.mu.Unlock()
+			return fmt.Errorf("%w: %q", err, key)
+		}
+		saveEntry(saved, key, before, existed)
+		for _, r := range evicted {
+			saveEntry(saved, r.key, r.entry, true)
+		}
+		if replaced {
+			removals = append(removals, removal{key: key, entry: previous, reason: RemovalReplaced})
+		}
+		removals = append(removals, evicted...)
+		updated[key] = replaced
+	}
+	s.queueRemovalsLocked(removals...)
+	for key, data := range newEntries {
+		s.queueWriteLocked(key, data, updated[key])
+	}
+	entries := len(s.data)
+	s.readMostly.endBatch()
+	s.mu.Unlock()
+
+	s.finishRemovals(removals...)
+	for key, data := range newEntries {
+		s.countersOf(key).set()
+		s.notifyWrite(key, data)
+	}
+	s.checkPressure(prefix, entries)
+	return nil
+}
+
+// savedEntry is the state of a key before a swap changed it, ok is false for a missing key.
+type savedEntry struct {
+	sd storageData
+	ok bool
+}
+
+// saveEntry remembers the state of key unless an earlier change of the swap already did.
+func saveEntry(saved map[string]savedEntry, key string, sd storageData, ok bool) {
+	if _, seen := saved[key]; !seen {
+		saved[key] = savedEntry{sd: sd, ok: ok}
+	}
+}
+
+// rollbackSwapLocked restores the keys a failed swap changed, the caller holds the write lock.
+func (s *storage) rollbackSwapLocked(saved map[string]savedEntry) {
+	for key, entry := range saved {
+		if !entry.ok {
+			if _, ok := s.data[key]; ok {
+				s.deleteLocked(key)
+			}
+			continue
+		}
+		s.putLocked(key, entry.sd)
+		s.track(key)
+	}
+}
@@ -0,0 +1,113 @@
+// Package ginmiddleware caches Gin responses in an addcache.Cache with the rules of the
+// httpcache package:
+//
+//	cached := ginmiddleware.New(cache)
+//	router.GET("/products", cached.TTL(time.Minute), listProducts)
+//	router.GET("/news", cached.TTL(10*time.Second), listNews)
+package ginmiddleware
+
+import (
+	"time"
+
+	"github.com/addit-digital/addcache"
+	"github.com/addit-digital/addcache/httpcache"
+	"github.com/gin-gonic/gin"
+)
+
+// KeyFunc derives the cache key of a request.
+type KeyFunc func(c *gin.Context) string
+
+// Option configures New.
+type Option func(m *Middleware)
+
+// WithKeyFunc replaces the httpcache key of method, host, URL and Vary headers.
+func WithKeyFunc(keyFunc KeyFunc) Option {
+	return func(m *Middleware) {
+		m.keyFunc = keyFunc
+	}
+}
+
+// WithOptions configures the underlying httpcache.Middleware, e.g. with httpcache.WithVary.
+func WithOptions(options ...httpcache.Option) Option {
+	return func(m *Middleware) {
+		m.options = append(m.options, options...)
+	}
+}
+
+// WithTTL caches responses without max-age for ttl, by default they are not cached.
+func WithTTL(ttl time.Duration) Option {
+	return func(m *Middleware) {
+		m.ttl = ttl
+	}
+}
+
+// Middleware creates Gin handlers caching responses.
+type Middleware struct {
+	cache   *httpcache.Middleware
+	options []httpcache.Option
+	keyFunc KeyFunc
+	ttl     time.Duration
+}
+
+// New caches responses in cache, which stays owned by the caller.
+func New(cache addcache.Cache, options ...Option) *Middleware {
+	m := &Middleware{}
+	for _, option := range options {
+		option(m)
+	}
+	m.cache = httpcache.New(cache, m.options...)
+	return m
+}
+
+// Handler caches responses without max-age for the WithTTL duration.
+func (m *Middleware) Handler() gin.HandlerFunc {
+	return m.TTL(m.ttl)
+}
+
+// TTL caches responses without max-age for ttl, for routes with their own TTL.
+func (m *Middleware) TTL(ttl time.Duration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := m.key(c)
+		if m.cache.ServeKey(c.Writer, c.Request, key) {
+			c.Abort()
+			return
+		}
+		original := c.Writer
+		recorder := m.cache.CaptureKey(original, c.Request, key)
+		c.Writer = &writer{ResponseWriter: original, recorder: recorder}
+		defer func() {
+			c.Writer = original
+		}()
+		c.Next()
+		recorder.FinishWithTTL(ttl)
+	}
+}
+
+func (m *Middleware) key(c *gin.Context) string {
+	if m.keyFunc != nil {
+		return m.keyFunc(c)
+	}
+	return m.cache.Key(c.Request)
+}
+
+// writer passes the response through the recorder.
+type writer struct {
+	gin.ResponseWriter
+	recorder *httpcache.Recorder
+}
+
+func (w *writer) WriteHeader(status int) {
+	w.recorder.WriteHeader(status)
+}
+
+func (w *writer) Write(p []byte) (int, error) {
+	return w.recorder.Write(p)
+}
+
+func (w *writer) WriteString(s string) (int, error) {
+	return w.recorder.Write([]byte(s))
+}
+
+func (w *writer) Flush() {
+	w.recorder.Flush()
+}
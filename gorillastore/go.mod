@@ -0,0 +1,11 @@
+module github.com/addit-digital/addcache/gorillastore
+
+go 1.25.0
+
+require (
+	github.com/addit-digital/addcache v0.0.0
+	github.com/gorilla/securecookie v1.1.2
+	github.com/gorilla/sessions v1.4.0
+)
+
+replace github.com/addit-digital/addcache => ../
@@ -0,0 +1,171 @@
+// Package gorillastore implements the gorilla/sessions Store on an addcache.Cache. The cookie
+// only carries the signed session id, the values are stored gob encoded in the cache and expire
+// with the MaxAge of the session:
+//
+//	store := gorillastore.New(cache, [][]byte{hashKey})
+//	session, _ := store.Get(r, "session")
+package gorillastore
+
+import (
+	"bytes"
+	"encoding/base32"
+	"encoding/gob"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/addit-digital/addcache"
+	"github.com/gorilla/securecookie"
+	"github.com/gorilla/sessions"
+)
+
+const (
+	defaultKeyPrefix = "session"
+	defaultMaxAge    = 86400 * 30
+)
+
+var base32RawStdEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// Option configures New.
+type Option func(s *Store)
+
+// WithKeyPrefix sets the prefix of the session keys, "session" by default.
+func WithKeyPrefix(prefix string) Option {
+	return func(s *Store) {
+		s.keyPrefix = prefix
+	}
+}
+
+// WithOptions sets the cookie options of new sessions, by default Path "/" and a MaxAge of 30 days.
+func WithOptions(options sessions.Options) Option {
+	return func(s *Store) {
+		s.Options = &options
+	}
+}
+
+// WithSlidingExpiration restarts the MaxAge of a session whenever it is loaded, not only when it is saved.
+func WithSlidingExpiration() Option {
+	return func(s *Store) {
+		s.sliding = true
+	}
+}
+
+// Store keeps sessions in a cache.
+type Store struct {
+	Codecs    []securecookie.Codec
+	Options   *sessions.Options
+	cache     addcache.Cache
+	keyPrefix string
+	sliding   bool
+}
+
+var _ sessions.Store = (*Store)(nil)
+
+// New stores sessions in cache, which stays owned by the caller. The key pairs sign and optionally
+// encrypt the session id cookie as in sessions.NewCookieStore, later pairs are used to rotate keys.
+func New(cache addcache.Cache, keyPairs [][]byte, options ...Option) *Store {
+	s := &Store{
+		Codecs:    securecookie.CodecsFromPairs(keyPairs...),
+		Options:   &sessions.Options{Path: "/", MaxAge: defaultMaxAge},
+		cache:     cache,
+		keyPrefix: defaultKeyPrefix,
+	}
+	for _, option := range options {
+		option(s)
+	}
+	s.MaxAge(s.Options.MaxAge)
+	return s
+}
+
+// Get returns the session of name after adding it to the registry of the request.
+func (s *Store) Get(r *http.Request, name string) (*sessions.Session, error) {
+	return sessions.GetRegistry(r).Get(s, name)
+}
+
+// New returns the session of name without adding it to the registry, a new session when the
+// request has none or its values expired.
+func (s *Store) New(r *http.Request, name string) (*sessions.Session, error) {
+	session := sessions.NewSession(s, name)
+	options := *s.Options
+	session.Options = &options
+	session.IsNew = true
+	cookie, err := r.Cookie(name)
+	if err != nil {
+		return session, nil
+	}
+	if err := securecookie.DecodeMulti(name, cookie.Value, &session.ID, s.Codecs...); err != nil {
+		return session, err
+	}
+	found, err := s.load(session)
+	if err != nil {
+		return session, err
+	}
+	session.IsNew = !found
+	return session, nil
+}
+
+// Save stores the session and sets its cookie, a MaxAge of zero or less deletes it.
+func (s *Store) Save(r *http.Request, w http.ResponseWriter, session *sessions.Session) error {
+	if session.Options.MaxAge <= 0 {
+		if session.ID != "" {
+			s.cache.Delete(s.key(session.ID))
+		}
+		http.SetCookie(w, sessions.NewCookie(session.Name(), "", session.Options))
+		return nil
+	}
+	if session.ID == "" {
+		session.ID = base32RawStdEncoding.EncodeToString(securecookie.GenerateRandomKey(32))
+	}
+	var raw bytes.Buffer
+	if err := gob.NewEncoder(&raw).Encode(session.Values); err != nil {
+		return err
+	}
+	s.cache.SetEx(s.key(session.ID), raw.Bytes(), maxAge(session))
+	encoded, err := securecookie.EncodeMulti(session.Name(), session.ID, s.Codecs...)
+	if err != nil {
+		return err
+	}
+	http.SetCookie(w, sessions.NewCookie(session.Name(), encoded, session.Options))
+	return nil
+}
+
+// MaxAge sets the MaxAge of new sessions and of the signed cookies.
+func (s *Store) MaxAge(age int) {
+	s.Options.MaxAge = age
+	for _, codec := range s.Codecs {
+		if cookie, ok := codec.(*securecookie.SecureCookie); ok {
+			cookie.MaxAge(age)
+		}
+	}
+}
+
+// load decodes the stored values into the session and reports whether they were found.
+func (s *Store) load(session *sessions.Session) (bool, error) {
+	key := s.key(session.ID)
+	data, err := s.cache.Get(key)
+	if errors.Is(err, addcache.ErrCacheKeyNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	raw, ok := data.([]byte)
+	if !ok {
+		return false, addcache.ErrTypeMismatch
+	}
+	if err := gob.NewDecoder(bytes.NewReader(raw)).Decode(&session.Values); err != nil {
+		return false, err
+	}
+	if s.sliding {
+		s.cache.SetEx(key, raw, maxAge(session))
+	}
+	return true, nil
+}
+
+func (s *Store) key(id string) string {
+	return s.keyPrefix + ":" + id
+}
+
+func maxAge(session *sessions.Session) time.Duration {
+	return time.Duration(session.Options.MaxAge) * time.Second
+}
@@ -0,0 +1,237 @@
+package grpcserver
+
+import (
+	"context"
+	"errors"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/addit-digital/addcache"
+	"github.com/addit-digital/addcache/grpcserver/cachepb"
+	"google.golang.org/grpc"
+)
+
+// ErrorHandler receives failed calls of methods without error result.
+type ErrorHandler func(operation string, key string, err error)
+
+// ClientOption configures NewClient.
+type ClientOption func(c *Client)
+
+// WithCodec encodes values other than strings, byte slices and integers, gob is the default.
+// Values are decoded into any, so gob needs concrete types registered with gob.Register.
+func WithCodec(codec addcache.Codec) ClientOption {
+	return func(c *Client) {
+		c.codec = codec
+	}
+}
+
+// WithTimeout sets the deadline of every call, 5 seconds by default and none for zero.
+func WithTimeout(timeout time.Duration) ClientOption {
+	return func(c *Client) {
+		c.timeout = timeout
+	}
+}
+
+// WithErrorHandler replaces the default handler, which logs failed calls.
+func WithErrorHandler(handler ErrorHandler) ClientOption {
+	return func(c *Client) {
+		if handler != nil {
+			c.onError = handler
+		}
+	}
+}
+
+// WithHookErrorHandler receives panics recovered from hooks, they are logged by default.
+func WithHookErrorHandler(handler addcache.HookErrorHandler) ClientOption {
+	return func(c *Client) {
+		c.hooks.ErrorHandler = handler
+	}
+}
+
+// Client is an addcache.Cache on a remote Server. Hooks are local to the process that registered them.
+type Client struct {
+	client  cachepb.CacheClient
+	codec   addcache.Codec
+	timeout time.Duration
+	onError ErrorHandler
+	hooks   addcache.Hooks
+}
+
+var _ addcache.Cache = (*Client)(nil)
+
+// NewClient calls the server on conn, which stays owned by the caller.
+func NewClient(conn grpc.ClientConnInterface, options ...ClientOption) *Client {
+	c := &Client{
+		client:  cachepb.NewCacheClient(conn),
+		codec:   addcache.GobCodec{},
+		timeout: 5 * time.Second,
+		onError: logError,
+	}
+	for _, option := range options {
+		option(c)
+	}
+	return c
+}
+
+func logError(operation string, key string, err error) {
+	log.Printf("addcache/grpcserver: %s for key %q failed: %v", operation, key, err)
+}
+
+// Set persists the data, or expires it after the default TTL of the server cache.
+func (c *Client) Set(key string, data any) {
+	c.write("Set", key, data, func(ctx context.Context, value *cachepb.Value) error {
+		_, err := c.client.Set(ctx, &cachepb.SetRequest{Key: key, Value: value})
+		return err
+	})
+}
+
+func (c *Client) SetPersistent(key string, data any) {
+	c.write("SetPersistent", key, data, func(ctx context.Context, value *cachepb.Value) error {
+		_, err := c.client.SetPersistent(ctx, &cachepb.SetRequest{Key: key, Value: value})
+		return err
+	})
+}
+
+func (c *Client) SetEx(key string, data any, duration time.Duration) {
+	c.write("SetEx", key, data, func(ctx context.Context, value *cachepb.Value) error {
+		_, err := c.client.SetEx(ctx, &cachepb.SetExRequest{Key: key, Value: value, TtlMillis: duration.Milliseconds()})
+		return err
+	})
+}
+
+func (c *Client) Get(key string) (any, error) {
+	ctx, cancel := c.context()
+	defer cancel()
+	response, err := c.client.Get(ctx, &cachepb.GetRequest{Key: key})
+	if err != nil {
+		return nil, fromStatus(err)
+	}
+	return fromValue(response.GetValue(), c.codec)
+}
+
+// GetMulti reads several keys in one call, missing keys are left out of the result.
+func (c *Client) GetMulti(keys ...string) (map[string]any, error) {
+	ctx, cancel := c.context()
+	defer cancel()
+	response, err := c.client.GetMulti(ctx, &cachepb.GetMultiRequest{Keys: keys})
+	if err != nil {
+		return nil, fromStatus(err)
+	}
+	values := make(map[string]any, len(response.GetValues()))
+	for key, value := range response.GetValues() {
+		data, err := fromValue(value, c.codec)
+		if err != nil {
+			return nil, err
+		}
+		values[key] = data
+	}
+	return values, nil
+}
+
+func (c *Client) Delete(key string) {
+	if _, err := c.GetAndDelete(key); err != nil && !errors.Is(err, addcache.ErrCacheKeyNotFound) {
+		c.onError("Delete", key, err)
+	}
+}
+
+func (c *Client) GetAndDelete(key string) (any, error) {
+	ctx, cancel := c.context()
+	defer cancel()
+	response, err := c.client.GetAndDelete(ctx, &cachepb.DeleteRequest{Key: key})
+	if err != nil {
+		return nil, fromStatus(err)
+	}
+	data, err := fromValue(response.GetValue(), c.codec)
+	if err != nil {
+		return nil, err
+	}
+	c.hooks.Run(addcache.DeleteOperation, key, data)
+	return data, nil
+}
+
+// GetAndSet stores newData like Set and returns the data it replaced.
+// ErrCacheKeyNotFound is returned when there was nothing to replace, newData is stored anyway.
+func (c *Client) GetAndSet(key string, newData any) (any, error) {
+	value, err := toValue(newData, c.codec)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := c.context()
+	defer cancel()
+	response, err := c.client.GetAndSet(ctx, &cachepb.SetRequest{Key: key, Value: value})
+	err = fromStatus(err)
+	if err != nil && !errors.Is(err, addcache.ErrCacheKeyNotFound) {
+		return nil, err
+	}
+	c.hooks.Run(addcache.CreateOperation, key, newData)
+	if err != nil {
+		return nil, err
+	}
+	return fromValue(response.GetValue(), c.codec)
+}
+
+func (c *Client) Increment(key string, delta int64) (int64, error) {
+	ctx, cancel := c.context()
+	defer cancel()
+	response, err := c.client.Increment(ctx, &cachepb.IncrementRequest{Key: key, Delta: delta})
+	if err != nil {
+		return 0, fromStatus(err)
+	}
+	c.hooks.Run(addcache.CreateOperation, key, response.GetValue())
+	return response.GetValue(), nil
+}
+
+func (c *Client) Decrement(key string, delta int64) (int64, error) {
+	return c.Increment(key, -delta)
+}
+
+func (c *Client) CreateKey(args ...string) string {
+	return c.CreateKeyWithDelimiter(":", args...)
+}
+
+func (c *Client) CreateKeyWithDelimiter(delimiter string, args ...string) string {
+	return strings.Join(args, delimiter)
+}
+
+// StopCleanup is a no-op, the server cleans up its cache.
+func (c *Client) StopCleanup() {}
+
+// Close is a no-op, the connection is owned by the caller.
+func (c *Client) Close() error {
+	return nil
+}
+
+func (c *Client) SetHook(operationType addcache.OperationType, handlerFunctions ...addcache.HandlerFunc) addcache.HookID {
+	return c.hooks.SetHook(operationType, handlerFunctions...)
+}
+
+func (c *Client) RemoveHook(operationType addcache.OperationType, id addcache.HookID) {
+	c.hooks.RemoveHook(operationType, id)
+}
+
+func (c *Client) ClearHooks(operationType addcache.OperationType) {
+	c.hooks.ClearHooks(operationType)
+}
+
+// write sends the data with call and runs the Create hooks.
+func (c *Client) write(operation string, key string, data any, call func(ctx context.Context, value *cachepb.Value) error) {
+	value, err := toValue(data, c.codec)
+	if err == nil {
+		ctx, cancel := c.context()
+		err = fromStatus(call(ctx, value))
+		cancel()
+	}
+	if err != nil {
+		c.onError(operation, key, err)
+		return
+	}
+	c.hooks.Run(addcache.CreateOperation, key, data)
+}
+
+func (c *Client) context() (context.Context, context.CancelFunc) {
+	if c.timeout > 0 {
+		return context.WithTimeout(context.Background(), c.timeout)
+	}
+	return context.Background(), func() {}
+}
@@ -0,0 +1,146 @@
+package grpcserver
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+
+	"github.com/addit-digital/addcache"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/proto"
+)
+
+const defaultResponseKeyPrefix = "grpc"
+
+// ResponseOption configures NewResponseCache.
+type ResponseOption func(rc *ResponseCache)
+
+// WithMethod caches the responses of the full method name, e.g. "/pkg.Service/Get", for ttl,
+// zero stores them like Set. Only methods listed this way are cached, they should be idempotent.
+func WithMethod(method string, ttl time.Duration) ResponseOption {
+	return func(rc *ResponseCache) {
+		rc.methods[method] = ttl
+	}
+}
+
+// WithInvalidation calls invalidate after every successful call of method, a mutating RPC
+// typically, to drop the cached responses it made stale with ResponseCache.Invalidate.
+func WithInvalidation(method string, invalidate func(rc *ResponseCache, request any)) ResponseOption {
+	return func(rc *ResponseCache) {
+		rc.invalidations[method] = append(rc.invalidations[method], invalidate)
+	}
+}
+
+// WithResponseKeyPrefix sets the prefix of the keys, "grpc" by default.
+func WithResponseKeyPrefix(prefix string) ResponseOption {
+	return func(rc *ResponseCache) {
+		rc.keyPrefix = prefix
+	}
+}
+
+// ResponseCache caches responses of unary RPCs in an addcache.Cache, keyed by method and a hash of
+// the deterministically marshaled request. Responses are stored marshaled as byte slices.
+type ResponseCache struct {
+	cache         addcache.Cache
+	methods       map[string]time.Duration
+	invalidations map[string][]func(rc *ResponseCache, request any)
+	keyPrefix     string
+}
+
+// NewResponseCache caches responses in cache, which stays owned by the caller.
+func NewResponseCache(cache addcache.Cache, options ...ResponseOption) *ResponseCache {
+	rc := &ResponseCache{
+		cache:         cache,
+		methods:       make(map[string]time.Duration),
+		invalidations: make(map[string][]func(rc *ResponseCache, request any)),
+		keyPrefix:     defaultResponseKeyPrefix,
+	}
+	for _, option := range options {
+		option(rc)
+	}
+	return rc
+}
+
+// UnaryClientInterceptor serves the methods of WithMethod from the cache and caches their
+// successful responses, other methods pass through and run their WithInvalidation functions.
+func (rc *ResponseCache) UnaryClientInterceptor() grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		ttl, cached := rc.methods[method]
+		if !cached {
+			if err := invoker(ctx, method, req, reply, cc, opts...); err != nil {
+				return err
+			}
+			for _, invalidate := range rc.invalidations[method] {
+				invalidate(rc, req)
+			}
+			return nil
+		}
+		key, err := rc.Key(method, req)
+		if err != nil {
+			return invoker(ctx, method, req, reply, cc, opts...)
+		}
+		if rc.load(key, reply) {
+			return nil
+		}
+		if err := invoker(ctx, method, req, reply, cc, opts...); err != nil {
+			return err
+		}
+		rc.store(key, reply, ttl)
+		return nil
+	}
+}
+
+// Key returns the cache key of a call of method with request.
+func (rc *ResponseCache) Key(method string, request any) (string, error) {
+	message, ok := request.(proto.Message)
+	if !ok {
+		return "", addcache.ErrTypeMismatch
+	}
+	raw, err := proto.MarshalOptions{Deterministic: true}.Marshal(message)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(raw)
+	return rc.keyPrefix + ":" + method + ":" + hex.EncodeToString(sum[:]), nil
+}
+
+// Invalidate drops the cached response of a call of method with request.
+func (rc *ResponseCache) Invalidate(method string, request any) {
+	if key, err := rc.Key(method, request); err == nil {
+		rc.cache.Delete(key)
+	}
+}
+
+// load unmarshals the cached response into reply and reports whether there was one.
+func (rc *ResponseCache) load(key string, reply any) bool {
+	message, ok := reply.(proto.Message)
+	if !ok {
+		return false
+	}
+	data, err := rc.cache.Get(key)
+	if err != nil {
+		return false
+	}
+	raw, ok := data.([]byte)
+	if !ok {
+		return false
+	}
+	return proto.Unmarshal(raw, message) == nil
+}
+
+func (rc *ResponseCache) store(key string, reply any, ttl time.Duration) {
+	message, ok := reply.(proto.Message)
+	if !ok {
+		return
+	}
+	raw, err := proto.Marshal(message)
+	if err != nil {
+		return
+	}
+	if ttl > 0 {
+		rc.cache.SetEx(key, raw, ttl)
+		return
+	}
+	rc.cache.Set(key, raw)
+}
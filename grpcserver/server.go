@@ -0,0 +1,144 @@
+// Package grpcserver serves an addcache.Cache over gRPC, e.g. as standalone cache daemon, and
+// provides the matching Client implementing addcache.Cache. The service is defined in
+// cachepb/cache.proto, run go generate in cachepb after changing it. ResponseCache is a client
+// interceptor caching the responses of other services.
+package grpcserver
+
+import (
+	"context"
+	"time"
+
+	"github.com/addit-digital/addcache"
+	"github.com/addit-digital/addcache/grpcserver/cachepb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+)
+
+// Server implements the cachepb.CacheServer on a cache, which stays owned by the caller.
+// Values set by clients are stored without decoding, values the application stored in the
+// cache itself are encoded with gob unless they are strings, byte slices or integers.
+type Server struct {
+	cachepb.UnimplementedCacheServer
+	cache addcache.Cache
+	codec addcache.Codec
+}
+
+var _ cachepb.CacheServer = (*Server)(nil)
+
+func NewServer(cache addcache.Cache) *Server {
+	return &Server{cache: cache, codec: addcache.GobCodec{}}
+}
+
+// Register serves cache on registrar, e.g. a *grpc.Server.
+func Register(registrar grpc.ServiceRegistrar, cache addcache.Cache) {
+	cachepb.RegisterCacheServer(registrar, NewServer(cache))
+}
+
+func (s *Server) Get(ctx context.Context, request *cachepb.GetRequest) (*cachepb.GetResponse, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, status.FromContextError(err).Err()
+	}
+	data, err := s.cache.Get(request.GetKey())
+	return s.response(data, err)
+}
+
+// GetMulti returns the keys found, it stops with the deadline of the call.
+func (s *Server) GetMulti(ctx context.Context, request *cachepb.GetMultiRequest) (*cachepb.GetMultiResponse, error) {
+	values := make(map[string]*cachepb.Value, len(request.GetKeys()))
+	for _, key := range request.GetKeys() {
+		if err := ctx.Err(); err != nil {
+			return nil, status.FromContextError(err).Err()
+		}
+		data, err := s.cache.Get(key)
+		if err != nil {
+			continue
+		}
+		value, err := toValue(data, s.codec)
+		if err != nil {
+			return nil, toStatus(err)
+		}
+		values[key] = value
+	}
+	return &cachepb.GetMultiResponse{Values: values}, nil
+}
+
+func (s *Server) Set(ctx context.Context, request *cachepb.SetRequest) (*cachepb.SetResponse, error) {
+	return s.write(ctx, request.GetValue(), func(data any) {
+		s.cache.Set(request.GetKey(), data)
+	})
+}
+
+func (s *Server) SetEx(ctx context.Context, request *cachepb.SetExRequest) (*cachepb.SetResponse, error) {
+	return s.write(ctx, request.GetValue(), func(data any) {
+		s.cache.SetEx(request.GetKey(), data, time.Duration(request.GetTtlMillis())*time.Millisecond)
+	})
+}
+
+func (s *Server) SetPersistent(ctx context.Context, request *cachepb.SetRequest) (*cachepb.SetResponse, error) {
+	return s.write(ctx, request.GetValue(), func(data any) {
+		s.cache.SetPersistent(request.GetKey(), data)
+	})
+}
+
+func (s *Server) Delete(ctx context.Context, request *cachepb.DeleteRequest) (*cachepb.DeleteResponse, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, status.FromContextError(err).Err()
+	}
+	s.cache.Delete(request.GetKey())
+	return &cachepb.DeleteResponse{}, nil
+}
+
+func (s *Server) GetAndDelete(ctx context.Context, request *cachepb.DeleteRequest) (*cachepb.GetResponse, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, status.FromContextError(err).Err()
+	}
+	data, err := s.cache.GetAndDelete(request.GetKey())
+	return s.response(data, err)
+}
+
+// GetAndSet stores the value and returns the replaced one, NOT_FOUND reports that nothing was replaced.
+func (s *Server) GetAndSet(ctx context.Context, request *cachepb.SetRequest) (*cachepb.GetResponse, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, status.FromContextError(err).Err()
+	}
+	data, err := fromValue(request.GetValue(), nil)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	previous, err := s.cache.GetAndSet(request.GetKey(), data)
+	return s.response(previous, err)
+}
+
+func (s *Server) Increment(ctx context.Context, request *cachepb.IncrementRequest) (*cachepb.IncrementResponse, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, status.FromContextError(err).Err()
+	}
+	value, err := s.cache.Increment(request.GetKey(), request.GetDelta())
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return &cachepb.IncrementResponse{Value: value}, nil
+}
+
+func (s *Server) write(ctx context.Context, value *cachepb.Value, store func(data any)) (*cachepb.SetResponse, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, status.FromContextError(err).Err()
+	}
+	data, err := fromValue(value, nil)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	store(data)
+	return &cachepb.SetResponse{}, nil
+}
+
+func (s *Server) response(data any, err error) (*cachepb.GetResponse, error) {
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	value, err := toValue(data, s.codec)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return &cachepb.GetResponse{Value: value}, nil
+}
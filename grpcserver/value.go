@@ -0,0 +1,116 @@
+package grpcserver
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/addit-digital/addcache"
+	"github.com/addit-digital/addcache/grpcserver/cachepb"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// encoded is a value the server received encoded by a client, it is stored and returned as is.
+type encoded []byte
+
+// toValue wraps data for the wire, values other than strings, byte slices and integers are encoded with codec.
+func toValue(data any, codec addcache.Codec) (*cachepb.Value, error) {
+	switch v := data.(type) {
+	case string:
+		return &cachepb.Value{Kind: &cachepb.Value_String_{String_: v}}, nil
+	case []byte:
+		return &cachepb.Value{Kind: &cachepb.Value_Bytes{Bytes: v}}, nil
+	case encoded:
+		return &cachepb.Value{Kind: &cachepb.Value_Encoded{Encoded: v}}, nil
+	case int:
+		return integer(int64(v)), nil
+	case int64:
+		return integer(v), nil
+	case int32:
+		return integer(int64(v)), nil
+	}
+	raw, err := codec.Marshal(&data)
+	if err != nil {
+		return nil, err
+	}
+	return &cachepb.Value{Kind: &cachepb.Value_Encoded{Encoded: raw}}, nil
+}
+
+func integer(v int64) *cachepb.Value {
+	return &cachepb.Value{Kind: &cachepb.Value_Integer{Integer: v}}
+}
+
+// fromValue unwraps a value, encoded values are decoded with codec or kept encoded without one.
+func fromValue(value *cachepb.Value, codec addcache.Codec) (any, error) {
+	switch kind := value.GetKind().(type) {
+	case *cachepb.Value_String_:
+		return kind.String_, nil
+	case *cachepb.Value_Bytes:
+		return kind.Bytes, nil
+	case *cachepb.Value_Integer:
+		return kind.Integer, nil
+	case *cachepb.Value_Encoded:
+		if codec == nil {
+			return encoded(kind.Encoded), nil
+		}
+		var data any
+		if err := codec.Unmarshal(kind.Encoded, &data); err != nil {
+			return nil, err
+		}
+		return data, nil
+	}
+	return nil, status.Error(codes.InvalidArgument, "value without kind")
+}
+
+// preconditions are the cache errors reported with FailedPrecondition, clients restore them from the message.
+var preconditions = []error{
+	addcache.ErrCacheValueNotInteger,
+	addcache.ErrTypeMismatch,
+	addcache.ErrReadOnly,
+	addcache.ErrCacheKeyRetained,
+}
+
+// toStatus maps cache errors to gRPC status codes.
+func toStatus(err error) error {
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, addcache.ErrCacheKeyNotFound):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, addcache.ErrCapacityExceeded):
+		return status.Error(codes.ResourceExhausted, err.Error())
+	case errors.Is(err, addcache.ErrTimeout):
+		return status.Error(codes.DeadlineExceeded, err.Error())
+	case errors.Is(err, addcache.ErrCacheClosed):
+		return status.Error(codes.Unavailable, err.Error())
+	}
+	for _, precondition := range preconditions {
+		if errors.Is(err, precondition) {
+			return status.Error(codes.FailedPrecondition, err.Error())
+		}
+	}
+	return status.Error(codes.Internal, err.Error())
+}
+
+// fromStatus maps status codes back to cache errors where the code identifies one.
+func fromStatus(err error) error {
+	s, ok := status.FromError(err)
+	if !ok {
+		return err
+	}
+	switch s.Code() {
+	case codes.NotFound:
+		return addcache.ErrCacheKeyNotFound
+	case codes.ResourceExhausted:
+		return addcache.ErrCapacityExceeded
+	case codes.DeadlineExceeded:
+		return addcache.ErrTimeout
+	case codes.FailedPrecondition:
+		for _, precondition := range preconditions {
+			if strings.HasPrefix(s.Message(), precondition.Error()) {
+				return precondition
+			}
+		}
+	}
+	return err
+}
@@ -0,0 +1,181 @@
+package addcache
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// maxRequestBody bounds the JSON body of a PUT to Handler.
+const maxRequestBody = 8 << 20
+
+// WithAdminToken sets the bearer token Handler requires, without it Handler refuses every request.
+func WithAdminToken(token string) Option {
+	return func(s *storage) {
+		s.adminToken = token
+	}
+}
+
+// keyInfo is the JSON document of an entry served by Handler.
+type keyInfo struct {
+	Key        string  `json:"key"`
+	Value      any     `json:"value"`
+	TTLSeconds float64 `json:"ttlSeconds,omitempty"`
+	Persistent bool    `json:"persistent"`
+	Version    uint64  `json:"version"`
+}
+
+// Handler serves an admin and data API for operators, requests need the WithAdminToken token
+// as "Authorization: Bearer <token>". Paths are relative, mount it with http.StripPrefix:
+//
+//	GET    /keys/{key}           the value with TTL as JSON
+//	PUT    /keys/{key}?ttl=30s   stores the JSON body of up to 8 MiB, like Set without ttl
+//	DELETE /keys/{key}           removes the key
+//	GET    /keys?prefix=         the keys starting with prefix
+//	GET    /stats                Stats
+//	POST   /flush?prefix=        deletes the keys starting with prefix, all without
+//	GET    /export               a snapshot of the live entries for ImportFrom
+func (s *storage) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !s.authorized(r) {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="addcache"`)
+			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+			return
+		}
+		path := "/" + strings.TrimPrefix(r.URL.EscapedPath(), "/")
+		switch {
+		case path == "/keys" && r.Method == http.MethodGet:
+			writeJSON(w, http.StatusOK, nonNil(s.Keys(r.URL.Query().Get("prefix"))))
+		case strings.HasPrefix(path, "/keys/"):
+			key, err := url.PathUnescape(strings.TrimPrefix(path, "/keys/"))
+			if err != nil || key == "" {
+				http.Error(w, "invalid key", http.StatusBadRequest)
+				return
+			}
+			s.serveKey(w, r, key)
+		case path == "/export" && r.Method == http.MethodGet:
+			if err := s.readable(); err != nil {
+				writeError(w, err)
+				return
+			}
+			w.Header().Set("Content-Type", "application/octet-stream")
+			if err := s.SaveTo(w); err != nil {
+				// the status went out with the first bytes, a broken connection tells the
+				// importer the snapshot is incomplete
+				panic(http.ErrAbortHandler)
+			}
+		case path == "/stats" && r.Method == http.MethodGet:
+			writeJSON(w, http.StatusOK, s.Stats())
+		case path == "/flush" && r.Method == http.MethodPost:
+			keys := s.Keys(r.URL.Query().Get("prefix"))
+			for _, key := range keys {
+				s.Delete(key)
+			}
+			writeJSON(w, http.StatusOK, map[string]int{"deleted": len(keys)})
+		default:
+			http.NotFound(w, r)
+		}
+	})
+}
+
+func (s *storage) authorized(r *http.Request) bool {
+	const scheme = "Bearer "
+	header := r.Header.Get("Authorization")
+	if s.adminToken == "" || !strings.HasPrefix(header, scheme) {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(header[len(scheme):]), []byte(s.adminToken)) == 1
+}
+
+func (s *storage) serveKey(w http.ResponseWriter, r *http.Request, key string) {
+	switch r.Method {
+	case http.MethodGet:
+		result, err := s.GetResult(key)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, keyInfo{
+			Key:        key,
+			Value:      result.Value,
+			TTLSeconds: result.TTL.Seconds(),
+			Persistent: result.Persistent,
+			Version:    result.Version,
+		})
+	case http.MethodPut:
+		var ttl time.Duration
+		if raw := r.URL.Query().Get("ttl"); raw != "" {
+			var err error
+			if ttl, err = time.ParseDuration(raw); err != nil || ttl <= 0 {
+				http.Error(w, "invalid ttl", http.StatusBadRequest)
+				return
+			}
+		}
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxRequestBody))
+		if err != nil {
+			http.Error(w, "body too large", http.StatusRequestEntityTooLarge)
+			return
+		}
+		var data any
+		if err = json.Unmarshal(body, &data); err != nil {
+			http.Error(w, "invalid JSON body", http.StatusBadRequest)
+			return
+		}
+		sd := s.newEntry(data, s.now())
+		if ttl > 0 {
+			sd = storageData{setTime: s.now(), expireDuration: s.jitter.apply(ttl), data: data}
+		}
+		if _, _, err = s.store(key, sd); err != nil {
+			writeError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	case http.MethodDelete:
+		if _, err := s.GetAndDelete(key); err != nil {
+			writeError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.Header().Set("Allow", "GET, PUT, DELETE")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+// writeError maps cache errors to status codes.
+func writeError(w http.ResponseWriter, err error) {
+	status := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, ErrCacheKeyNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, ErrReadOnly), errors.Is(err, ErrCacheKeyRetained):
+		status = http.StatusConflict
+	case errors.Is(err, ErrValueTooLarge):
+		status = http.StatusRequestEntityTooLarge
+	case errors.Is(err, ErrCapacityExceeded):
+		status = http.StatusInsufficientStorage
+	case errors.Is(err, ErrBackingStore):
+		status = http.StatusBadGateway
+	case errors.Is(err, ErrCacheClosed):
+		status = http.StatusServiceUnavailable
+	}
+	http.Error(w, err.Error(), status)
+}
+
+func nonNil(keys []string) []string {
+	if keys == nil {
+		return []string{}
+	}
+	return keys
+}
@@ -0,0 +1,267 @@
+package addcache
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"math/rand"
+)
+
+// Hash is the value of a hash key. It is immutable, writes replace it with a copy sharing all
+// nodes but the O(log n) ones on the paths of the written fields of its treap ordered by field.
+type Hash struct {
+	root *hNode
+}
+
+type hNode struct {
+	field       string
+	value       any
+	priority    uint32
+	size        int
+	left, right *hNode
+}
+
+func init() {
+	gob.Register(&Hash{})
+}
+
+// Len returns the number of fields.
+func (h *Hash) Len() int {
+	return h.root.count()
+}
+
+// Get returns the value of the field and whether the hash has it.
+func (h *Hash) Get(field string) (any, bool) {
+	node := h.root
+	for node != nil {
+		switch {
+		case field < node.field:
+			node = node.left
+		case field > node.field:
+			node = node.right
+		default:
+			return node.value, true
+		}
+	}
+	return nil, false
+}
+
+// Fields returns a copy of the fields with their values.
+func (h *Hash) Fields() map[string]any {
+	fields := make(map[string]any, h.Len())
+	h.root.walk(func(node *hNode) {
+		fields[node.field] = node.value
+	})
+	return fields
+}
+
+// with returns a copy of the hash with the field set to value.
+func (h *Hash) with(field string, value any) *Hash {
+	left, right := splitFields(h.without(field).root, field)
+	node := &hNode{field: field, value: value, priority: rand.Uint32(), size: 1}
+	return &Hash{root: mergeFields(mergeFields(left, node), right)}
+}
+
+// without returns a copy of the hash without the field.
+func (h *Hash) without(field string) *Hash {
+	if _, ok := h.Get(field); !ok {
+		return &Hash{root: h.root}
+	}
+	return &Hash{root: removeField(h.root, field)}
+}
+
+func (n *hNode) count() int {
+	if n == nil {
+		return 0
+	}
+	return n.size
+}
+
+func (n *hNode) walk(visit func(node *hNode)) {
+	if n == nil {
+		return
+	}
+	n.left.walk(visit)
+	visit(n)
+	n.right.walk(visit)
+}
+
+// withChildren copies the node with new children, nodes are never changed once in a tree.
+func (n *hNode) withChildren(left, right *hNode) *hNode {
+	node := *n
+	node.left, node.right = left, right
+	node.size = left.count() + right.count() + 1
+	return &node
+}
+
+// splitFields splits the tree into the fields ordered before field and the rest, copying the split path.
+func splitFields(n *hNode, field string) (*hNode, *hNode) {
+	if n == nil {
+		return nil, nil
+	}
+	if n.field < field {
+		left, right := splitFields(n.right, field)
+		return n.withChildren(n.left, left), right
+	}
+	left, right := splitFields(n.left, field)
+	return left, n.withChildren(right, n.right)
+}
+
+func mergeFields(left, right *hNode) *hNode {
+	switch {
+	case left == nil:
+		return right
+	case right == nil:
+		return left
+	case left.priority > right.priority:
+		return left.withChildren(left.left, mergeFields(left.right, right))
+	}
+	return right.withChildren(mergeFields(left, right.left), right.right)
+}
+
+// removeField drops the node of the field, the tree must contain it.
+func removeField(n *hNode, field string) *hNode {
+	switch {
+	case n == nil:
+		return nil
+	case field < n.field:
+		return n.withChildren(removeField(n.left, field), n.right)
+	case field > n.field:
+		return n.withChildren(n.left, removeField(n.right, field))
+	}
+	return mergeFields(n.left, n.right)
+}
+
+// GobEncode encodes the fields as a map for snapshots and WithSerialization.
+func (h *Hash) GobEncode() ([]byte, error) {
+	var buf bytes.Buffer
+	err := gob.NewEncoder(&buf).Encode(h.Fields())
+	return buf.Bytes(), err
+}
+
+func (h *Hash) GobDecode(raw []byte) error {
+	var fields map[string]any
+	if err := gob.NewDecoder(bytes.NewReader(raw)).Decode(&fields); err != nil {
+		return err
+	}
+	*h = *hashFrom(fields)
+	return nil
+}
+
+// MarshalJSON encodes the fields as an object for JSON snapshots.
+func (h *Hash) MarshalJSON() ([]byte, error) {
+	return json.Marshal(h.Fields())
+}
+
+func (h *Hash) UnmarshalJSON(raw []byte) error {
+	var fields map[string]any
+	if err := json.Unmarshal(raw, &fields); err != nil {
+		return err
+	}
+	*h = *hashFrom(fields)
+	return nil
+}
+
+func hashFrom(fields map[string]any) *Hash {
+	hash := &Hash{}
+	for field, value := range fields {
+		hash = hash.with(field, value)
+	}
+	return hash
+}
+
+// HSet sets fields of the hash stored under the key and returns how many fields were added.
+// Hashes are stored as *Hash, a missing key starts an empty hash stored like Set and the
+// expiration of an existing key is kept. ErrTypeMismatch is returned when the key holds another
+// value. A write copies O(log n) nodes per field, with WithSerialization the whole hash is still
+// encoded again.
+func (s *storage) HSet(key string, fieldValues map[string]any) (int, error) {
+	added := 0
+	err := s.update(key, func(current any, ok bool) (any, error) {
+		hash, err := hashOf(current, ok)
+		if err != nil {
+			return nil, err
+		}
+		for field, value := range fieldValues {
+			if _, exists := hash.Get(field); !exists {
+				added++
+			}
+			hash = hash.with(field, value)
+		}
+		return hash, nil
+	})
+	return added, err
+}
+
+// HGet returns the value of a field, ErrCacheKeyNotFound reports a missing key or field.
+func (s *storage) HGet(key string, field string) (any, error) {
+	hash, err := s.readHash(key)
+	if err != nil {
+		return nil, err
+	}
+	value, ok := hash.Get(field)
+	if !ok {
+		return nil, ErrCacheKeyNotFound
+	}
+	return value, nil
+}
+
+// HGetAll returns a copy of the fields of the hash stored under the key.
+func (s *storage) HGetAll(key string) (map[string]any, error) {
+	hash, err := s.readHash(key)
+	if err != nil {
+		return nil, err
+	}
+	return hash.Fields(), nil
+}
+
+// HDel removes fields of the hash and returns how many existed, the key is deleted with its last field.
+func (s *storage) HDel(key string, fields ...string) (int, error) {
+	removed := 0
+	err := s.update(key, func(current any, ok bool) (any, error) {
+		if !ok {
+			return nil, errUnchanged
+		}
+		hash, err := hashOf(current, ok)
+		if err != nil {
+			return nil, err
+		}
+		for _, field := range fields {
+			if _, exists := hash.Get(field); exists {
+				hash = hash.without(field)
+				removed++
+			}
+		}
+		if removed == 0 {
+			return nil, errUnchanged
+		}
+		if hash.Len() == 0 {
+			return nil, nil
+		}
+		return hash, nil
+	})
+	return removed, err
+}
+
+// readHash returns the hash stored under the key.
+func (s *storage) readHash(key string) (*Hash, error) {
+	data, err := s.Get(key)
+	if err != nil {
+		return nil, err
+	}
+	return hashOf(data, true)
+}
+
+// hashOf accepts the map[string]any hashes were stored as before *Hash, from older snapshots and logs.
+func hashOf(data any, ok bool) (*Hash, error) {
+	if !ok {
+		return &Hash{}, nil
+	}
+	switch hash := data.(type) {
+	case *Hash:
+		return hash, nil
+	case map[string]any:
+		return hashFrom(hash), nil
+	}
+	return nil, ErrTypeMismatch
+}
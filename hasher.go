@@ -0,0 +1,47 @@
+package addcache
+
+import (
+	"hash/fnv"
+	"hash/maphash"
+)
+
+// Hasher maps keys to 64 bit hashes for lock striping and worker selection.
+type Hasher interface {
+	Sum64(key string) uint64
+}
+
+// MaphashHasher hashes with hash/maphash using a random seed, hashes differ between processes.
+// This is the default, the seed makes collisions impossible to precompute from outside.
+type MaphashHasher struct {
+	seed maphash.Seed
+}
+
+func NewMaphashHasher() MaphashHasher {
+	return MaphashHasher{seed: maphash.MakeSeed()}
+}
+
+func (h MaphashHasher) Sum64(key string) uint64 {
+	var mh maphash.Hash
+	mh.SetSeed(h.seed)
+	_, _ = mh.WriteString(key)
+	return mh.Sum64()
+}
+
+// FNVHasher is the unkeyed 64 bit FNV-1a hash, stable across processes.
+// Colliding keys can be precomputed, avoid it when keys derive from user input.
+type FNVHasher struct{}
+
+func (FNVHasher) Sum64(key string) uint64 {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(key))
+	return h.Sum64()
+}
+
+// WithHasher replaces the default MaphashHasher.
+func WithHasher(hasher Hasher) Option {
+	return func(s *storage) {
+		if hasher != nil {
+			s.hasher = hasher
+		}
+	}
+}
@@ -0,0 +1,39 @@
+package addcache
+
+import (
+	"errors"
+	"log"
+)
+
+// EvictedOperation identifies OnEvicted callbacks when reporting hook panics.
+const EvictedOperation OperationType = "Evicted"
+
+// HookErrorHandler receives the value recovered from a panicking hook, or ErrHookQueueFull for
+// hooks WithHookWorkers dropped.
+type HookErrorHandler func(operationType OperationType, key string, recovered any)
+
+// WithHookErrorHandler replaces the default handler, which logs recovered hook panics and dropped hooks.
+func WithHookErrorHandler(handler HookErrorHandler) Option {
+	return func(s *storage) {
+		if handler != nil {
+			s.hookErr = handler
+		}
+	}
+}
+
+func logHookError(operationType OperationType, key string, recovered any) {
+	if err, ok := recovered.(error); ok && errors.Is(err, ErrHookQueueFull) {
+		log.Printf("addcache: %s hook for key %q dropped: %v", operationType, key, err)
+		return
+	}
+	log.Printf("addcache: %s hook for key %q panicked: %v", operationType, key, recovered)
+}
+
+func (s *storage) safeCall(operationType OperationType, key string, hook func()) {
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			s.hookErr(operationType, key, recovered)
+		}
+	}()
+	hook()
+}
@@ -0,0 +1,92 @@
+package addcache
+
+import (
+	"runtime"
+	"sync"
+)
+
+const hookQueueSize = 1024
+
+// hookPool runs hooks on a bounded set of goroutines.
+// Work for one key always lands on the same worker, so hooks of a key keep their order.
+type hookPool struct {
+	hasher Hasher
+	mu     sync.RWMutex
+	closed bool
+	queues []chan func()
+	wg     sync.WaitGroup
+}
+
+// WithHookWorkers runs hooks asynchronously on workers goroutines instead of the calling one.
+// The pool is capped at GOMAXPROCS so background work can not crowd out request goroutines,
+// zero or less selects a quarter of GOMAXPROCS. Each worker queues up to 1024 hook runs, those
+// arriving at a full queue are dropped and reported to the HookErrorHandler as ErrHookQueueFull.
+func WithHookWorkers(workers int) Option {
+	return func(s *storage) {
+		s.hookWorkers = &workers
+	}
+}
+
+func newHookPool(workers int, hasher Hasher) *hookPool {
+	procs := runtime.GOMAXPROCS(0)
+	if workers <= 0 {
+		workers = procs / 4
+	}
+	if workers > procs {
+		workers = procs
+	}
+	if workers < 1 {
+		workers = 1
+	}
+
+	p := &hookPool{
+		hasher: hasher,
+		queues: make([]chan func(), workers),
+	}
+	for i := range p.queues {
+		queue := make(chan func(), hookQueueSize)
+		p.queues[i] = queue
+		p.wg.Add(1)
+		go func() {
+			defer p.wg.Done()
+			for task := range queue {
+				task()
+			}
+		}()
+	}
+	return p
+}
+
+// dispatch queues the task on the worker owning the key, once stopped tasks run inline. It never
+// blocks, a hook writing to the cache would otherwise wait on its own full queue from the worker
+// that drains it. False reports a full queue, the task is dropped then.
+func (p *hookPool) dispatch(key string, task func()) bool {
+	p.mu.RLock()
+	if p.closed {
+		p.mu.RUnlock()
+		task()
+		return true
+	}
+	defer p.mu.RUnlock()
+	select {
+	case p.queues[p.hasher.Sum64(key)%uint64(len(p.queues))] <- task:
+		return true
+	default:
+		return false
+	}
+}
+
+// stop drains the queued hooks and waits for the workers to exit.
+func (p *hookPool) stop() {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return
+	}
+	p.closed = true
+	for _, queue := range p.queues {
+		close(queue)
+	}
+	p.mu.Unlock()
+	p.wg.Wait()
+}
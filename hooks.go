@@ -0,0 +1,108 @@
+package addcache
+
+import "sync"
+
+// Hooks is the hook registry of the in memory cache, exported for Cache implementations outside
+// this package. The zero value is ready to use and logs panicking handlers like the cache does.
+type Hooks struct {
+	// ErrorHandler receives panics recovered from handlers, nil logs them.
+	ErrorHandler HookErrorHandler
+
+	mu            sync.RWMutex
+	handlers      map[OperationType][]registeredHook
+	batchHandlers map[OperationType][]registeredBatchHook
+	lastID        HookID
+}
+
+// SetHook registers the handlers for the operation type and returns the id removing them again.
+func (h *Hooks) SetHook(operationType OperationType, handlerFunctions ...HandlerFunc) HookID {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.handlers == nil {
+		h.handlers = make(map[OperationType][]registeredHook)
+	}
+	h.lastID++
+	for _, handlerFunction := range handlerFunctions {
+		h.handlers[operationType] = append(h.handlers[operationType], registeredHook{id: h.lastID, handler: handlerFunction})
+	}
+	return h.lastID
+}
+
+// RemoveHook unregisters the handlers added by the SetHook or SetBatchHook call that returned id.
+func (h *Hooks) RemoveHook(operationType OperationType, id HookID) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if handlers, ok := h.handlers[operationType]; ok {
+		hooks := make([]registeredHook, 0, len(handlers))
+		for _, hook := range handlers {
+			if hook.id != id {
+				hooks = append(hooks, hook)
+			}
+		}
+		h.handlers[operationType] = hooks
+	}
+	if handlers, ok := h.batchHandlers[operationType]; ok {
+		hooks := make([]registeredBatchHook, 0, len(handlers))
+		for _, hook := range handlers {
+			if hook.id != id {
+				hooks = append(hooks, hook)
+			}
+		}
+		h.batchHandlers[operationType] = hooks
+	}
+}
+
+// ClearHooks unregisters all handlers and batch handlers of the operation type.
+func (h *Hooks) ClearHooks(operationType OperationType) {
+	h.mu.Lock()
+	delete(h.handlers, operationType)
+	delete(h.batchHandlers, operationType)
+	h.mu.Unlock()
+}
+
+// Run calls the handlers of the operation type in registration order on the calling goroutine.
+func (h *Hooks) Run(operationType OperationType, key string, data any) {
+	h.mu.RLock()
+	hooks := h.handlers[operationType]
+	h.mu.RUnlock()
+	for _, hook := range hooks {
+		h.safeCall(operationType, key, func() {
+			hook.handler(key, data)
+		})
+	}
+}
+
+func (h *Hooks) safeCall(operationType OperationType, key string, hook func()) {
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			if h.ErrorHandler != nil {
+				h.ErrorHandler(operationType, key, recovered)
+			} else {
+				logHookError(operationType, key, recovered)
+			}
+		}
+	}()
+	hook()
+}
+
+// registered counts the handlers and batch handlers of every operation type with any.
+func (h *Hooks) registered() map[OperationType][2]int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	counts := make(map[OperationType][2]int)
+	for operationType, hooks := range h.handlers {
+		if len(hooks) > 0 {
+			count := counts[operationType]
+			count[0] = len(hooks)
+			counts[operationType] = count
+		}
+	}
+	for operationType, hooks := range h.batchHandlers {
+		if len(hooks) > 0 {
+			count := counts[operationType]
+			count[1] = len(hooks)
+			counts[operationType] = count
+		}
+	}
+	return counts
+}
@@ -0,0 +1,103 @@
+package addcache
+
+import (
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	hotKeyBuckets    = 6
+	hotKeyMaxTracked = 4096
+)
+
+// KeyStat is the estimated number of reads of a key within the hot key window.
+type KeyStat struct {
+	Key   string
+	Reads int64
+}
+
+// hotKeyTracker counts sampled reads in buckets covering a sixth of the window each, the
+// window slides by dropping the oldest bucket.
+type hotKeyTracker struct {
+	sampleRate  float64
+	bucketWidth int64
+	mu          sync.Mutex
+	ids         [hotKeyBuckets]int64
+	counts      [hotKeyBuckets]map[string]int64
+}
+
+// WithHotKeys samples the given fraction of reads, hits and misses, to report the most read keys
+// of the last window with TopKeys. Each sixth of the window tracks up to 4096 distinct keys,
+// further keys are only counted once they were sampled before the bucket filled up.
+func WithHotKeys(sampleRate float64, window time.Duration) Option {
+	return func(s *storage) {
+		if sampleRate <= 0 || window <= 0 {
+			return
+		}
+		if sampleRate > 1 {
+			sampleRate = 1
+		}
+		bucketWidth := int64(window / hotKeyBuckets)
+		if bucketWidth < 1 {
+			bucketWidth = 1
+		}
+		s.hotKeys = &hotKeyTracker{sampleRate: sampleRate, bucketWidth: bucketWidth}
+	}
+}
+
+// observe counts a read of the key when it is sampled.
+func (t *hotKeyTracker) observe(key string, now time.Time) {
+	if t == nil || t.sampleRate < 1 && rand.Float64() >= t.sampleRate {
+		return
+	}
+	id := now.UnixNano() / t.bucketWidth
+	slot := id % hotKeyBuckets
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.ids[slot] != id || t.counts[slot] == nil {
+		t.ids[slot] = id
+		t.counts[slot] = make(map[string]int64)
+	}
+	counts := t.counts[slot]
+	if _, ok := counts[key]; ok || len(counts) < hotKeyMaxTracked {
+		counts[key]++
+	}
+}
+
+// TopKeys returns the n most read keys of the WithHotKeys window, most read first. Reads are
+// estimated from the sample. It returns nil without WithHotKeys.
+func (s *storage) TopKeys(n int) []KeyStat {
+	t := s.hotKeys
+	if t == nil || n <= 0 {
+		return nil
+	}
+	oldest := s.now().UnixNano()/t.bucketWidth - hotKeyBuckets
+	totals := make(map[string]int64)
+	t.mu.Lock()
+	for slot, counts := range t.counts {
+		if t.ids[slot] <= oldest {
+			continue
+		}
+		for key, count := range counts {
+			totals[key] += count
+		}
+	}
+	t.mu.Unlock()
+
+	stats := make([]KeyStat, 0, len(totals))
+	for key, count := range totals {
+		stats = append(stats, KeyStat{Key: key, Reads: int64(float64(count) / t.sampleRate)})
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		if stats[i].Reads != stats[j].Reads {
+			return stats[i].Reads > stats[j].Reads
+		}
+		return stats[i].Key < stats[j].Key
+	})
+	if len(stats) > n {
+		stats = stats[:n]
+	}
+	return stats
+}
@@ -0,0 +1,345 @@
+// Package httpcache caches HTTP responses in an addcache.Cache. Responses to GET and HEAD
+// requests are keyed by method, host and URL plus the configured Vary request headers and live
+// as long as their Cache-Control s-maxage or max-age allows. Responses to requests carrying
+// credentials are only stored when marked for shared caches. Served responses carry an
+// X-Cache header of HIT or MISS.
+//
+// Handler is the net/http middleware, ServeCached and Capture are its building blocks for
+// adapters to other frameworks.
+package httpcache
+
+import (
+	"bytes"
+	"encoding/gob"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/addit-digital/addcache"
+)
+
+const (
+	// HeaderXCache is set on cacheable responses to HIT or MISS.
+	HeaderXCache = "X-Cache"
+
+	defaultKeyPrefix   = "httpcache"
+	defaultMaxBodySize = 1 << 20
+	// maxDeltaSeconds caps max-age and s-maxage like RFC 9111 section 1.2.2 does for larger values.
+	maxDeltaSeconds = 1 << 31
+)
+
+// KeyFunc derives the cache key of a request.
+type KeyFunc func(r *http.Request) string
+
+// Option configures New.
+type Option func(m *Middleware)
+
+// WithVary adds the request headers to the key, for headers responses depend on such as Accept-Encoding.
+func WithVary(headers ...string) Option {
+	return func(m *Middleware) {
+		for _, header := range headers {
+			m.vary = append(m.vary, http.CanonicalHeaderKey(header))
+		}
+	}
+}
+
+// WithTTL caches responses without max-age for ttl, by default they are not cached.
+func WithTTL(ttl time.Duration) Option {
+	return func(m *Middleware) {
+		m.ttl = ttl
+	}
+}
+
+// WithKeyFunc replaces the key of method, host, URL and Vary headers.
+func WithKeyFunc(keyFunc KeyFunc) Option {
+	return func(m *Middleware) {
+		m.keyFunc = keyFunc
+	}
+}
+
+// WithKeyPrefix sets the prefix of the default keys, "httpcache" by default.
+func WithKeyPrefix(prefix string) Option {
+	return func(m *Middleware) {
+		m.keyPrefix = prefix
+	}
+}
+
+// WithMaxBodySize skips caching responses with larger bodies, 1 MiB by default.
+func WithMaxBodySize(size int) Option {
+	return func(m *Middleware) {
+		m.maxBodySize = size
+	}
+}
+
+// Response is a cached response, it is registered with gob for caches encoding values.
+type Response struct {
+	StatusCode int
+	Header     http.Header
+	Body       []byte
+}
+
+func init() {
+	gob.Register(Response{})
+}
+
+// Middleware caches responses of the handlers it wraps.
+type Middleware struct {
+	cache       addcache.Cache
+	vary        []string
+	ttl         time.Duration
+	keyFunc     KeyFunc
+	keyPrefix   string
+	maxBodySize int
+}
+
+// New caches responses in cache, which stays owned by the caller.
+func New(cache addcache.Cache, options ...Option) *Middleware {
+	m := &Middleware{
+		cache:       cache,
+		keyPrefix:   defaultKeyPrefix,
+		maxBodySize: defaultMaxBodySize,
+	}
+	for _, option := range options {
+		option(m)
+	}
+	return m
+}
+
+// Handler serves cached responses and caches the responses of next.
+func (m *Middleware) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if m.ServeCached(w, r) {
+			return
+		}
+		recorder := m.Capture(w, r)
+		next.ServeHTTP(recorder, r)
+		recorder.Finish()
+	})
+}
+
+// Key returns the cache key of r.
+func (m *Middleware) Key(r *http.Request) string {
+	if m.keyFunc != nil {
+		return m.keyFunc(r)
+	}
+	var key strings.Builder
+	key.WriteString(m.keyPrefix + ":" + r.Method + ":" + r.Host + r.URL.RequestURI())
+	for _, header := range m.vary {
+		key.WriteString("\n" + header + ":" + strings.Join(r.Header.Values(header), ","))
+	}
+	return key.String()
+}
+
+// cacheable reports whether responses to r may be served from and stored in the cache.
+func cacheable(r *http.Request) bool {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return false
+	}
+	directives := parseCacheControl(r.Header)
+	_, noStore := directives["no-store"]
+	return !noStore
+}
+
+// ServeCached writes the cached response of r to w and reports whether there was one.
+// Requests with Cache-Control no-cache skip the cache and refresh it.
+func (m *Middleware) ServeCached(w http.ResponseWriter, r *http.Request) bool {
+	return m.ServeKey(w, r, m.Key(r))
+}
+
+// ServeKey is ServeCached with the response cached under key.
+func (m *Middleware) ServeKey(w http.ResponseWriter, r *http.Request, key string) bool {
+	if !cacheable(r) {
+		return false
+	}
+	if _, noCache := parseCacheControl(r.Header)["no-cache"]; noCache {
+		return false
+	}
+	data, err := m.cache.Get(key)
+	if err != nil {
+		return false
+	}
+	response, ok := data.(Response)
+	if !ok {
+		return false
+	}
+	header := w.Header()
+	for name, values := range response.Header {
+		header[name] = append([]string(nil), values...)
+	}
+	header.Set(HeaderXCache, "HIT")
+	w.WriteHeader(response.StatusCode)
+	if r.Method != http.MethodHead {
+		_, _ = w.Write(response.Body)
+	}
+	return true
+}
+
+// Capture wraps w to record the response to r, Finish stores it when it is cacheable.
+func (m *Middleware) Capture(w http.ResponseWriter, r *http.Request) *Recorder {
+	return m.CaptureKey(w, r, m.Key(r))
+}
+
+// CaptureKey is Capture with the response stored under key.
+func (m *Middleware) CaptureKey(w http.ResponseWriter, r *http.Request, key string) *Recorder {
+	return &Recorder{ResponseWriter: w, middleware: m, key: key, enabled: cacheable(r), credentials: hasCredentials(r)}
+}
+
+// hasCredentials reports requests whose responses may be personal, see sharedForCredentials.
+func hasCredentials(r *http.Request) bool {
+	return r.Header.Get("Authorization") != "" || r.Header.Get("Cookie") != ""
+}
+
+// sharedForCredentials reports whether a response to a request with Authorization or Cookie may
+// be stored for everyone requesting the URL, which RFC 9111 section 3.5 allows for responses
+// marked public, s-maxage or must-revalidate.
+func sharedForCredentials(header http.Header) bool {
+	directives := parseCacheControl(header)
+	for _, shared := range []string{"public", "s-maxage", "must-revalidate"} {
+		if _, ok := directives[shared]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Recorder passes a response through and records it for the cache.
+type Recorder struct {
+	http.ResponseWriter
+	middleware  *Middleware
+	key         string
+	enabled     bool
+	credentials bool
+	wroteHeader bool
+	status      int
+	header      http.Header
+	body        bytes.Buffer
+}
+
+func (rec *Recorder) WriteHeader(status int) {
+	if rec.wroteHeader {
+		return
+	}
+	rec.wroteHeader = true
+	rec.status = status
+	if rec.enabled {
+		rec.ResponseWriter.Header().Set(HeaderXCache, "MISS")
+	}
+	rec.ResponseWriter.WriteHeader(status)
+}
+
+func (rec *Recorder) Write(p []byte) (int, error) {
+	if !rec.wroteHeader {
+		rec.WriteHeader(http.StatusOK)
+	}
+	// frameworks buffering the status set headers until the first write
+	if rec.header == nil {
+		rec.header = rec.ResponseWriter.Header().Clone()
+	}
+	if rec.enabled {
+		if rec.body.Len()+len(p) > rec.middleware.maxBodySize {
+			rec.enabled = false
+			rec.body = bytes.Buffer{}
+		} else {
+			rec.body.Write(p)
+		}
+	}
+	return rec.ResponseWriter.Write(p)
+}
+
+// Flush passes flushes through when the wrapped writer supports them.
+func (rec *Recorder) Flush() {
+	if flusher, ok := rec.ResponseWriter.(http.Flusher); ok {
+		if !rec.wroteHeader {
+			rec.WriteHeader(http.StatusOK)
+		}
+		flusher.Flush()
+	}
+}
+
+// Unwrap returns the wrapped writer for http.ResponseController.
+func (rec *Recorder) Unwrap() http.ResponseWriter {
+	return rec.ResponseWriter
+}
+
+// Finish stores the recorded response, unless the request, status or headers forbid caching.
+// Responses to requests with Authorization or Cookie headers are only stored when marked public,
+// s-maxage or must-revalidate, as they may be personal.
+func (rec *Recorder) Finish() {
+	rec.FinishWithTTL(rec.middleware.ttl)
+}
+
+// FinishWithTTL is Finish with fallback as TTL for responses without max-age.
+func (rec *Recorder) FinishWithTTL(fallback time.Duration) {
+	if !rec.enabled || !rec.wroteHeader || !cacheableStatus(rec.status) {
+		return
+	}
+	if rec.header == nil {
+		rec.header = rec.ResponseWriter.Header().Clone()
+	}
+	if rec.credentials && !sharedForCredentials(rec.header) {
+		return
+	}
+	ttl, ok := TTL(rec.header, fallback)
+	if !ok {
+		return
+	}
+	header := rec.header.Clone()
+	header.Del(HeaderXCache)
+	rec.middleware.cache.SetEx(rec.key, Response{
+		StatusCode: rec.status,
+		Header:     header,
+		Body:       append([]byte(nil), rec.body.Bytes()...),
+	}, ttl)
+}
+
+func cacheableStatus(status int) bool {
+	switch status {
+	case http.StatusOK, http.StatusNonAuthoritativeInfo, http.StatusNoContent,
+		http.StatusMovedPermanently, http.StatusNotFound, http.StatusGone:
+		return true
+	}
+	return false
+}
+
+// TTL returns how long a response with header may be cached: its s-maxage or max-age, or
+// fallback without either. Responses marked no-store, no-cache or private, setting cookies or
+// varying on any header are not cacheable.
+func TTL(header http.Header, fallback time.Duration) (time.Duration, bool) {
+	if header.Get("Set-Cookie") != "" || header.Get("Vary") == "*" {
+		return 0, false
+	}
+	directives := parseCacheControl(header)
+	for _, forbidden := range []string{"no-store", "no-cache", "private"} {
+		if _, ok := directives[forbidden]; ok {
+			return 0, false
+		}
+	}
+	for _, directive := range []string{"s-maxage", "max-age"} {
+		if value, ok := directives[directive]; ok {
+			seconds, err := strconv.ParseInt(value, 10, 64)
+			if err != nil || seconds <= 0 {
+				return 0, false
+			}
+			if seconds > maxDeltaSeconds {
+				seconds = maxDeltaSeconds
+			}
+			return time.Duration(seconds) * time.Second, true
+		}
+	}
+	return fallback, fallback > 0
+}
+
+// parseCacheControl returns the lower cased directives of the Cache-Control header with their values.
+func parseCacheControl(header http.Header) map[string]string {
+	directives := make(map[string]string)
+	for _, line := range header.Values("Cache-Control") {
+		for _, part := range strings.Split(line, ",") {
+			name, value, _ := strings.Cut(strings.TrimSpace(part), "=")
+			if name != "" {
+				directives[strings.ToLower(name)] = strings.Trim(value, `"`)
+			}
+		}
+	}
+	return directives
+}
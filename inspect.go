@@ -0,0 +1,60 @@
+package addcache
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// EntryInfo is the metadata of an entry as reported by Inspect.
+// Created is the first write of the key, Updated its last one. LastAccess is zero until the
+// first hit. TTL is the remaining lifetime, zero for persistent and expired entries. Expired
+// entries are still reported until the cleanup removes them, Held ones are kept by a hold.
+type EntryInfo struct {
+	Key        string
+	Created    time.Time
+	Updated    time.Time
+	LastAccess time.Time
+	Hits       int64
+	TTL        time.Duration
+	Persistent bool
+	Expired    bool
+	Held       bool
+	Version    uint64
+	Size       int
+}
+
+// Inspect reports the metadata of the entry under key without counting as a read.
+// Missing keys fail with ErrCacheKeyNotFound.
+func (s *storage) Inspect(key string) (EntryInfo, error) {
+	if err := s.readable(); err != nil {
+		return EntryInfo{}, err
+	}
+	now := s.now()
+	s.mu.RLock()
+	sd, ok := s.data[key]
+	expired := ok && s.expiredLocked(key, sd, now)
+	s.mu.RUnlock()
+	if !ok {
+		return EntryInfo{}, ErrCacheKeyNotFound
+	}
+	info := EntryInfo{
+		Key:        key,
+		Updated:    sd.setTime,
+		Persistent: sd.isPersistence,
+		Expired:    expired,
+		Held:       sd.held,
+		Version:    sd.version,
+		Size:       s.sizeOf(key, sd.data),
+	}
+	if sd.meta != nil {
+		info.Created = sd.meta.created
+		info.Hits = atomic.LoadInt64(&sd.meta.hits)
+		if lastAccess := atomic.LoadInt64(&sd.meta.lastAccess); lastAccess != 0 {
+			info.LastAccess = time.Unix(0, lastAccess)
+		}
+	}
+	if remaining := sd.setTime.Add(sd.expireDuration).Sub(now); !sd.isPersistence && !expired && remaining > 0 {
+		info.TTL = remaining
+	}
+	return info, nil
+}
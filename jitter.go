@@ -0,0 +1,41 @@
+package addcache
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+)
+
+type ttlJitter struct {
+	fraction float64
+	mu       sync.Mutex
+	random   *rand.Rand
+}
+
+// WithTTLJitter spreads expirations by randomizing every TTL by up to ±fraction of its duration,
+// so entries written together do not all expire in the same cleanup tick.
+func WithTTLJitter(fraction float64) Option {
+	return func(s *storage) {
+		if fraction <= 0 {
+			s.jitter = nil
+			return
+		}
+		if fraction > 1 {
+			fraction = 1
+		}
+		s.jitter = &ttlJitter{
+			fraction: fraction,
+			random:   rand.New(rand.NewSource(time.Now().UnixNano())),
+		}
+	}
+}
+
+func (j *ttlJitter) apply(duration time.Duration) time.Duration {
+	if j == nil || duration <= 0 {
+		return duration
+	}
+	j.mu.Lock()
+	offset := (j.random.Float64()*2 - 1) * j.fraction
+	j.mu.Unlock()
+	return duration + time.Duration(float64(duration)*offset)
+}
@@ -0,0 +1,121 @@
+// Package kafkaconsumer keeps an addcache.Cache warm from a Kafka topic of change events, e.g.
+// a CDC stream: every message is mapped to upserts and deletions which are applied to the cache.
+package kafkaconsumer
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/addit-digital/addcache"
+	"github.com/segmentio/kafka-go"
+)
+
+// Change is one cache modification derived from a message.
+type Change struct {
+	Key string
+	// Value is stored unless Delete is set.
+	Value any
+	// Delete removes the key instead of storing Value.
+	Delete bool
+	// TTL expires the stored value, zero stores it like Set.
+	TTL time.Duration
+	// Persistent stores the value without expiration regardless of the default TTL.
+	Persistent bool
+}
+
+// Mapper derives the changes of a message, none to ignore it.
+type Mapper func(message kafka.Message) ([]Change, error)
+
+// Reader is the part of *kafka.Reader the consumer uses. The reader has to belong to a consumer
+// group for offsets to be committed.
+type Reader interface {
+	FetchMessage(ctx context.Context) (kafka.Message, error)
+	CommitMessages(ctx context.Context, messages ...kafka.Message) error
+}
+
+var _ Reader = (*kafka.Reader)(nil)
+
+// ErrorHandler receives messages the mapper failed on, they are skipped and committed.
+type ErrorHandler func(message kafka.Message, err error)
+
+// Option configures New.
+type Option func(c *Consumer)
+
+// WithErrorHandler replaces the default handler, which logs mapping errors.
+func WithErrorHandler(handler ErrorHandler) Option {
+	return func(c *Consumer) {
+		if handler != nil {
+			c.onError = handler
+		}
+	}
+}
+
+// Consumer applies the change events of a topic to a cache.
+type Consumer struct {
+	cache   addcache.Cache
+	reader  Reader
+	mapper  Mapper
+	onError ErrorHandler
+}
+
+// New consumes reader into cache, both stay owned by the caller.
+func New(cache addcache.Cache, reader Reader, mapper Mapper, options ...Option) *Consumer {
+	c := &Consumer{
+		cache:   cache,
+		reader:  reader,
+		mapper:  mapper,
+		onError: logError,
+	}
+	for _, option := range options {
+		option(c)
+	}
+	return c
+}
+
+func logError(message kafka.Message, err error) {
+	log.Printf("addcache/kafkaconsumer: mapping message %s/%d@%d failed: %v", message.Topic, message.Partition, message.Offset, err)
+}
+
+// Run applies messages until ctx is done, a message is committed after its changes were applied.
+// It returns nil once ctx is done and the error of the reader otherwise.
+func (c *Consumer) Run(ctx context.Context) error {
+	for {
+		message, err := c.reader.FetchMessage(ctx)
+		if err != nil {
+			if ctx.Err() != nil && errors.Is(err, ctx.Err()) {
+				return nil
+			}
+			return err
+		}
+		c.Apply(message)
+		if err := c.reader.CommitMessages(ctx, message); err != nil {
+			if ctx.Err() != nil && errors.Is(err, ctx.Err()) {
+				return nil
+			}
+			return err
+		}
+	}
+}
+
+// Apply maps a single message and applies its changes, e.g. for readers driven by the caller.
+func (c *Consumer) Apply(message kafka.Message) {
+	changes, err := c.mapper(message)
+	if err != nil {
+		c.onError(message, err)
+		return
+	}
+	for _, change := range changes {
+		switch {
+		case change.Delete:
+			c.cache.Delete(change.Key)
+		case change.Persistent:
+			c.cache.SetPersistent(change.Key, change.Value)
+		case change.TTL > 0:
+			c.cache.SetEx(change.Key, change.Value, change.TTL)
+		default:
+			c.cache.Set(change.Key, change.Value)
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package addcache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// keyHashLength is the number of hex digits of the hash replacing the overflow of a long key.
+const keyHashLength = 16
+
+// KeyBuilder builds keys from typed segments. Unlike CreateKey it escapes the delimiter and '%'
+// inside segments as %XX, so "a:b" and "a", "b" yield different keys. A KeyBuilder is an
+// immutable value, every method returns a new one, so a builder holding a prefix can be reused:
+//
+//	users := addcache.NewKeyBuilder("user")
+//	key := users.ID(12).Str("profile").String() // "user:12:profile"
+type KeyBuilder struct {
+	delimiter string
+	maxLength int
+	segments  []string
+}
+
+// NewKeyBuilder starts a key with the prefix segments, joined with ":".
+func NewKeyBuilder(prefix ...string) KeyBuilder {
+	return KeyBuilder{delimiter: defaultDelimiter, segments: prefix}
+}
+
+// Delimiter joins the segments with delimiter instead of ":".
+func (b KeyBuilder) Delimiter(delimiter string) KeyBuilder {
+	b.delimiter = delimiter
+	return b
+}
+
+// MaxLength limits the key to n bytes, longer keys are cut and end in a hash of the whole key,
+// so they stay distinct and keep their leading segments for prefix based features. Keys are cut
+// between characters and outside of escapes. Limits too short for a segment besides the hash
+// yield the hash alone, shortened to n. Zero disables the limit.
+func (b KeyBuilder) MaxLength(n int) KeyBuilder {
+	b.maxLength = n
+	return b
+}
+
+// Str appends a string segment.
+func (b KeyBuilder) Str(segment string) KeyBuilder {
+	return b.append(segment)
+}
+
+// ID appends an integer segment.
+func (b KeyBuilder) ID(id int64) KeyBuilder {
+	return b.append(strconv.FormatInt(id, 10))
+}
+
+// UUID appends a UUID in its canonical form, uuid.UUID of github.com/google/uuid converts to [16]byte.
+func (b KeyBuilder) UUID(id [16]byte) KeyBuilder {
+	return b.append(fmt.Sprintf("%x-%x-%x-%x-%x", id[0:4], id[4:6], id[6:8], id[8:10], id[10:16]))
+}
+
+// Time appends the start of the bucket holding t as Unix seconds, e.g. an hour bucket makes all
+// keys built within the same hour equal.
+func (b KeyBuilder) Time(t time.Time, bucket time.Duration) KeyBuilder {
+	if bucket > 0 {
+		t = t.Truncate(bucket)
+	}
+	return b.append(strconv.FormatInt(t.Unix(), 10))
+}
+
+// String returns the key.
+func (b KeyBuilder) String() string {
+	escaped := make([]string, len(b.segments))
+	for i, segment := range b.segments {
+		escaped[i] = b.escape(segment)
+	}
+	key := strings.Join(escaped, b.delimiter)
+	if b.maxLength <= 0 || len(key) <= b.maxLength {
+		return key
+	}
+	sum := sha256.Sum256([]byte(key))
+	hash := hex.EncodeToString(sum[:])[:keyHashLength]
+	keep := b.maxLength - len(b.delimiter) - len(hash)
+	for keep > 0 && !utf8.RuneStart(key[keep]) {
+		keep--
+	}
+	if keep > 0 && key[keep-1] == '%' {
+		keep--
+	} else if keep > 1 && key[keep-2] == '%' {
+		keep -= 2
+	}
+	if keep <= 0 {
+		return hash[:minInt(len(hash), b.maxLength)]
+	}
+	return key[:keep] + b.delimiter + hash
+}
+
+// append returns a builder with the segment added, never sharing the array of b.
+func (b KeyBuilder) append(segment string) KeyBuilder {
+	segments := make([]string, len(b.segments), len(b.segments)+1)
+	copy(segments, b.segments)
+	b.segments = append(segments, segment)
+	return b
+}
+
+func (b KeyBuilder) escape(segment string) string {
+	if !strings.Contains(segment, "%") && (b.delimiter == "" || !strings.Contains(segment, b.delimiter)) {
+		return segment
+	}
+	segment = strings.ReplaceAll(segment, "%", "%25")
+	if b.delimiter == "" {
+		return segment
+	}
+	var escaped strings.Builder
+	for i := 0; i < len(b.delimiter); i++ {
+		fmt.Fprintf(&escaped, "%%%02X", b.delimiter[i])
+	}
+	return strings.ReplaceAll(segment, b.delimiter, escaped.String())
+}
@@ -0,0 +1,112 @@
+package addcache
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const defaultKeyLockStripes = 256
+
+// keyLocks is a striped lock table, keys hashing to the same stripe share a mutex. Waits for a
+// stripe held for another key are counted as collisions, many of them hint at keys crafted to
+// collide under an unkeyed hasher, see Stats.StripeCollisions.
+type keyLocks struct {
+	collisions int64
+	hasher     Hasher
+	stripes    []keyStripe
+}
+
+// keyStripe remembers the hash of the key holding it, it is read racily to tell collisions
+// from waits for the same key.
+type keyStripe struct {
+	mu     sync.Mutex
+	holder uint64
+}
+
+func newKeyLocks(stripes int, hasher Hasher) *keyLocks {
+	return &keyLocks{
+		hasher:  hasher,
+		stripes: make([]keyStripe, stripes),
+	}
+}
+
+func (kl *keyLocks) lock(key string) func() {
+	hash := kl.hasher.Sum64(key)
+	stripe := &kl.stripes[hash%uint64(len(kl.stripes))]
+	if !stripe.mu.TryLock() {
+		if atomic.LoadUint64(&stripe.holder) != hash {
+			atomic.AddInt64(&kl.collisions, 1)
+		}
+		stripe.mu.Lock()
+	}
+	atomic.StoreUint64(&stripe.holder, hash)
+	return stripe.mu.Unlock
+}
+
+// LockKey serializes work on the key across goroutines and returns the function releasing it.
+// Locks are striped, so holding one key lock while acquiring another may deadlock.
+func (s *storage) LockKey(key string) (unlock func()) {
+	return s.keyLocks.lock(key)
+}
+
+// WithComputeTimeout makes GetOrCompute return ErrTimeout when compute runs longer than timeout.
+// The computation carries on in the background and its result is still stored.
+func WithComputeTimeout(timeout time.Duration) Option {
+	return func(s *storage) {
+		s.computeTimeout = timeout
+	}
+}
+
+// GetOrCompute returns the cached data or stores the result of compute under the key.
+// Concurrent callers for the same key wait for a single compute call,
+// a duration of zero or less persists the result.
+func (s *storage) GetOrCompute(key string, duration time.Duration, compute func() (any, error)) (any, error) {
+	data, err := s.Get(key)
+	if err == nil || errors.Is(err, ErrCacheClosed) {
+		return data, err
+	}
+
+	unlock := s.LockKey(key)
+	if data, err := s.Get(key); err == nil {
+		unlock()
+		return data, nil
+	}
+	if s.computeTimeout <= 0 || !backgroundTasks {
+		defer unlock()
+		return s.computeAndStore(key, duration, compute)
+	}
+
+	type result struct {
+		data any
+		err  error
+	}
+	done := make(chan result, 1)
+	go func() {
+		defer unlock()
+		data, err := s.computeAndStore(key, duration, compute)
+		done <- result{data: data, err: err}
+	}()
+	timer := time.NewTimer(s.computeTimeout)
+	defer timer.Stop()
+	select {
+	case r := <-done:
+		return r.data, r.err
+	case <-timer.C:
+		return nil, ErrTimeout
+	}
+}
+
+func (s *storage) computeAndStore(key string, duration time.Duration, compute func() (any, error)) (any, error) {
+	data, err := compute()
+	if err != nil {
+		return nil, err
+	}
+	if duration > 0 {
+		s.SetEx(key, data, duration)
+	} else {
+		s.Set(key, data)
+	}
+	return data, nil
+}
@@ -0,0 +1,24 @@
+package addcache
+
+import (
+	"sort"
+	"strings"
+)
+
+// Keys returns the sorted keys of the live entries starting with prefix, all for an empty prefix.
+func (s *storage) Keys(prefix string) []string {
+	if s.readable() != nil {
+		return nil
+	}
+	now := s.now()
+	s.mu.RLock()
+	var keys []string
+	for key, sd := range s.data {
+		if strings.HasPrefix(key, prefix) && !s.expiredLocked(key, sd, now) {
+			keys = append(keys, key)
+		}
+	}
+	s.mu.RUnlock()
+	sort.Strings(keys)
+	return keys
+}
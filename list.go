@@ -0,0 +1,435 @@
+package addcache
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"math/rand"
+	"time"
+)
+
+// List is the value of a list key. It is immutable, pushes and pops replace it with a copy
+// sharing all nodes but the O(log n) ones on the modified paths of its treap ordered by position.
+type List struct {
+	root *lNode
+}
+
+type lNode struct {
+	value       any
+	priority    uint32
+	size        int
+	left, right *lNode
+}
+
+func init() {
+	gob.Register(&List{})
+}
+
+// Len returns the number of elements.
+func (l *List) Len() int {
+	return l.root.count()
+}
+
+// Index returns the element at the zero based position and whether there is one.
+func (l *List) Index(i int) (any, bool) {
+	if i < 0 || i >= l.Len() {
+		return nil, false
+	}
+	node := l.root
+	for {
+		switch left := node.left.count(); {
+		case i < left:
+			node = node.left
+		case i > left:
+			i -= left + 1
+			node = node.right
+		default:
+			return node.value, true
+		}
+	}
+}
+
+// Range returns a copy of the elements from start to stop inclusive, negative indexes count from
+// the end like in Redis.
+func (l *List) Range(start, stop int) []any {
+	start, stop = listBounds(l.Len(), start, stop)
+	if start > stop {
+		return nil
+	}
+	values := make([]any, 0, stop-start+1)
+	l.slice(start, stop+1).root.walk(func(value any) {
+		values = append(values, value)
+	})
+	return values
+}
+
+// Values returns a copy of all elements.
+func (l *List) Values() []any {
+	return l.Range(0, -1)
+}
+
+func (l *List) pushFront(value any) *List {
+	return &List{root: mergeElements(newElement(value), l.root)}
+}
+
+func (l *List) pushBack(value any) *List {
+	return &List{root: mergeElements(l.root, newElement(value))}
+}
+
+// slice returns a copy of the list holding the elements from start up to end exclusive.
+func (l *List) slice(start, end int) *List {
+	rest, _ := splitElements(l.root, end)
+	_, kept := splitElements(rest, start)
+	return &List{root: kept}
+}
+
+func newElement(value any) *lNode {
+	return &lNode{value: value, priority: rand.Uint32(), size: 1}
+}
+
+func (n *lNode) count() int {
+	if n == nil {
+		return 0
+	}
+	return n.size
+}
+
+func (n *lNode) walk(visit func(value any)) {
+	if n == nil {
+		return
+	}
+	n.left.walk(visit)
+	visit(n.value)
+	n.right.walk(visit)
+}
+
+// withChildren copies the node with new children, nodes are never changed once in a tree.
+func (n *lNode) withChildren(left, right *lNode) *lNode {
+	node := *n
+	node.left, node.right = left, right
+	node.size = left.count() + right.count() + 1
+	return &node
+}
+
+// splitElements splits the tree into its first count elements and the rest, copying the split path.
+func splitElements(n *lNode, count int) (*lNode, *lNode) {
+	if n == nil {
+		return nil, nil
+	}
+	if left := n.left.count(); left < count {
+		first, rest := splitElements(n.right, count-left-1)
+		return n.withChildren(n.left, first), rest
+	}
+	first, rest := splitElements(n.left, count)
+	return first, n.withChildren(rest, n.right)
+}
+
+func mergeElements(left, right *lNode) *lNode {
+	switch {
+	case left == nil:
+		return right
+	case right == nil:
+		return left
+	case left.priority > right.priority:
+		return left.withChildren(left.left, mergeElements(left.right, right))
+	}
+	return right.withChildren(mergeElements(left, right.left), right.right)
+}
+
+// GobEncode encodes the elements in order for snapshots and WithSerialization.
+func (l *List) GobEncode() ([]byte, error) {
+	var buf bytes.Buffer
+	err := gob.NewEncoder(&buf).Encode(l.Values())
+	return buf.Bytes(), err
+}
+
+func (l *List) GobDecode(raw []byte) error {
+	var values []any
+	if err := gob.NewDecoder(bytes.NewReader(raw)).Decode(&values); err != nil {
+		return err
+	}
+	*l = *listFrom(values)
+	return nil
+}
+
+// MarshalJSON encodes the elements as an array for JSON snapshots.
+func (l *List) MarshalJSON() ([]byte, error) {
+	return json.Marshal(l.Values())
+}
+
+func (l *List) UnmarshalJSON(raw []byte) error {
+	var values []any
+	if err := json.Unmarshal(raw, &values); err != nil {
+		return err
+	}
+	*l = *listFrom(values)
+	return nil
+}
+
+func listFrom(values []any) *List {
+	list := &List{}
+	for _, value := range values {
+		list = list.pushBack(value)
+	}
+	return list
+}
+
+// WithMaxListLength caps lists at length elements, pushes drop the elements beyond it from the
+// opposite end so lists keep the most recent elements like LPUSH followed by LTRIM in Redis.
+func WithMaxListLength(length int) Option {
+	return func(s *storage) {
+		s.maxListLength = length
+	}
+}
+
+// LPush prepends the values to the list stored under the key and returns its length. Values end up
+// in reverse order like in Redis. Lists are stored as *List, a missing key starts an empty list
+// stored like Set and the expiration of an existing key is kept. ErrTypeMismatch is returned when
+// the key holds another value.
+func (s *storage) LPush(key string, values ...any) (int, error) {
+	return s.push(key, values, true)
+}
+
+// RPush appends the values to the list stored under the key and returns its length, see LPush.
+func (s *storage) RPush(key string, values ...any) (int, error) {
+	return s.push(key, values, false)
+}
+
+func (s *storage) push(key string, values []any, front bool) (int, error) {
+	length := 0
+	err := s.update(key, func(current any, ok bool) (any, error) {
+		list, err := listOf(current, ok)
+		if err != nil {
+			return nil, err
+		}
+		for _, value := range values {
+			if front {
+				list = list.pushFront(value)
+			} else {
+				list = list.pushBack(value)
+			}
+		}
+		if s.maxListLength > 0 && list.Len() > s.maxListLength {
+			if front {
+				list = list.slice(0, s.maxListLength)
+			} else {
+				list = list.slice(list.Len()-s.maxListLength, list.Len())
+			}
+		}
+		length = list.Len()
+		return list, nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	s.notifyListWaiters(key)
+	return length, nil
+}
+
+// LPop removes and returns the first element of the list, ErrCacheKeyNotFound reports a missing
+// key or an empty list. The key is deleted with its last element.
+func (s *storage) LPop(key string) (any, error) {
+	return s.pop(key, true)
+}
+
+// RPop removes and returns the last element of the list, see LPop.
+func (s *storage) RPop(key string) (any, error) {
+	return s.pop(key, false)
+}
+
+func (s *storage) pop(key string, front bool) (any, error) {
+	var popped any
+	found := false
+	err := s.update(key, func(current any, ok bool) (any, error) {
+		if !ok {
+			return nil, errUnchanged
+		}
+		list, err := listOf(current, ok)
+		if err != nil {
+			return nil, err
+		}
+		if list.Len() == 0 {
+			return nil, errUnchanged
+		}
+		found = true
+		if front {
+			popped, _ = list.Index(0)
+			list = list.slice(1, list.Len())
+		} else {
+			popped, _ = list.Index(list.Len() - 1)
+			list = list.slice(0, list.Len()-1)
+		}
+		if list.Len() == 0 {
+			return nil, nil
+		}
+		return list, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, ErrCacheKeyNotFound
+	}
+	return popped, nil
+}
+
+// BLPop is LPop waiting up to timeout for an element pushed by another goroutine of the process,
+// ErrTimeout is returned when none arrived in time and ErrCacheClosed when the cache was closed.
+func (s *storage) BLPop(key string, timeout time.Duration) (any, error) {
+	return s.blockingPop(key, timeout, true)
+}
+
+// BRPop is RPop waiting up to timeout for an element, see BLPop.
+func (s *storage) BRPop(key string, timeout time.Duration) (any, error) {
+	return s.blockingPop(key, timeout, false)
+}
+
+func (s *storage) blockingPop(key string, timeout time.Duration, front bool) (any, error) {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	for {
+		value, err := s.popOrWait(key, front, timer.C)
+		if !errors.Is(err, errPushed) {
+			return value, err
+		}
+	}
+}
+
+// errPushed is returned by popOrWait when a push ended the wait, the pop is tried again.
+var errPushed = errors.New("pushed")
+
+// popOrWait pops an element or waits for the next push to the key until timeout. Close wakes
+// the waiters, their next pop fails with ErrCacheClosed.
+func (s *storage) popOrWait(key string, front bool, timeout <-chan time.Time) (any, error) {
+	// the waiter is registered before popping so a push in between is not missed
+	waiter := s.joinListWaiter(key)
+	defer s.leaveListWaiter(key, waiter)
+	value, err := s.pop(key, front)
+	if !errors.Is(err, ErrCacheKeyNotFound) {
+		return value, err
+	}
+	select {
+	case <-waiter.pushed:
+		return nil, errPushed
+	case <-timeout:
+		return nil, ErrTimeout
+	}
+}
+
+// LRange returns the elements from start to stop inclusive, negative indexes count from the end
+// like in Redis. A missing key is an empty list.
+func (s *storage) LRange(key string, start, stop int) ([]any, error) {
+	data, err := s.Get(key)
+	if errors.Is(err, ErrCacheKeyNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	list, err := listOf(data, true)
+	if err != nil {
+		return nil, err
+	}
+	return list.Range(start, stop), nil
+}
+
+// LTrim keeps the elements from start to stop inclusive, see LRange. The key is deleted when nothing remains.
+func (s *storage) LTrim(key string, start, stop int) error {
+	return s.update(key, func(current any, ok bool) (any, error) {
+		if !ok {
+			return nil, errUnchanged
+		}
+		list, err := listOf(current, ok)
+		if err != nil {
+			return nil, err
+		}
+		start, stop = listBounds(list.Len(), start, stop)
+		if start > stop {
+			return nil, nil
+		}
+		return list.slice(start, stop+1), nil
+	})
+}
+
+// listBounds resolves negative indexes and clamps them to a list of length.
+func listBounds(length, start, stop int) (int, int) {
+	if start < 0 {
+		start += length
+	}
+	if stop < 0 {
+		stop += length
+	}
+	if start < 0 {
+		start = 0
+	}
+	if stop >= length {
+		stop = length - 1
+	}
+	return start, stop
+}
+
+// listOf accepts the []any lists were stored as before *List, from older snapshots and logs.
+func listOf(data any, ok bool) (*List, error) {
+	if !ok {
+		return &List{}, nil
+	}
+	switch list := data.(type) {
+	case *List:
+		return list, nil
+	case []any:
+		return listFrom(list), nil
+	}
+	return nil, ErrTypeMismatch
+}
+
+// listWaiter is closed by the next push to its key, waiting counts the blocking pops using it.
+type listWaiter struct {
+	pushed  chan struct{}
+	waiting int
+}
+
+// joinListWaiter returns the waiter of the next push to the key, leaveListWaiter must follow.
+func (s *storage) joinListWaiter(key string) *listWaiter {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.listWaiters == nil {
+		s.listWaiters = make(map[string]*listWaiter)
+	}
+	waiter, ok := s.listWaiters[key]
+	if !ok {
+		waiter = &listWaiter{pushed: make(chan struct{})}
+		s.listWaiters[key] = waiter
+	}
+	waiter.waiting++
+	return waiter
+}
+
+// leaveListWaiter drops the waiter of the key once its last blocking pop gave up.
+func (s *storage) leaveListWaiter(key string, waiter *listWaiter) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	waiter.waiting--
+	if waiter.waiting == 0 && s.listWaiters[key] == waiter {
+		delete(s.listWaiters, key)
+	}
+}
+
+func (s *storage) notifyListWaiters(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if waiter, ok := s.listWaiters[key]; ok {
+		close(waiter.pushed)
+		delete(s.listWaiters, key)
+	}
+}
+
+// wakeListWaiters ends all waits when the cache is closed.
+func (s *storage) wakeListWaiters() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for key, waiter := range s.listWaiters {
+		close(waiter.pushed)
+		delete(s.listWaiters, key)
+	}
+}
@@ -0,0 +1,247 @@
+package addcache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// LoaderFunc loads a key missing in the cache, see RegisterLoader. It reports keys the source
+// does not know with ErrCacheKeyNotFound, a ttl of zero or less persists the value.
+type LoaderFunc func(ctx context.Context, key string) (any, time.Duration, error)
+
+// loaders holds the loaders by prefix, the loads in flight, so concurrent misses of a key or
+// identity, see WithLoadIdentity, share one call, the keys known to be missing, see
+// WithNegativeTTL, and the values of recent loads, see WithLoadDedupWindow.
+type loaders struct {
+	mu          sync.Mutex
+	byPrefix    map[string]LoaderFunc
+	batches     map[string]BatchLoader
+	identity    func(key string) string
+	inFlight    map[string]*load
+	negativeTTL time.Duration
+	negatives   map[string]time.Time
+	dedupWindow time.Duration
+	recent      map[string]recentLoad
+}
+
+type load struct {
+	done chan struct{}
+	data any
+	ttl  time.Duration
+	err  error
+}
+
+// RegisterLoader makes Get and GetResult load keys starting with prefix they miss with loader
+// and cache the result. The loader of the longest matching prefix is used, keys no loader
+// matches fall back to the store of WithWriteThrough. Concurrent misses of a key wait for a
+// single loader call. Loaded values are not written back to the backing store. A nil loader
+// unregisters the prefix.
+func (s *storage) RegisterLoader(prefix string, loader LoaderFunc) {
+	s.loaders.mu.Lock()
+	defer s.loaders.mu.Unlock()
+	if loader == nil {
+		delete(s.loaders.byPrefix, prefix)
+		return
+	}
+	if s.loaders.byPrefix == nil {
+		s.loaders.byPrefix = make(map[string]LoaderFunc)
+	}
+	s.loaders.byPrefix[prefix] = loader
+}
+
+// match returns the loader of the longest prefix of key.
+func (l *loaders) match(key string) LoaderFunc {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	var loader LoaderFunc
+	longest := -1
+	for prefix, candidate := range l.byPrefix {
+		if len(prefix) > longest && strings.HasPrefix(key, prefix) {
+			loader, longest = candidate, len(prefix)
+		}
+	}
+	return loader
+}
+
+// do runs fn for key unless a call for key is already running, in which case it waits for that
+// call and shares its result. A panic of fn is raised again in the caller running it, the
+// waiting callers get ErrLoaderPanicked.
+func (l *loaders) do(key string, fn func() (any, error)) (any, error) {
+	data, _, _, err := l.share(key, func() (any, time.Duration, error) {
+		data, err := fn()
+		return data, 0, err
+	})
+	return data, err
+}
+
+// share is do for loads with a ttl, shared reports a result of another caller's call.
+func (l *loaders) share(key string, fn func() (any, time.Duration, error)) (data any, ttl time.Duration, shared bool, err error) {
+	l.mu.Lock()
+	if running, ok := l.inFlight[key]; ok {
+		l.mu.Unlock()
+		<-running.done
+		return running.data, running.ttl, true, running.err
+	}
+	if l.inFlight == nil {
+		l.inFlight = make(map[string]*load)
+	}
+	current := &load{done: make(chan struct{})}
+	l.inFlight[key] = current
+	l.mu.Unlock()
+
+	defer func() {
+		recovered := recover()
+		if recovered != nil {
+			current.data, current.ttl, current.err = nil, 0, fmt.Errorf("%w: %v", ErrLoaderPanicked, recovered)
+		}
+		l.mu.Lock()
+		delete(l.inFlight, key)
+		l.mu.Unlock()
+		close(current.done)
+		if recovered != nil {
+			panic(recovered)
+		}
+	}()
+	current.data, current.ttl, current.err = fn()
+	return current.data, current.ttl, false, current.err
+}
+
+// loadMissing loads a key the cache missed from its loader or the backing store, misses
+// without either stay ErrCacheKeyNotFound. A failed load falls back to the expired entry of
+// the key, reported by stale, see WithLoaderCircuitBreaker.
+func (s *storage) loadMissing(key string, expired storageData) (data any, stale bool, err error) {
+	var fetch func() (any, time.Duration, error)
+	if loader := s.loaders.match(key); loader != nil {
+		fetch = func() (any, time.Duration, error) {
+			return s.loadWith(key, loader)
+		}
+	} else if s.backing.store != nil {
+		fetch = func() (any, time.Duration, error) {
+			data, err := s.readThrough(key)
+			return data, s.defaultTTL, err
+		}
+	} else {
+		return nil, false, ErrCacheKeyNotFound
+	}
+	if s.loaders.knownMissing(key, s.now()) {
+		return nil, false, ErrNegativeCached
+	}
+	if data, ok := s.loaders.recentlyLoaded(key, s.now()); ok {
+		return data, false, nil
+	}
+	flight := s.loaders.flightOf(key)
+	var guard *loadGuard
+	if flight != key {
+		// the load may be made for another key of the identity, this one stores a copy
+		guard = s.beginLoad(key)
+		defer s.endLoad(key, guard)
+	}
+	data, ttl, shared, err := s.loaders.share(flight, func() (any, time.Duration, error) {
+		var ttl time.Duration
+		data, err := s.guardLoad(key, func() (loaded any, err error) {
+			loaded, ttl, err = fetch()
+			return loaded, err
+		})
+		return data, ttl, err
+	})
+	if err == nil && shared && guard != nil {
+		s.storeLoaded(key, s.loadedEntry(data, ttl), guard)
+	}
+	if s.staleFallback(expired, err) {
+		data, err = s.decodeValue(expired.data)
+		return data, err == nil, err
+	}
+	return data, false, err
+}
+
+func (s *storage) loadWith(key string, loader LoaderFunc) (any, time.Duration, error) {
+	guard := s.beginLoad(key)
+	defer s.endLoad(key, guard)
+	data, ttl, err := loader(context.Background(), key)
+	if err != nil {
+		s.rememberMissing(key, err, guard)
+		return nil, 0, err
+	}
+	s.storeLoaded(key, s.loadedEntry(data, ttl), guard)
+	return data, ttl, nil
+}
+
+// loadedEntry is the entry of a loaded value, a ttl of zero or less persists it.
+func (s *storage) loadedEntry(data any, ttl time.Duration) storageData {
+	sd := storageData{isPersistence: true, setTime: s.now(), data: data}
+	if ttl > 0 {
+		sd.isPersistence = false
+		sd.expireDuration = s.jitter.apply(ttl)
+	}
+	return sd
+}
+
+// errLoadSuperseded reports a loaded value that was not stored because the key was written meanwhile.
+var errLoadSuperseded = errors.New("load superseded")
+
+// loadGuard notes writes to a key while loads of it are running, so a value read from the source
+// before the write does not overwrite it.
+type loadGuard struct {
+	loads   int
+	written bool
+}
+
+// beginLoad guards the key until endLoad, a live entry already there counts as written since
+// the miss that started the load.
+func (s *storage) beginLoad(key string) *loadGuard {
+	now := s.now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.loadGuards == nil {
+		s.loadGuards = make(map[string]*loadGuard)
+	}
+	guard, ok := s.loadGuards[key]
+	if !ok {
+		guard = &loadGuard{}
+		s.loadGuards[key] = guard
+	}
+	guard.loads++
+	if sd, ok := s.data[key]; ok && !s.expiredLocked(key, sd, now) {
+		guard.written = true
+	}
+	return guard
+}
+
+func (s *storage) endLoad(key string, guard *loadGuard) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if guard.loads--; guard.loads == 0 {
+		delete(s.loadGuards, key)
+	}
+}
+
+// markWrittenLocked notes a write or removal of a key being loaded, the caller holds the write lock.
+func (s *storage) markWrittenLocked(key string) {
+	if guard, ok := s.loadGuards[key]; ok {
+		guard.written = true
+	}
+}
+
+// rememberMissing adds the negative entry of a key the source did not know unless the key was
+// written while it was loaded.
+func (s *storage) rememberMissing(key string, err error, guard *loadGuard) {
+	s.mu.RLock()
+	written := guard.written
+	s.mu.RUnlock()
+	if !written {
+		s.loaders.rememberMissing(key, err, s.now())
+	}
+}
+
+// storeLoaded caches a loaded value without writing it back unless the key was written while it
+// was loaded, and starts its dedup window.
+func (s *storage) storeLoaded(key string, sd storageData, guard *loadGuard) {
+	data := sd.data
+	if _, _, err := s.storeLocalGuarded(key, sd, guard); err == nil {
+		s.loaders.rememberLoad(key, data, s.now())
+	}
+}
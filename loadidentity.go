@@ -0,0 +1,21 @@
+package addcache
+
+// WithLoadIdentity maps keys to the upstream object they are views of, e.g. one record cached
+// under the keys of several namespaces. Concurrent misses of keys with the same identity share
+// a single call of the loader or the store of WithWriteThrough, made for the key missed first,
+// and the other keys store a copy of its value with the same TTL. A TieredCache over the cache
+// shares its backend reads by identity as well. Negative entries of WithNegativeTTL and the
+// window of WithLoadDedupWindow stay per key.
+func WithLoadIdentity(identity func(key string) string) Option {
+	return func(s *storage) {
+		s.loaders.identity = identity
+	}
+}
+
+// flightOf returns the key concurrent loads of key are shared under.
+func (l *loaders) flightOf(key string) string {
+	if l.identity == nil {
+		return key
+	}
+	return l.identity(key)
+}
@@ -0,0 +1,137 @@
+package addcache
+
+import "time"
+
+// Lock is a lock on a key acquired with TryLock, it is released by Unlock or when its TTL passed.
+type Lock interface {
+	// Key returns the locked key.
+	Key() string
+	// Token returns the fencing token of the lock. Tokens grow with every acquisition, so a
+	// resource can reject writes carrying a smaller token than the last one it accepted. They keep
+	// growing across restarts with WithAppendOnlyLog, a restored snapshot only continues after the
+	// largest token among its live entries.
+	Token() uint64
+	// Unlock releases the lock, ErrLockNotHeld reports that it expired or was taken over before.
+	Unlock() error
+	// Extend restarts the TTL of a held lock with ttl, see Unlock for ErrLockNotHeld.
+	Extend(ttl time.Duration) error
+}
+
+// TryLock stores a fencing token under the key unless it holds a live entry, like SET NX in Redis,
+// and reports whether the lock was acquired. The entry expires after ttl, zero or less keeps it
+// until Unlock. Locks are visible as regular entries and run the Create and removal hooks.
+func (s *storage) TryLock(key string, ttl time.Duration) (Lock, bool) {
+	if s.writable() != nil {
+		return nil, false
+	}
+	now := s.now()
+	s.mu.Lock()
+	if current, ok := s.data[key]; ok && !s.expiredLocked(key, current, now) {
+		s.mu.Unlock()
+		return nil, false
+	}
+	s.lockTokens++
+	token := s.lockTokens
+	if s.aof != nil {
+		s.aof.append(AOFRecord{Op: aofOpLockToken, Time: now, Data: token})
+	}
+	data, err := s.encodeValue(key, token)
+	if err != nil {
+		s.mu.Unlock()
+		return nil, false
+	}
+	sd := storageData{isPersistence: ttl <= 0, setTime: now, expireDuration: ttl, data: data}
+	previous, replaced, removals, err := s.insertLocked(key, sd)
+	if err == nil {
+		if replaced {
+			removals = append(removals, removal{key: key, entry: previous, reason: RemovalExpired})
+		}
+		s.queueRemovalsLocked(removals...)
+		s.queueWriteLocked(key, token, false)
+	}
+	entries := len(s.data)
+	s.mu.Unlock()
+	if err != nil {
+		s.countersOf(key).reject()
+		return nil, false
+	}
+	s.countersOf(key).set()
+	s.finishRemovals(removals...)
+	s.notifyWrite(key, token)
+	s.checkPressure(key, entries)
+	s.checkQuota(key)
+	return &localLock{storage: s, key: key, token: token}, true
+}
+
+type localLock struct {
+	storage *storage
+	key     string
+	token   uint64
+}
+
+// seedLockTokensLocked continues the fencing tokens after a restored token, the caller holds the write lock.
+func (s *storage) seedLockTokensLocked(data any) {
+	if token, ok := data.(uint64); ok && token > s.lockTokens {
+		s.lockTokens = token
+	}
+}
+
+func (l *localLock) Key() string {
+	return l.key
+}
+
+func (l *localLock) Token() uint64 {
+	return l.token
+}
+
+func (l *localLock) Unlock() error {
+	s := l.storage
+	if err := s.writable(); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	sd, err := l.heldLocked(s.now())
+	unlocked := removal{key: l.key, entry: sd, reason: RemovalDeleted}
+	if err == nil {
+		s.deleteLocked(l.key)
+		s.queueRemovalsLocked(unlocked)
+	}
+	s.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	s.finishRemovals(unlocked)
+	return nil
+}
+
+func (l *localLock) Extend(ttl time.Duration) error {
+	s := l.storage
+	if err := s.writable(); err != nil {
+		return err
+	}
+	now := s.now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sd, err := l.heldLocked(now)
+	if err != nil {
+		return err
+	}
+	sd.isPersistence = ttl <= 0
+	sd.setTime = now
+	sd.expireDuration = ttl
+	s.putLocked(l.key, sd)
+	return nil
+}
+
+// heldLocked returns the entry of the lock while it still carries its token, the caller holds the write lock.
+func (l *localLock) heldLocked(now time.Time) (storageData, error) {
+	s := l.storage
+	sd, ok := s.data[l.key]
+	if !ok || s.expiredLocked(l.key, sd, now) {
+		return storageData{}, ErrLockNotHeld
+	}
+	if data, err := s.decodeValue(sd.data); err != nil || data != any(l.token) {
+		return storageData{}, ErrLockNotHeld
+	}
+	return sd, nil
+}
@@ -0,0 +1,88 @@
+package addcache
+
+import (
+	"container/list"
+	"sync"
+)
+
+// lruIndex keeps keys in least recently used order.
+type lruIndex struct {
+	mu       sync.Mutex
+	order    *list.List
+	elements map[string]*list.Element
+}
+
+func newLRUIndex() *lruIndex {
+	return &lruIndex{
+		order:    list.New(),
+		elements: make(map[string]*list.Element),
+	}
+}
+
+// add marks the key as most recently used and reports whether it was new.
+func (l *lruIndex) add(key string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if element, ok := l.elements[key]; ok {
+		l.order.MoveToFront(element)
+		return false
+	}
+	l.elements[key] = l.order.PushFront(key)
+	return true
+}
+
+// touch refreshes a key that is still indexed.
+func (l *lruIndex) touch(key string) {
+	l.mu.Lock()
+	if element, ok := l.elements[key]; ok {
+		l.order.MoveToFront(element)
+	}
+	l.mu.Unlock()
+}
+
+func (l *lruIndex) remove(key string) {
+	l.mu.Lock()
+	if element, ok := l.elements[key]; ok {
+		l.order.Remove(element)
+		delete(l.elements, key)
+	}
+	l.mu.Unlock()
+}
+
+func (l *lruIndex) contains(key string) bool {
+	l.mu.Lock()
+	_, ok := l.elements[key]
+	l.mu.Unlock()
+	return ok
+}
+
+// oldestMatching returns the least recently used key accepted by the filter.
+func (l *lruIndex) oldestMatching(accept func(key string) bool) (string, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for element := l.order.Back(); element != nil; element = element.Prev() {
+		if key := element.Value.(string); accept(key) {
+			return key, true
+		}
+	}
+	return "", false
+}
+
+// oldestN returns up to n least recently used keys accepted by the filter, oldest first.
+func (l *lruIndex) oldestN(n int, accept func(key string) bool) []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	var keys []string
+	for element := l.order.Back(); element != nil && len(keys) < n; element = element.Prev() {
+		if key := element.Value.(string); accept(key) {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+func (l *lruIndex) len() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.order.Len()
+}
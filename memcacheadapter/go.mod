@@ -0,0 +1,10 @@
+module github.com/addit-digital/addcache/memcacheadapter
+
+go 1.18
+
+require (
+	github.com/addit-digital/addcache v0.0.0
+	github.com/bradfitz/gomemcache v0.0.0-20260422231931-4d751bb6e37c
+)
+
+replace github.com/addit-digital/addcache => ../
@@ -0,0 +1,365 @@
+// Package memcacheadapter implements addcache.Cache on top of a gomemcache client, so memcached
+// deployments can sit behind the addcache interface and hooks.
+package memcacheadapter
+
+import (
+	"errors"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/addit-digital/addcache"
+	"github.com/bradfitz/gomemcache/memcache"
+)
+
+// Item flags tell how a value was encoded, integers are stored as decimal text with flag zero
+// so memcached can increment them.
+const (
+	flagInteger uint32 = iota
+	flagCodec
+	flagString
+	flagBytes
+)
+
+// memcached reads expirations above 30 days as unix timestamps.
+const maxRelativeExpiration = 30 * 24 * time.Hour
+
+// ErrorHandler receives failed commands of methods without error result.
+type ErrorHandler func(operation string, key string, err error)
+
+// Cache is an addcache.Cache backed by memcached. Hooks are local to the process that registered them.
+// Counters are unsigned in memcached, decrementing below zero stops at zero.
+type Cache struct {
+	client     *memcache.Client
+	codec      addcache.Codec
+	prefix     string
+	defaultTTL time.Duration
+	onError    ErrorHandler
+	hooks      addcache.Hooks
+}
+
+var _ addcache.Cache = (*Cache)(nil)
+
+// Option configures New.
+type Option func(c *Cache)
+
+// WithCodec encodes values other than strings, byte slices and integers, gob is the default.
+// Values are decoded into any, so gob needs concrete types registered with gob.Register.
+func WithCodec(codec addcache.Codec) Option {
+	return func(c *Cache) {
+		c.codec = codec
+	}
+}
+
+// WithDefaultTTL makes Set and GetAndSet expire after ttl like addcache.WithDefaultTTL.
+func WithDefaultTTL(ttl time.Duration) Option {
+	return func(c *Cache) {
+		c.defaultTTL = ttl
+	}
+}
+
+// WithKeyPrefix namespaces all keys in memcached, hooks still see the keys without prefix.
+func WithKeyPrefix(prefix string) Option {
+	return func(c *Cache) {
+		c.prefix = prefix
+	}
+}
+
+// WithErrorHandler replaces the default handler, which logs failed commands.
+func WithErrorHandler(handler ErrorHandler) Option {
+	return func(c *Cache) {
+		if handler != nil {
+			c.onError = handler
+		}
+	}
+}
+
+// WithHookErrorHandler receives panics recovered from hooks, they are logged by default.
+func WithHookErrorHandler(handler addcache.HookErrorHandler) Option {
+	return func(c *Cache) {
+		c.hooks.ErrorHandler = handler
+	}
+}
+
+// New wraps client, which stays owned by the caller.
+func New(client *memcache.Client, options ...Option) *Cache {
+	c := &Cache{
+		client:  client,
+		codec:   addcache.GobCodec{},
+		onError: logError,
+	}
+	for _, option := range options {
+		option(c)
+	}
+	return c
+}
+
+func logError(operation string, key string, err error) {
+	log.Printf("addcache/memcacheadapter: %s for key %q failed: %v", operation, key, err)
+}
+
+// Set persists the data, or expires it after the WithDefaultTTL duration when configured.
+func (c *Cache) Set(key string, data any) {
+	if err := c.write(key, data, c.defaultTTL); err != nil {
+		c.onError("Set", key, err)
+	}
+}
+
+func (c *Cache) SetPersistent(key string, data any) {
+	if err := c.write(key, data, 0); err != nil {
+		c.onError("SetPersistent", key, err)
+	}
+}
+
+func (c *Cache) SetEx(key string, data any, duration time.Duration) {
+	if err := c.write(key, data, duration); err != nil {
+		c.onError("SetEx", key, err)
+	}
+}
+
+func (c *Cache) Get(key string) (any, error) {
+	item, err := c.client.Get(c.prefix + key)
+	if err != nil {
+		return nil, translate(err)
+	}
+	return c.decode(item)
+}
+
+// Delete removes the key with a single delete command without reading it, Delete hooks receive
+// nil as data.
+func (c *Cache) Delete(key string) {
+	if err := c.remove(key); err != nil {
+		c.onError("Delete", key, err)
+	}
+}
+
+// remove deletes the key, a missing key is not an error.
+func (c *Cache) remove(key string) error {
+	err := c.client.Delete(c.prefix + key)
+	if errors.Is(err, memcache.ErrCacheMiss) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	c.hooks.Run(addcache.DeleteOperation, key, nil)
+	return nil
+}
+
+// GetAndDelete reads and deletes the key in two commands, a concurrent write in between is deleted too.
+func (c *Cache) GetAndDelete(key string) (any, error) {
+	data, err := c.Get(key)
+	if err != nil {
+		return nil, err
+	}
+	if err = c.client.Delete(c.prefix + key); err != nil {
+		return nil, translate(err)
+	}
+	c.hooks.Run(addcache.DeleteOperation, key, data)
+	return data, nil
+}
+
+// GetAndSet stores newData like Set and returns the data it replaced, using compare and swap.
+// ErrCacheKeyNotFound is returned when there was nothing to replace, newData is stored anyway.
+func (c *Cache) GetAndSet(key string, newData any) (any, error) {
+	replacement, err := c.item(key, newData, c.defaultTTL)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		current, err := c.client.Get(c.prefix + key)
+		if errors.Is(err, memcache.ErrCacheMiss) {
+			if err = c.client.Add(replacement); errors.Is(err, memcache.ErrNotStored) {
+				continue
+			}
+			if err != nil {
+				return nil, translate(err)
+			}
+			c.hooks.Run(addcache.CreateOperation, key, newData)
+			return nil, addcache.ErrCacheKeyNotFound
+		}
+		if err != nil {
+			return nil, translate(err)
+		}
+		previous, err := c.decode(current)
+		if err != nil {
+			return nil, err
+		}
+		current.Value, current.Flags, current.Expiration = replacement.Value, replacement.Flags, replacement.Expiration
+		if err = c.client.CompareAndSwap(current); errors.Is(err, memcache.ErrCASConflict) || errors.Is(err, memcache.ErrNotStored) {
+			continue
+		}
+		if err != nil {
+			return nil, translate(err)
+		}
+		c.hooks.Run(addcache.CreateOperation, key, newData)
+		return previous, nil
+	}
+}
+
+// Increment adds delta to the counter, missing keys start at zero and are persisted.
+func (c *Cache) Increment(key string, delta int64) (int64, error) {
+	for {
+		var value uint64
+		var err error
+		if delta >= 0 {
+			value, err = c.client.Increment(c.prefix+key, uint64(delta))
+		} else {
+			value, err = c.client.Decrement(c.prefix+key, uint64(-delta))
+		}
+		if errors.Is(err, memcache.ErrCacheMiss) {
+			initial := delta
+			if initial < 0 {
+				initial = 0
+			}
+			err = c.client.Add(&memcache.Item{Key: c.prefix + key, Value: strconv.AppendInt(nil, initial, 10), Flags: flagInteger})
+			if errors.Is(err, memcache.ErrNotStored) {
+				continue
+			}
+			if err != nil {
+				return 0, translate(err)
+			}
+			value = uint64(initial)
+		} else if err != nil {
+			if strings.Contains(err.Error(), "non-numeric") {
+				return 0, addcache.ErrCacheValueNotInteger
+			}
+			return 0, translate(err)
+		}
+		c.hooks.Run(addcache.CreateOperation, key, int64(value))
+		return int64(value), nil
+	}
+}
+
+func (c *Cache) Decrement(key string, delta int64) (int64, error) {
+	return c.Increment(key, -delta)
+}
+
+func (c *Cache) CreateKey(args ...string) string {
+	return c.CreateKeyWithDelimiter(":", args...)
+}
+
+func (c *Cache) CreateKeyWithDelimiter(delimiter string, args ...string) string {
+	return strings.Join(args, delimiter)
+}
+
+// StopCleanup is a no-op, memcached expires keys itself.
+func (c *Cache) StopCleanup() {}
+
+// Close is a no-op, the client is owned by the caller.
+func (c *Cache) Close() error {
+	return nil
+}
+
+func (c *Cache) SetHook(operationType addcache.OperationType, handlerFunctions ...addcache.HandlerFunc) addcache.HookID {
+	return c.hooks.SetHook(operationType, handlerFunctions...)
+}
+
+func (c *Cache) RemoveHook(operationType addcache.OperationType, id addcache.HookID) {
+	c.hooks.RemoveHook(operationType, id)
+}
+
+func (c *Cache) ClearHooks(operationType addcache.OperationType) {
+	c.hooks.ClearHooks(operationType)
+}
+
+// Backend exposes the cache as the L2 of an addcache.TieredCache.
+func (c *Cache) Backend() addcache.Backend {
+	return backend{cache: c}
+}
+
+type backend struct {
+	cache *Cache
+}
+
+func (b backend) Get(key string) (any, error) {
+	return b.cache.Get(key)
+}
+
+func (b backend) Set(key string, data any, ttl time.Duration) error {
+	return b.cache.write(key, data, ttl)
+}
+
+func (b backend) Delete(key string) error {
+	return b.cache.remove(key)
+}
+
+func (b backend) Increment(key string, delta int64) (int64, error) {
+	return b.cache.Increment(key, delta)
+}
+
+// write stores the data and runs the Create hooks, a ttl of zero persists.
+func (c *Cache) write(key string, data any, ttl time.Duration) error {
+	item, err := c.item(key, data, ttl)
+	if err != nil {
+		return err
+	}
+	if err = c.client.Set(item); err != nil {
+		return translate(err)
+	}
+	c.hooks.Run(addcache.CreateOperation, key, data)
+	return nil
+}
+
+func (c *Cache) item(key string, data any, ttl time.Duration) (*memcache.Item, error) {
+	item := &memcache.Item{Key: c.prefix + key, Expiration: expiration(ttl)}
+	switch v := data.(type) {
+	case string:
+		item.Value, item.Flags = []byte(v), flagString
+	case []byte:
+		item.Value, item.Flags = v, flagBytes
+	case int:
+		item.Value = strconv.AppendInt(nil, int64(v), 10)
+	case int64:
+		item.Value = strconv.AppendInt(nil, v, 10)
+	case int32:
+		item.Value = strconv.AppendInt(nil, int64(v), 10)
+	default:
+		raw, err := c.codec.Marshal(&data)
+		if err != nil {
+			return nil, err
+		}
+		item.Value, item.Flags = raw, flagCodec
+	}
+	return item, nil
+}
+
+// decode reverses item, integers are returned as int64.
+func (c *Cache) decode(item *memcache.Item) (any, error) {
+	switch item.Flags {
+	case flagString:
+		return string(item.Value), nil
+	case flagBytes:
+		return item.Value, nil
+	case flagCodec:
+		var data any
+		if err := c.codec.Unmarshal(item.Value, &data); err != nil {
+			return nil, err
+		}
+		return data, nil
+	}
+	raw := strings.TrimSpace(string(item.Value))
+	if value, err := strconv.ParseInt(raw, 10, 64); err == nil {
+		return value, nil
+	}
+	return string(item.Value), nil
+}
+
+// expiration converts a ttl into memcached seconds, rounding up so short TTLs do not persist.
+func expiration(ttl time.Duration) int32 {
+	if ttl <= 0 {
+		return 0
+	}
+	if ttl > maxRelativeExpiration {
+		return int32(time.Now().Add(ttl).Unix())
+	}
+	return int32((ttl + time.Second - 1) / time.Second)
+}
+
+func translate(err error) error {
+	if errors.Is(err, memcache.ErrCacheMiss) {
+		return addcache.ErrCacheKeyNotFound
+	}
+	return err
+}
@@ -0,0 +1,78 @@
+package addcache
+
+import (
+	"runtime"
+	"time"
+)
+
+// MemoryPressureFunc reports whether the process is short of memory, see WithMemoryPressure.
+type MemoryPressureFunc func() bool
+
+type memoryPressure struct {
+	check    MemoryPressureFunc
+	interval time.Duration
+}
+
+// WithMemoryPressure drops all best effort entries stored with SetBestEffort whenever check,
+// called every interval, reports pressure, e.g. from a cgroup memory limit shared with the service.
+func WithMemoryPressure(check MemoryPressureFunc, interval time.Duration) Option {
+	return func(s *storage) {
+		if check == nil || interval <= 0 {
+			return
+		}
+		s.memoryPressure = &memoryPressure{check: check, interval: interval}
+	}
+}
+
+// WithMemoryWatermark is WithMemoryPressure reporting pressure while the heap in use, as read by
+// runtime.ReadMemStats, exceeds bytes. Reading the stats stops the world briefly, so the interval
+// should be seconds rather than milliseconds.
+func WithMemoryWatermark(bytes uint64, interval time.Duration) Option {
+	return WithMemoryPressure(func() bool {
+		var stats runtime.MemStats
+		runtime.ReadMemStats(&stats)
+		return stats.HeapInuse > bytes
+	}, interval)
+}
+
+// SetBestEffort stores the data like SetEx as best effort entry, which is dropped before anything
+// else when WithMemoryPressure or WithMemoryWatermark report pressure. A ttl of zero or less
+// persists it until then. Overwriting the key with another write makes it a regular entry.
+func (s *storage) SetBestEffort(key string, data any, ttl time.Duration) {
+	sd := storageData{isPersistence: true, setTime: s.now(), data: data, bestEffort: true}
+	if ttl > 0 {
+		sd.isPersistence = false
+		sd.expireDuration = s.jitter.apply(ttl)
+	}
+	s.store(key, sd)
+}
+
+func (s *storage) memoryPressureLoop(t Ticker) {
+	defer t.Stop()
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-t.C():
+			s.relieveMemoryPressure()
+		}
+	}
+}
+
+// relieveMemoryPressure evicts the best effort entries when the pressure check fires.
+func (s *storage) relieveMemoryPressure() {
+	if !s.memoryPressure.check() {
+		return
+	}
+	var removals []removal
+	s.mu.Lock()
+	for key, sd := range s.data {
+		if sd.bestEffort && !s.pinned[key] && !s.protected(key, sd, s.now()) {
+			s.deleteLocked(key)
+			removals = append(removals, removal{key: key, entry: sd, reason: RemovalEvicted, decision: DecisionMemory})
+		}
+	}
+	s.queueRemovalsLocked(removals...)
+	s.mu.Unlock()
+	s.finishRemovals(removals...)
+}
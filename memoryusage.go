@@ -0,0 +1,80 @@
+package addcache
+
+import (
+	"reflect"
+	"strings"
+)
+
+// entryOverhead is the bookkeeping of an entry beside its key and value, the map slot and the
+// storageData header.
+var entryOverhead = int64(reflect.TypeOf(storageData{}).Size() + reflect.TypeOf("").Size())
+
+// Sizer estimates the bytes a value occupies in the cache, see WithSizer.
+type Sizer func(key string, value any) int
+
+// MemoryReport is an estimate of the memory held by the entries of the cache.
+type MemoryReport struct {
+	Bytes      int64
+	Entries    int
+	Namespaces map[string]NamespaceMemory
+}
+
+// NamespaceMemory is the share of a namespace, the part of its keys before the first ":", in a
+// MemoryReport. Keys without ":" are reported under the empty namespace.
+type NamespaceMemory struct {
+	Bytes   int64
+	Entries int
+}
+
+// WithSizer replaces the reflection based size estimate of values with sizer, e.g. for values
+// knowing their own size or holding memory reflection can not see. It is used by MemoryUsage,
+// the cost of WithNamespaceQuota, Inspect, ExportCSV and DebugHandler. Values kept serialized by
+// a codec are measured by their encoded length instead.
+func WithSizer(sizer Sizer) Option {
+	return func(s *storage) {
+		s.sizer = sizer
+	}
+}
+
+// sizeOf estimates the bytes of stored data with the sizer of WithSizer or approximateSize.
+func (s *storage) sizeOf(key string, data any) int {
+	if _, encoded := data.(encodedValue); encoded || s.sizer == nil {
+		return approximateSize(data)
+	}
+	return s.sizer(key, data)
+}
+
+// MemoryUsage estimates the bytes held by keys, values and the bookkeeping of every entry,
+// overall and per namespace. Values are measured like WithSizer describes, allocator overhead
+// and memory shared between values are not accounted exactly. It copies all entries under the
+// read lock and sizes them after releasing it, so it suits dashboards polled every few seconds
+// rather than the request path.
+func (s *storage) MemoryUsage() MemoryReport {
+	type entry struct {
+		key  string
+		data any
+	}
+	s.mu.RLock()
+	entries := make([]entry, 0, len(s.data))
+	for key, sd := range s.data {
+		entries = append(entries, entry{key: key, data: sd.data})
+	}
+	s.mu.RUnlock()
+
+	report := MemoryReport{Namespaces: make(map[string]NamespaceMemory)}
+	for _, e := range entries {
+		key := e.key
+		bytes := int64(len(key)+s.sizeOf(key, e.data)) + entryOverhead
+		report.Bytes += bytes
+		report.Entries++
+		namespace := keyPrefix(key)
+		if !strings.Contains(key, defaultDelimiter) {
+			namespace = ""
+		}
+		usage := report.Namespaces[namespace]
+		usage.Bytes += bytes
+		usage.Entries++
+		report.Namespaces[namespace] = usage
+	}
+	return report
+}
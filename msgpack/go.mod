@@ -0,0 +1,12 @@
+module github.com/addit-digital/addcache/msgpack
+
+go 1.18
+
+require (
+	github.com/addit-digital/addcache v0.0.0
+	github.com/vmihailenco/msgpack/v5 v5.4.1
+)
+
+require github.com/vmihailenco/tagparser/v2 v2.0.0 // indirect
+
+replace github.com/addit-digital/addcache => ../
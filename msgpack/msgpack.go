@@ -0,0 +1,20 @@
+// Package msgpack provides a MessagePack addcache.Codec.
+package msgpack
+
+import (
+	"github.com/addit-digital/addcache"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// Codec encodes with github.com/vmihailenco/msgpack/v5.
+type Codec struct{}
+
+var _ addcache.Codec = Codec{}
+
+func (Codec) Marshal(v any) ([]byte, error) {
+	return msgpack.Marshal(v)
+}
+
+func (Codec) Unmarshal(data []byte, v any) error {
+	return msgpack.Unmarshal(data, v)
+}
@@ -0,0 +1,231 @@
+package addcache
+
+import (
+	"math"
+	"sync"
+	"sync/atomic"
+)
+
+const QuotaOperation OperationType = "Quota"
+
+// QuotaEvent is passed as data to Quota hooks when a namespace starts to run into its quota,
+// i.e. a write had to evict entries of the namespace or was rejected. The hook fires again only
+// after a write of the namespace fit without either.
+type QuotaEvent struct {
+	Namespace  string
+	Entries    int
+	Cost       int64
+	MaxEntries int
+	MaxCost    int64
+}
+
+// namespaces tracks the namespaces of keys, the part before the first ":" like for Recommendations.
+type namespaces struct {
+	all    bool
+	states sync.Map
+}
+
+// namespaceState holds the counters of a namespace, entries and cost are guarded by the write
+// lock of the storage, counters are updated atomically.
+type namespaceState struct {
+	counters statsCounters
+	entries  int
+	cost     int64
+	maxCost  int64
+	limit    *prefixLimit
+	alerted  bool
+	pending  int32
+}
+
+// WithNamespaceStats keeps Stats per namespace for NamespaceStats, the namespace of a key is the
+// part before its first ":". Namespaces should be a small set such as tenants or subsystems.
+func WithNamespaceStats() Option {
+	return func(s *storage) {
+		s.enableNamespaces().all = true
+	}
+}
+
+// WithNamespaceQuota bounds the entries of a namespace to maxEntries and the approximate size of
+// their values to maxCost bytes, zero leaves a bound out. Writes beyond the quota evict the least
+// recently used entries of the same namespace, so one tenant can not evict the entries of others.
+// Single values larger than maxCost are rejected with ErrCapacityExceeded. Quota hooks report a
+// namespace running into its quota, its Stats are available from NamespaceStats.
+func WithNamespaceQuota(namespace string, maxEntries int, maxCost int64) Option {
+	return func(s *storage) {
+		if maxEntries <= 0 {
+			maxEntries = math.MaxInt
+		}
+		limit := &prefixLimit{
+			prefix:  namespace + defaultDelimiter,
+			maxKeys: maxEntries,
+			policy:  PrefixEvictOldest,
+			keys:    newLRUIndex(),
+		}
+		s.prefixLimits = append(s.prefixLimits, limit)
+		s.enableNamespaces().states.Store(namespace, &namespaceState{maxCost: maxCost, limit: limit})
+	}
+}
+
+func (s *storage) enableNamespaces() *namespaces {
+	if s.namespaces == nil {
+		s.namespaces = &namespaces{}
+	}
+	return s.namespaces
+}
+
+// namespaceOf returns the state of the namespace of key, nil when it is not tracked.
+func (s *storage) namespaceOf(key string) *namespaceState {
+	if s.namespaces == nil {
+		return nil
+	}
+	namespace := keyPrefix(key)
+	if state, ok := s.namespaces.states.Load(namespace); ok {
+		return state.(*namespaceState)
+	}
+	if !s.namespaces.all {
+		return nil
+	}
+	state, _ := s.namespaces.states.LoadOrStore(namespace, &namespaceState{})
+	return state.(*namespaceState)
+}
+
+// NamespaceStats returns the Stats of a namespace tracked by WithNamespaceStats or WithNamespaceQuota,
+// false for namespaces without any.
+func (s *storage) NamespaceStats(namespace string) (Stats, bool) {
+	if s.namespaces == nil {
+		return Stats{}, false
+	}
+	state, ok := s.namespaces.states.Load(namespace)
+	if !ok {
+		return Stats{}, false
+	}
+	ns := state.(*namespaceState)
+	s.mu.RLock()
+	entries := ns.entries
+	s.mu.RUnlock()
+	return Stats{
+		Hits:        atomic.LoadInt64(&ns.counters.hits),
+		Misses:      atomic.LoadInt64(&ns.counters.misses),
+		Sets:        atomic.LoadInt64(&ns.counters.sets),
+		Deletes:     atomic.LoadInt64(&ns.counters.deletes),
+		Expirations: atomic.LoadInt64(&ns.counters.expirations),
+		Evictions:   atomic.LoadInt64(&ns.counters.evictions),
+		Rejections:  atomic.LoadInt64(&ns.counters.rejections),
+		Entries:     int64(entries),
+	}, true
+}
+
+// keyCounters updates the counters of the cache and of the namespace of a key.
+type keyCounters struct {
+	global    *statsCounters
+	namespace *statsCounters
+}
+
+func (s *storage) countersOf(key string) keyCounters {
+	c := keyCounters{global: &s.counters}
+	if ns := s.namespaceOf(key); ns != nil {
+		c.namespace = &ns.counters
+	}
+	return c
+}
+
+func (c keyCounters) hit()    { c.each((*statsCounters).hit) }
+func (c keyCounters) miss()   { c.each((*statsCounters).miss) }
+func (c keyCounters) set()    { c.each((*statsCounters).set) }
+func (c keyCounters) delete() { c.each((*statsCounters).delete) }
+func (c keyCounters) evict()  { c.each((*statsCounters).evict) }
+func (c keyCounters) reject() { c.each((*statsCounters).reject) }
+func (c keyCounters) expire() { c.each((*statsCounters).expire) }
+
+func (c keyCounters) each(count func(*statsCounters)) {
+	count(c.global)
+	if c.namespace != nil {
+		count(c.namespace)
+	}
+}
+
+// namespaceCost is the cost of a value counted against WithNamespaceQuota, zero for untracked keys.
+func (s *storage) namespaceCost(key string, data any) int64 {
+	if s.namespaceOf(key) == nil {
+		return 0
+	}
+	return int64(s.sizeOf(key, data))
+}
+
+// countNamespaceLocked adds or, with a negative sign, removes an entry from the usage of its
+// namespace, the caller holds the write lock.
+func (s *storage) countNamespaceLocked(key string, sd storageData, sign int) {
+	if ns := s.namespaceOf(key); ns != nil {
+		ns.entries += sign
+		ns.cost += int64(sign) * sd.cost
+	}
+}
+
+// admitNamespaceLocked rejects values larger than the cost quota of their namespace and notes
+// whether the write runs into the quota, the caller holds the write lock.
+func (s *storage) admitNamespaceLocked(key string, sd storageData) bool {
+	ns := s.namespaceOf(key)
+	if ns == nil || ns.limit == nil {
+		return true
+	}
+	_, exists := s.data[key]
+	cost := s.namespaceCost(key, sd.data)
+	admitted := ns.maxCost <= 0 || cost <= ns.maxCost
+	full := !exists && ns.limit.keys.len() >= ns.limit.maxKeys
+	if !admitted || full || ns.maxCost > 0 && ns.cost+cost > ns.maxCost {
+		s.alertQuotaLocked(ns)
+	} else {
+		ns.alerted = false
+	}
+	return admitted
+}
+
+// enforceCostLocked evicts the least recently used entries of the namespace of the written key
+// until the namespace fits its cost quota again, the caller holds the write lock.
+func (s *storage) enforceCostLocked(written string) []removal {
+	ns := s.namespaceOf(written)
+	if ns == nil || ns.limit == nil || ns.maxCost <= 0 {
+		return nil
+	}
+	var removals []removal
+	for ns.cost > ns.maxCost {
+		victim, ok := ns.limit.keys.oldestMatching(func(key string) bool {
+			return key != written && s.evictable(key)
+		})
+		if !ok {
+			break
+		}
+		sd := s.data[victim]
+		s.deleteLocked(victim)
+		removals = append(removals, removal{key: victim, entry: sd, reason: RemovalEvicted, decision: DecisionQuota})
+	}
+	return removals
+}
+
+func (s *storage) alertQuotaLocked(ns *namespaceState) {
+	if !ns.alerted {
+		ns.alerted = true
+		atomic.StoreInt32(&ns.pending, 1)
+	}
+}
+
+// checkQuota runs the Quota hooks of a namespace that ran into its quota during a write of key.
+func (s *storage) checkQuota(key string) {
+	ns := s.namespaceOf(key)
+	if ns == nil || !atomic.CompareAndSwapInt32(&ns.pending, 1, 0) {
+		return
+	}
+	s.mu.RLock()
+	event := QuotaEvent{
+		Namespace:  keyPrefix(key),
+		Entries:    ns.entries,
+		Cost:       ns.cost,
+		MaxEntries: ns.limit.maxKeys,
+		MaxCost:    ns.maxCost,
+	}
+	s.mu.RUnlock()
+	if event.MaxEntries == math.MaxInt {
+		event.MaxEntries = 0
+	}
+	s.processHooks(QuotaOperation, key, event)
+}
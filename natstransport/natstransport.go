@@ -0,0 +1,158 @@
+// Package natstransport implements the cluster.Transport of addcache invalidations on NATS,
+// with one subject per key namespace and optional JetStream delivery.
+package natstransport
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/addit-digital/addcache/cluster"
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+// Transport publishes the invalidations of a key namespace on <subject>.<namespace>, those of
+// keys without namespace on <subject> itself.
+type Transport struct {
+	conn       *nats.Conn
+	subject    string
+	namespaces []string
+	stream     string
+	js         jetstream.JetStream
+	timeout    time.Duration
+}
+
+var _ cluster.NamespacedTransport = (*Transport)(nil)
+
+// Option configures New.
+type Option func(t *Transport)
+
+// WithNamespaces subscribes to the invalidations of the listed namespaces only, all by default.
+// Use it when processes cache disjoint namespaces to spare them the traffic of the others.
+func WithNamespaces(namespaces ...string) Option {
+	return func(t *Transport) {
+		t.namespaces = namespaces
+	}
+}
+
+// WithJetStream publishes into stream, which has to exist and capture the subjects, and consumes
+// it with an ordered consumer starting at new messages. Publications are acknowledged by the
+// server, so a lost invalidation is reported instead of silently dropped.
+func WithJetStream(stream string) Option {
+	return func(t *Transport) {
+		t.stream = stream
+	}
+}
+
+// WithTimeout bounds JetStream publications and the creation of the consumer, 5 seconds by default.
+func WithTimeout(timeout time.Duration) Option {
+	return func(t *Transport) {
+		t.timeout = timeout
+	}
+}
+
+// New uses subject as root of the invalidation subjects on conn, which stays owned by the caller.
+func New(conn *nats.Conn, subject string, options ...Option) (*Transport, error) {
+	t := &Transport{
+		conn:    conn,
+		subject: subject,
+		timeout: 5 * time.Second,
+	}
+	for _, option := range options {
+		option(t)
+	}
+	if t.stream != "" {
+		js, err := jetstream.New(conn)
+		if err != nil {
+			return nil, err
+		}
+		t.js = js
+	}
+	return t, nil
+}
+
+// Publish sends payload on the subject of keys without namespace.
+func (t *Transport) Publish(payload []byte) error {
+	return t.PublishNamespace("", payload)
+}
+
+func (t *Transport) PublishNamespace(namespace string, payload []byte) error {
+	subject := t.subjectOf(namespace)
+	if t.js == nil {
+		return t.conn.Publish(subject, payload)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), t.timeout)
+	defer cancel()
+	_, err := t.js.Publish(ctx, subject, payload)
+	return err
+}
+
+func (t *Transport) Subscribe(handler func(payload []byte)) (func(), error) {
+	subjects := []string{t.subject, t.subject + ".>"}
+	if t.namespaces != nil {
+		subjects = subjects[:0]
+		for _, namespace := range t.namespaces {
+			subjects = append(subjects, t.subjectOf(namespace))
+		}
+	}
+	if t.js != nil {
+		return t.consume(subjects, handler)
+	}
+
+	var subscriptions []*nats.Subscription
+	cancel := func() {
+		for _, subscription := range subscriptions {
+			_ = subscription.Unsubscribe()
+		}
+	}
+	for _, subject := range subjects {
+		subscription, err := t.conn.Subscribe(subject, func(message *nats.Msg) {
+			handler(message.Data)
+		})
+		if err != nil {
+			cancel()
+			return nil, err
+		}
+		subscriptions = append(subscriptions, subscription)
+	}
+	// make sure the server registered the subscriptions before publications are relied on
+	if err := t.conn.Flush(); err != nil {
+		cancel()
+		return nil, err
+	}
+	return cancel, nil
+}
+
+func (t *Transport) consume(subjects []string, handler func(payload []byte)) (func(), error) {
+	ctx, cancel := context.WithTimeout(context.Background(), t.timeout)
+	defer cancel()
+	consumer, err := t.js.OrderedConsumer(ctx, t.stream, jetstream.OrderedConsumerConfig{
+		FilterSubjects: subjects,
+		DeliverPolicy:  jetstream.DeliverNewPolicy,
+	})
+	if err != nil {
+		return nil, err
+	}
+	consumption, err := consumer.Consume(func(message jetstream.Msg) {
+		handler(message.Data())
+	})
+	if err != nil {
+		return nil, err
+	}
+	return consumption.Stop, nil
+}
+
+// subjectOf maps a namespace to its subject, characters NATS reserves in subjects become "_".
+func (t *Transport) subjectOf(namespace string) string {
+	if namespace == "" {
+		return t.subject
+	}
+	return t.subject + "." + strings.Map(func(r rune) rune {
+		switch r {
+		case '.', '*', '>', ' ', '\t', '\r', '\n':
+			return '_'
+		}
+		return r
+	}, namespace)
+}
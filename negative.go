@@ -0,0 +1,66 @@
+package addcache
+
+import (
+	"errors"
+	"time"
+)
+
+// WithNegativeTTL remembers for ttl that a loader of RegisterLoader or the store of
+// WithWriteThrough did not know a key. Reads missing the key within ttl fail with
+// ErrNegativeCached, which is also an ErrCacheKeyNotFound, without asking the source again.
+// Writing the key ends the negative entry early.
+func WithNegativeTTL(ttl time.Duration) Option {
+	return func(s *storage) {
+		if ttl > 0 {
+			s.loaders.negativeTTL = ttl
+		}
+	}
+}
+
+// knownMissing reports whether a negative entry of key is still live.
+func (l *loaders) knownMissing(key string, now time.Time) bool {
+	if l.negativeTTL == 0 {
+		return false
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	until, ok := l.negatives[key]
+	return ok && now.Before(until)
+}
+
+// rememberMissing adds a negative entry when err reports an unknown key.
+func (l *loaders) rememberMissing(key string, err error, now time.Time) {
+	if l.negativeTTL == 0 || !errors.Is(err, ErrCacheKeyNotFound) {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.negatives == nil {
+		l.negatives = make(map[string]time.Time)
+	}
+	l.negatives[key] = now.Add(l.negativeTTL)
+}
+
+// forgetMissing drops the negative entry of a key written to the cache.
+func (l *loaders) forgetMissing(key string) {
+	if l.negativeTTL == 0 {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.negatives, key)
+}
+
+// dropExpiredMissing drops the negative entries passed at now, called by the cleanup passes.
+func (l *loaders) dropExpiredMissing(now time.Time) {
+	if l.negativeTTL == 0 {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for key, until := range l.negatives {
+		if !now.Before(until) {
+			delete(l.negatives, key)
+		}
+	}
+}
@@ -0,0 +1,30 @@
+package addcache
+
+import "time"
+
+// Option configures a cache created by NewCache or NewCacheWithCleanup.
+type Option func(s *storage)
+
+// WithName labels the cache instance, named instances are listed in the process registry.
+func WithName(name string) Option {
+	return func(s *storage) {
+		s.name = name
+	}
+}
+
+// WithDefaultTTL makes Set, GetAndSet and new Increment counters expire after ttl.
+// SetPersistent keeps data forever regardless.
+func WithDefaultTTL(ttl time.Duration) Option {
+	return func(s *storage) {
+		s.defaultTTL = ttl
+	}
+}
+
+// WithMaxValueSize rejects []byte and string values larger than size bytes with ErrValueTooLarge.
+// Values are measured as stored, after WithSerialization and WithCompression, which also makes
+// other types measurable.
+func WithMaxValueSize(size int) Option {
+	return func(s *storage) {
+		s.maxValueSize = size
+	}
+}
@@ -0,0 +1,221 @@
+// Package otel instruments an addcache.Cache with OpenTelemetry spans and metrics.
+package otel
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/addit-digital/addcache"
+	otelapi "go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const instrumentationName = "github.com/addit-digital/addcache/otel"
+
+type config struct {
+	tracerProvider trace.TracerProvider
+	meterProvider  metric.MeterProvider
+	name           string
+	keyAttribute   bool
+}
+
+// Option configures Instrument.
+type Option func(c *config)
+
+// WithTracerProvider replaces the global tracer provider.
+func WithTracerProvider(provider trace.TracerProvider) Option {
+	return func(c *config) {
+		c.tracerProvider = provider
+	}
+}
+
+// WithMeterProvider replaces the global meter provider.
+func WithMeterProvider(provider metric.MeterProvider) Option {
+	return func(c *config) {
+		c.meterProvider = provider
+	}
+}
+
+// WithCacheName sets the cache.name attribute on all spans and measurements.
+func WithCacheName(name string) Option {
+	return func(c *config) {
+		c.name = name
+	}
+}
+
+// WithKeyAttribute records keys as the cache.key span attribute, off by default because keys may carry user data.
+func WithKeyAttribute(enabled bool) Option {
+	return func(c *config) {
+		c.keyAttribute = enabled
+	}
+}
+
+type instruments struct {
+	config     config
+	tracer     trace.Tracer
+	operations metric.Int64Counter
+	duration   metric.Float64Histogram
+}
+
+// instrumentedCache wraps a Cache, spans are parented to ctx set by WithContext.
+type instrumentedCache struct {
+	addcache.Cache
+	ctx         context.Context
+	instruments *instruments
+}
+
+// Instrument decorates cache with spans and the addcache.operations and addcache.operation.duration instruments.
+func Instrument(cache addcache.Cache, options ...Option) addcache.Cache {
+	cfg := config{
+		tracerProvider: otelapi.GetTracerProvider(),
+		meterProvider:  otelapi.GetMeterProvider(),
+	}
+	for _, option := range options {
+		option(&cfg)
+	}
+
+	meter := cfg.meterProvider.Meter(instrumentationName)
+	operations, err := meter.Int64Counter("addcache.operations",
+		metric.WithDescription("Cache operations by type and result."))
+	if err != nil {
+		otelapi.Handle(err)
+	}
+	duration, err := meter.Float64Histogram("addcache.operation.duration",
+		metric.WithDescription("Duration of cache operations."),
+		metric.WithUnit("s"))
+	if err != nil {
+		otelapi.Handle(err)
+	}
+
+	return &instrumentedCache{
+		Cache: cache,
+		ctx:   context.Background(),
+		instruments: &instruments{
+			config:     cfg,
+			tracer:     cfg.tracerProvider.Tracer(instrumentationName),
+			operations: operations,
+			duration:   duration,
+		},
+	}
+}
+
+// WithContext returns a view of an instrumented cache whose spans are children of the span in ctx.
+// Caches not created by Instrument are returned unchanged.
+func WithContext(ctx context.Context, cache addcache.Cache) addcache.Cache {
+	instrumented, ok := cache.(*instrumentedCache)
+	if !ok {
+		return cache
+	}
+	return &instrumentedCache{
+		Cache:       instrumented.Cache,
+		ctx:         ctx,
+		instruments: instrumented.instruments,
+	}
+}
+
+func (c *instrumentedCache) Set(key string, data any) {
+	end := c.start("Set", key)
+	c.Cache.Set(key, data)
+	end(nil)
+}
+
+func (c *instrumentedCache) SetPersistent(key string, data any) {
+	end := c.start("SetPersistent", key)
+	c.Cache.SetPersistent(key, data)
+	end(nil)
+}
+
+func (c *instrumentedCache) SetEx(key string, data any, duration time.Duration) {
+	end := c.start("SetEx", key, attribute.Int64("cache.ttl_ms", duration.Milliseconds()))
+	c.Cache.SetEx(key, data, duration)
+	end(nil)
+}
+
+func (c *instrumentedCache) Get(key string) (any, error) {
+	end := c.start("Get", key)
+	data, err := c.Cache.Get(key)
+	end(err)
+	return data, err
+}
+
+func (c *instrumentedCache) Delete(key string) {
+	end := c.start("Delete", key)
+	c.Cache.Delete(key)
+	end(nil)
+}
+
+func (c *instrumentedCache) GetAndDelete(key string) (any, error) {
+	end := c.start("GetAndDelete", key)
+	data, err := c.Cache.GetAndDelete(key)
+	end(err)
+	return data, err
+}
+
+func (c *instrumentedCache) GetAndSet(key string, newData any) (any, error) {
+	end := c.start("GetAndSet", key)
+	data, err := c.Cache.GetAndSet(key, newData)
+	end(err)
+	return data, err
+}
+
+func (c *instrumentedCache) Increment(key string, delta int64) (int64, error) {
+	end := c.start("Increment", key)
+	value, err := c.Cache.Increment(key, delta)
+	end(err)
+	return value, err
+}
+
+func (c *instrumentedCache) Decrement(key string, delta int64) (int64, error) {
+	end := c.start("Decrement", key)
+	value, err := c.Cache.Decrement(key, delta)
+	end(err)
+	return value, err
+}
+
+// start opens the span of an operation, the returned function ends it with the outcome.
+// Lookups report cache.hit, a not found error counts as a miss and not as a span error.
+func (c *instrumentedCache) start(operation string, key string, attributes ...attribute.KeyValue) func(err error) {
+	in := c.instruments
+	attributes = append(attributes, attribute.String("cache.operation", operation))
+	if in.config.name != "" {
+		attributes = append(attributes, attribute.String("cache.name", in.config.name))
+	}
+	spanAttributes := attributes[:len(attributes):len(attributes)]
+	if in.config.keyAttribute {
+		spanAttributes = append(spanAttributes, attribute.String("cache.key", key))
+	}
+
+	_, span := in.tracer.Start(c.ctx, "addcache."+operation,
+		trace.WithSpanKind(trace.SpanKindInternal),
+		trace.WithAttributes(spanAttributes...))
+	started := time.Now()
+
+	return func(err error) {
+		result := "ok"
+		switch {
+		case errors.Is(err, addcache.ErrCacheKeyNotFound):
+			result = "miss"
+			span.SetAttributes(attribute.Bool("cache.hit", false))
+		case err != nil:
+			result = "error"
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		case operation == "Get" || operation == "GetAndDelete":
+			result = "hit"
+			span.SetAttributes(attribute.Bool("cache.hit", true))
+		}
+		span.End()
+
+		measured := metric.WithAttributes(append(attributes[:len(attributes):len(attributes)], attribute.String("cache.result", result))...)
+		if in.operations != nil {
+			in.operations.Add(c.ctx, 1, measured)
+		}
+		if in.duration != nil {
+			in.duration.Record(c.ctx, time.Since(started).Seconds(), measured)
+		}
+	}
+}
@@ -0,0 +1,220 @@
+// Package peer turns a set of addcache instances into one shared cache without an external
+// server, in the style of groupcache: every instance owns a consistent hash partition of the
+// keys, loads and caches the keys it owns and fetches the others from their owner over HTTP.
+// Keys requested often from other owners are replicated locally for a short time.
+package peer
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/addit-digital/addcache"
+)
+
+const defaultBasePath = "/_addcache/"
+
+// Loader loads the data of a key owned by this instance, it returns addcache.ErrCacheKeyNotFound
+// for keys that do not exist.
+type Loader func(key string) (any, error)
+
+// Option configures NewPool.
+type Option func(p *Pool)
+
+// WithBasePath sets the path prefix peer requests are served under, "/_addcache/" by default.
+func WithBasePath(path string) Option {
+	return func(p *Pool) {
+		p.basePath = path
+	}
+}
+
+// WithVirtualNodes sets the points of every peer on the hash ring, see addcache.NewRing.
+func WithVirtualNodes(virtualNodes int) Option {
+	return func(p *Pool) {
+		p.virtualNodes = virtualNodes
+	}
+}
+
+// WithTTL expires the loaded data of owned keys, zero persists it.
+func WithTTL(ttl time.Duration) Option {
+	return func(p *Pool) {
+		p.ttl = ttl
+	}
+}
+
+// WithHotKeys replicates keys fetched from their owner at least threshold times within ttl
+// into the local cache for ttl, 10 times within 10 seconds by default. A threshold of zero
+// or less disables replication.
+func WithHotKeys(threshold int, ttl time.Duration) Option {
+	return func(p *Pool) {
+		p.hotThreshold = threshold
+		p.hotTTL = ttl
+	}
+}
+
+// WithClient replaces the client of peer requests, by default one with a 5 second timeout.
+func WithClient(client *http.Client) Option {
+	return func(p *Pool) {
+		if client != nil {
+			p.client = client
+		}
+	}
+}
+
+// WithCodec encodes values on the wire, gob is the default. Values are decoded into any, so gob
+// needs concrete types registered with gob.Register.
+func WithCodec(codec addcache.Codec) Option {
+	return func(p *Pool) {
+		p.codec = codec
+	}
+}
+
+// Pool is the view of one instance on the peers sharing the keys.
+type Pool struct {
+	self         string
+	local        addcache.LocalCache
+	loader       Loader
+	ring         *addcache.Ring
+	virtualNodes int
+	basePath     string
+	ttl          time.Duration
+	hotThreshold int
+	hotTTL       time.Duration
+	client       *http.Client
+	codec        addcache.Codec
+
+	hotMu      sync.Mutex
+	hotCounts  map[string]int
+	hotStarted time.Time
+}
+
+// NewPool serves the keys self owns from local, loading missing ones with loader. Self is the
+// base URL other peers reach this instance under, e.g. "http://10.0.0.1:8080", and has to be
+// in the peers passed to SetPeers. The pool has to be mounted at the base path as handler.
+func NewPool(self string, local addcache.LocalCache, loader Loader, options ...Option) *Pool {
+	p := &Pool{
+		self:         self,
+		local:        local,
+		loader:       loader,
+		basePath:     defaultBasePath,
+		hotThreshold: 10,
+		hotTTL:       10 * time.Second,
+		client:       &http.Client{Timeout: 5 * time.Second},
+		codec:        addcache.GobCodec{},
+		hotCounts:    make(map[string]int),
+	}
+	for _, option := range options {
+		option(p)
+	}
+	p.ring = addcache.NewRing(p.virtualNodes, self)
+	return p
+}
+
+// SetPeers replaces the base URLs of all instances sharing the keys, including self.
+func (p *Pool) SetPeers(peers ...string) {
+	p.ring.Set(peers...)
+}
+
+// Get returns the data of key, from the local cache when this instance owns or replicates it
+// and from the owning peer otherwise. When the owner can not be reached the key is loaded locally.
+func (p *Pool) Get(key string) (any, error) {
+	owner, ok := p.ring.Get(key)
+	if !ok || owner == p.self {
+		return p.load(key)
+	}
+	if data, err := p.local.Get(key); err == nil {
+		return data, nil
+	}
+
+	data, err := p.fetch(owner, key)
+	if errors.Is(err, addcache.ErrCacheKeyNotFound) {
+		return nil, err
+	}
+	if err != nil {
+		return p.load(key)
+	}
+	if p.hot(key) {
+		p.local.SetEx(key, data, p.hotTTL)
+	}
+	return data, nil
+}
+
+func (p *Pool) load(key string) (any, error) {
+	return p.local.GetOrCompute(key, p.ttl, func() (any, error) {
+		return p.loader(key)
+	})
+}
+
+// hot counts a fetch of the key and reports whether it crossed the replication threshold.
+func (p *Pool) hot(key string) bool {
+	if p.hotThreshold <= 0 {
+		return false
+	}
+	p.hotMu.Lock()
+	defer p.hotMu.Unlock()
+	if now := time.Now(); now.Sub(p.hotStarted) >= p.hotTTL {
+		p.hotCounts = make(map[string]int)
+		p.hotStarted = now
+	}
+	p.hotCounts[key]++
+	return p.hotCounts[key] >= p.hotThreshold
+}
+
+func (p *Pool) fetch(owner string, key string) (any, error) {
+	response, err := p.client.Get(strings.TrimSuffix(owner, "/") + p.basePath + url.PathEscape(key))
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+	switch response.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return nil, addcache.ErrCacheKeyNotFound
+	default:
+		return nil, fmt.Errorf("addcache/peer: %s answered %s", owner, response.Status)
+	}
+	raw, err := io.ReadAll(response.Body)
+	if err != nil {
+		return nil, err
+	}
+	var data any
+	if err := p.codec.Unmarshal(raw, &data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// ServeHTTP answers peer requests for keys this instance owns, keys it does not own are
+// loaded anyway so peers with a different view of the ring still get an answer.
+func (p *Pool) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet || !strings.HasPrefix(r.URL.EscapedPath(), p.basePath) {
+		http.NotFound(w, r)
+		return
+	}
+	key, err := url.PathUnescape(strings.TrimPrefix(r.URL.EscapedPath(), p.basePath))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	data, err := p.load(key)
+	if errors.Is(err, addcache.ErrCacheKeyNotFound) {
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	raw, err := p.codec.Marshal(&data)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/octet-stream")
+	_, _ = w.Write(raw)
+}
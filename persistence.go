@@ -0,0 +1,160 @@
+package addcache
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/cipher"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	RestoreOperation OperationType = "Restore"
+
+	persistenceMagic = "ADDCACHE-FILE1"
+)
+
+// RestoreEvent is passed as data to Restore hooks, the key is the snapshot path.
+// Err is set when the file could not be restored and the cache started empty.
+type RestoreEvent struct {
+	Path     string
+	Entries  int
+	Err      error
+	Restored time.Time
+}
+
+type persistence struct {
+	path      string
+	interval  time.Duration
+	lastSaved int64
+}
+
+// WithPersistence restores the cache from the snapshot file at path on creation and
+// rewrites it atomically every interval and on Close. The file carries a SHA-256 checksum,
+// a corrupt or missing file leaves the cache empty and is reported to Restore hooks.
+func WithPersistence(path string, interval time.Duration) Option {
+	return func(s *storage) {
+		s.persistence = &persistence{path: path, interval: interval}
+	}
+}
+
+// WithHook registers handlers at creation, needed for hooks fired while the cache is built such as Restore.
+func WithHook(operationType OperationType, handlerFunctions ...HandlerFunc) Option {
+	return func(s *storage) {
+		s.SetHook(operationType, handlerFunctions...)
+	}
+}
+
+func (s *storage) restoreFromFile() {
+	p := s.persistence
+	event := RestoreEvent{Path: p.path, Restored: s.now()}
+	entries, err := readSnapshotFile(p.path, s.snapshotCodec, s.aead)
+	if err != nil {
+		event.Err = err
+	} else {
+		s.restore(entries)
+		event.Entries = len(entries)
+	}
+	atomic.StoreInt64(&p.lastSaved, s.changes())
+	s.processHooks(RestoreOperation, p.path, event)
+}
+
+func (s *storage) persistenceLoop(t Ticker) {
+	defer t.Stop()
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-t.C():
+			_ = s.persist(false)
+		}
+	}
+}
+
+// persist writes the snapshot file when the cache changed since the last write, or always when forced.
+func (s *storage) persist(force bool) error {
+	p := s.persistence
+	changes := s.changes()
+	if !force && changes == atomic.LoadInt64(&p.lastSaved) {
+		return nil
+	}
+	if err := writeSnapshotFile(p.path, s.snapshotFormat, s.snapshotCodec, s.aead, s.liveEntries()); err != nil {
+		return err
+	}
+	atomic.StoreInt64(&p.lastSaved, changes)
+	return nil
+}
+
+// changes sums the counters of all writes and removals.
+func (s *storage) changes() int64 {
+	c := &s.counters
+	return atomic.LoadInt64(&c.sets) + atomic.LoadInt64(&c.deletes) +
+		atomic.LoadInt64(&c.expirations) + atomic.LoadInt64(&c.evictions)
+}
+
+// writeSnapshotFile replaces path atomically with a checksummed snapshot.
+func writeSnapshotFile(path string, format SnapshotFormat, codec Codec, aead cipher.AEAD, entries entrySource) error {
+	var payload bytes.Buffer
+	if err := writeSnapshot(&payload, format, codec, aead, entries); err != nil {
+		return err
+	}
+	sum := sha256.Sum256(payload.Bytes())
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err = fmt.Fprintf(tmp, "%s %s %d\n", persistenceMagic, hex.EncodeToString(sum[:]), payload.Len()); err == nil {
+		_, err = payload.WriteTo(tmp)
+	}
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+func readSnapshotFile(path string, codec Codec, aead cipher.AEAD) ([]SnapshotEntry, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	reader := bufio.NewReader(file)
+	var magic, checksum string
+	var length int64
+	if _, err := fmt.Fscanf(reader, "%s %s %d\n", &magic, &checksum, &length); err != nil || magic != persistenceMagic {
+		return nil, ErrSnapshotCorrupt
+	}
+	// the length is checked against the file before the checksum can vouch for it
+	if length < 0 || length > info.Size() {
+		return nil, ErrSnapshotCorrupt
+	}
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(reader, payload); err != nil {
+		return nil, ErrSnapshotCorrupt
+	}
+	sum := sha256.Sum256(payload)
+	if hex.EncodeToString(sum[:]) != checksum {
+		return nil, ErrSnapshotCorrupt
+	}
+	return readSnapshot(bytes.NewReader(payload), codec, aead)
+}
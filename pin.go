@@ -0,0 +1,21 @@
+package addcache
+
+// Pin exempts the key from eviction by WithMaxEntries and WithPrefixLimit, e.g. for feature flags
+// or signing keys that must survive memory pressure. The pin belongs to the key, it stays across
+// overwrites and may be placed before the key is written. Delete and expiration still remove the
+// entry. A capacity whose entries are all pinned is exceeded rather than evicting them.
+func (s *storage) Pin(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.pinned == nil {
+		s.pinned = make(map[string]bool)
+	}
+	s.pinned[key] = true
+}
+
+// Unpin makes the key evictable again.
+func (s *storage) Unpin(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.pinned, key)
+}
@@ -0,0 +1,148 @@
+package addcache
+
+import (
+	"container/list"
+	"sync"
+)
+
+// EvictionPolicy picks the entries a capacity limited cache evicts, see WithEvictionPolicy.
+// OnSet and OnRemove are called with the write lock of the cache held, OnGet concurrently with
+// other reads, so implementations synchronize themselves.
+type EvictionPolicy interface {
+	// OnSet records a written key, new or overwritten.
+	OnSet(key string)
+	// OnGet records a read of a key.
+	OnGet(key string)
+	// OnRemove forgets a key that left the cache.
+	OnRemove(key string)
+	// Victim returns the key to evict next among those accepted by evictable, which rejects
+	// keys protected by a hold or retention rule, and false when there is none.
+	Victim(evictable func(key string) bool) (string, bool)
+}
+
+// WithEvictionPolicy replaces the least recently used order of WithMaxEntries, e.g. with
+// NewLFUPolicy. A policy instance must not be shared between caches.
+func WithEvictionPolicy(policy EvictionPolicy) Option {
+	return func(s *storage) {
+		s.evictionPolicy = policy
+	}
+}
+
+// NewLRUPolicy evicts the least recently used entry, the default of WithMaxEntries.
+func NewLRUPolicy() EvictionPolicy {
+	return lruPolicy{newLRUIndex()}
+}
+
+type lruPolicy struct {
+	*lruIndex
+}
+
+func (p lruPolicy) OnSet(key string) {
+	p.add(key)
+}
+
+func (p lruPolicy) OnGet(key string) {
+	p.touch(key)
+}
+
+func (p lruPolicy) OnRemove(key string) {
+	p.remove(key)
+}
+
+func (p lruPolicy) Victim(evictable func(key string) bool) (string, bool) {
+	return p.oldestMatching(evictable)
+}
+
+// NewLFUPolicy evicts the least frequently used entry, the least recently used one among equally
+// frequent entries. Frequencies count the reads and writes since the key was added, all updates
+// run in constant time.
+func NewLFUPolicy() EvictionPolicy {
+	return &lfuPolicy{
+		buckets: list.New(),
+		entries: make(map[string]*lfuEntry),
+	}
+}
+
+// lfuPolicy keeps buckets of equally frequent keys in ascending frequency, most recent keys first.
+type lfuPolicy struct {
+	mu      sync.Mutex
+	buckets *list.List
+	entries map[string]*lfuEntry
+}
+
+type lfuBucket struct {
+	frequency int
+	keys      *list.List
+}
+
+type lfuEntry struct {
+	bucket  *list.Element
+	element *list.Element
+}
+
+func (p *lfuPolicy) OnSet(key string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if _, ok := p.entries[key]; ok {
+		p.incrementLocked(key)
+		return
+	}
+	first := p.buckets.Front()
+	if first == nil || first.Value.(*lfuBucket).frequency != 1 {
+		first = p.buckets.PushFront(&lfuBucket{frequency: 1, keys: list.New()})
+	}
+	p.entries[key] = &lfuEntry{bucket: first, element: first.Value.(*lfuBucket).keys.PushFront(key)}
+}
+
+func (p *lfuPolicy) OnGet(key string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if _, ok := p.entries[key]; ok {
+		p.incrementLocked(key)
+	}
+}
+
+func (p *lfuPolicy) OnRemove(key string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if entry, ok := p.entries[key]; ok {
+		p.unlinkLocked(entry)
+		delete(p.entries, key)
+	}
+}
+
+func (p *lfuPolicy) Victim(evictable func(key string) bool) (string, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for bucket := p.buckets.Front(); bucket != nil; bucket = bucket.Next() {
+		for element := bucket.Value.(*lfuBucket).keys.Back(); element != nil; element = element.Prev() {
+			if key := element.Value.(string); evictable(key) {
+				return key, true
+			}
+		}
+	}
+	return "", false
+}
+
+// incrementLocked moves the key into the bucket of the next frequency.
+func (p *lfuPolicy) incrementLocked(key string) {
+	entry := p.entries[key]
+	current := entry.bucket
+	frequency := current.Value.(*lfuBucket).frequency + 1
+	next := current.Next()
+	if next == nil || next.Value.(*lfuBucket).frequency != frequency {
+		next = p.buckets.InsertAfter(&lfuBucket{frequency: frequency, keys: list.New()}, current)
+	}
+	p.unlinkLocked(entry)
+	entry.bucket = next
+	entry.element = next.Value.(*lfuBucket).keys.PushFront(key)
+}
+
+// unlinkLocked removes the key from its bucket and drops the bucket once it is empty.
+func (p *lfuPolicy) unlinkLocked(entry *lfuEntry) {
+	bucket := entry.bucket.Value.(*lfuBucket)
+	bucket.keys.Remove(entry.element)
+	if bucket.keys.Len() == 0 {
+		p.buckets.Remove(entry.bucket)
+	}
+}
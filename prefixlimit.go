@@ -0,0 +1,76 @@
+package addcache
+
+import "strings"
+
+// PrefixLimitPolicy decides what happens to a new key once its prefix is full.
+type PrefixLimitPolicy int
+
+const (
+	// PrefixEvictOldest evicts the least recently used key under the prefix.
+	PrefixEvictOldest PrefixLimitPolicy = iota
+	// PrefixRejectNew drops the write of the new key, existing keys can still be updated.
+	PrefixRejectNew
+)
+
+type prefixLimit struct {
+	prefix  string
+	maxKeys int
+	policy  PrefixLimitPolicy
+	keys    *lruIndex
+}
+
+// WithPrefixLimit caps the number of distinct keys starting with prefix.
+// Rejected writes are counted in Stats.Rejections and make Increment and GetAndSet return ErrCapacityExceeded.
+func WithPrefixLimit(prefix string, maxKeys int, policy PrefixLimitPolicy) Option {
+	return func(s *storage) {
+		if maxKeys <= 0 {
+			return
+		}
+		s.prefixLimits = append(s.prefixLimits, &prefixLimit{
+			prefix:  prefix,
+			maxKeys: maxKeys,
+			policy:  policy,
+			keys:    newLRUIndex(),
+		})
+	}
+}
+
+// prefixRejectsLocked reports whether a full prefix of the PrefixRejectNew policy rejects the key,
+// the caller holds a lock.
+func (s *storage) prefixRejectsLocked(key string) bool {
+	for _, limit := range s.prefixLimits {
+		if limit.full(key) && limit.policy == PrefixRejectNew {
+			return true
+		}
+	}
+	return false
+}
+
+// full reports whether the key is new under the limited prefix and finds no room.
+func (l *prefixLimit) full(key string) bool {
+	return strings.HasPrefix(key, l.prefix) && !l.keys.contains(key) && l.keys.len() >= l.maxKeys
+}
+
+// admitPrefixesLocked makes room for a new key under its limited prefixes, the caller holds the write lock.
+// It reports false when a prefix rejects the key, nothing is evicted in that case.
+func (s *storage) admitPrefixesLocked(key string) ([]removal, bool) {
+	if s.prefixRejectsLocked(key) {
+		return nil, false
+	}
+	var removals []removal
+	for _, limit := range s.prefixLimits {
+		if !limit.full(key) {
+			continue
+		}
+		for limit.keys.len() >= limit.maxKeys {
+			oldest, ok := limit.keys.oldestMatching(s.evictable)
+			if !ok {
+				break
+			}
+			sd := s.data[oldest]
+			s.deleteLocked(oldest)
+			removals = append(removals, removal{key: oldest, entry: sd, reason: RemovalEvicted, decision: DecisionQuota})
+		}
+	}
+	return removals, true
+}
@@ -0,0 +1,67 @@
+package addcache
+
+import (
+	"sort"
+	"time"
+)
+
+// Priority orders entries for eviction, lower priorities are evicted first. The eviction policy
+// picks among the entries of the lowest priority present.
+type Priority int
+
+const (
+	PriorityLow    Priority = -1
+	PriorityNormal Priority = 0
+	PriorityHigh   Priority = 1
+)
+
+// SetWithPriority stores the data like SetEx with the priority, a ttl of zero or less persists it.
+// Overwriting the key with another write resets it to PriorityNormal.
+func (s *storage) SetWithPriority(key string, data any, ttl time.Duration, priority Priority) {
+	sd := storageData{isPersistence: true, setTime: s.now(), data: data, priority: priority}
+	if ttl > 0 {
+		sd.isPersistence = false
+		sd.expireDuration = s.jitter.apply(ttl)
+	}
+	s.store(key, sd)
+}
+
+// countPriorityLocked accounts an entry entering (delta 1) or leaving (delta -1) the cache, only
+// entries outside PriorityNormal are counted. The caller holds the write lock.
+func (s *storage) countPriorityLocked(priority Priority, delta int) {
+	if priority == PriorityNormal {
+		return
+	}
+	if s.priorities == nil {
+		s.priorities = make(map[Priority]int)
+	}
+	s.priorities[priority] += delta
+	if s.priorities[priority] <= 0 {
+		delete(s.priorities, priority)
+	}
+}
+
+// priorityVictimLocked picks the victim of the eviction policy among the entries of the lowest
+// priority with an evictable entry, the caller holds the write lock.
+func (s *storage) priorityVictimLocked(evictable func(key string) bool) (string, bool) {
+	if len(s.priorities) == 0 {
+		return s.evictionVictimLocked(evictable)
+	}
+	levels := []Priority{PriorityNormal}
+	for priority := range s.priorities {
+		levels = append(levels, priority)
+	}
+	sort.Slice(levels, func(i, j int) bool {
+		return levels[i] < levels[j]
+	})
+	for _, level := range levels {
+		level := level
+		key, ok := s.evictionVictimLocked(func(key string) bool {
+			return s.data[key].priority == level && evictable(key)
+		})
+		if ok {
+			return key, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,176 @@
+// Package ratelimit throttles keys such as user ids or client addresses with limiters keeping
+// their state in an addcache.Cache. State entries expire once they carry no information anymore,
+// so idle keys are reclaimed by the cleanup of the cache.
+//
+// Updates of a key are serialized with the LockKey of caches providing it, such as
+// addcache.LocalCache, and with a lock of the limiter otherwise. They are atomic within the
+// process only, limiters on a shared remote cache may let a few more requests pass.
+package ratelimit
+
+import (
+	"encoding/gob"
+	"math"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/addit-digital/addcache"
+)
+
+const (
+	defaultKeyPrefix = "ratelimit"
+	// minWindow is the shortest window of a SlidingWindow, shorter ones are raised to it.
+	minWindow = time.Millisecond
+)
+
+// Limiter decides whether requests of a key may proceed.
+type Limiter interface {
+	// Allow reports whether one request of key may proceed and accounts it if so.
+	Allow(key string) bool
+	// AllowN reports whether n requests of key may proceed at once and accounts them if so.
+	AllowN(key string, n int) bool
+}
+
+// Option configures NewTokenBucket and NewSlidingWindow.
+type Option func(c *config)
+
+// WithKeyPrefix sets the prefix of the state keys, "ratelimit" by default. Limiters sharing a
+// cache need distinct prefixes.
+func WithKeyPrefix(prefix string) Option {
+	return func(c *config) {
+		c.keyPrefix = prefix
+	}
+}
+
+type config struct {
+	cache     addcache.Cache
+	keyPrefix string
+	lock      func(key string) func()
+}
+
+func newConfig(cache addcache.Cache, options []Option) config {
+	c := config{cache: cache, keyPrefix: defaultKeyPrefix}
+	for _, option := range options {
+		option(&c)
+	}
+	if locker, ok := cache.(interface{ LockKey(key string) func() }); ok {
+		c.lock = locker.LockKey
+	} else {
+		var mu sync.Mutex
+		c.lock = func(string) func() {
+			mu.Lock()
+			return mu.Unlock
+		}
+	}
+	return c
+}
+
+// Bucket is the stored state of a TokenBucket key, it is registered with gob for caches encoding values.
+type Bucket struct {
+	Tokens float64
+	Last   time.Time
+}
+
+func init() {
+	gob.Register(Bucket{})
+}
+
+// TokenBucket lets bursts of up to burst requests pass and refills rate tokens per second.
+type TokenBucket struct {
+	config
+	rate  float64
+	burst int
+}
+
+var _ Limiter = (*TokenBucket)(nil)
+
+// NewTokenBucket limits keys to rate requests per second with bursts of burst requests.
+func NewTokenBucket(cache addcache.Cache, rate float64, burst int, options ...Option) *TokenBucket {
+	return &TokenBucket{config: newConfig(cache, options), rate: rate, burst: burst}
+}
+
+func (tb *TokenBucket) Allow(key string) bool {
+	return tb.AllowN(key, 1)
+}
+
+func (tb *TokenBucket) AllowN(key string, n int) bool {
+	stateKey := tb.keyPrefix + ":" + key
+	unlock := tb.lock(stateKey)
+	defer unlock()
+	now := time.Now()
+	bucket := Bucket{Tokens: float64(tb.burst), Last: now}
+	if data, err := tb.cache.Get(stateKey); err == nil {
+		if stored, ok := data.(Bucket); ok {
+			bucket = stored
+			elapsed := now.Sub(bucket.Last).Seconds()
+			bucket.Tokens = math.Min(float64(tb.burst), bucket.Tokens+math.Max(elapsed, 0)*tb.rate)
+			bucket.Last = now
+		}
+	}
+	if bucket.Tokens < float64(n) {
+		return false
+	}
+	bucket.Tokens -= float64(n)
+	tb.cache.SetEx(stateKey, bucket, tb.refill(bucket.Tokens))
+	return true
+}
+
+// refill returns how long the bucket takes to fill up from tokens, afterwards it is a fresh bucket.
+func (tb *TokenBucket) refill(tokens float64) time.Duration {
+	if tb.rate <= 0 {
+		return 24 * time.Hour
+	}
+	return time.Duration((float64(tb.burst)-tokens)/tb.rate*float64(time.Second)) + time.Second
+}
+
+// SlidingWindow lets limit requests pass within any window, estimated from the counts of the
+// current and the previous fixed window weighted by their overlap with the sliding one.
+type SlidingWindow struct {
+	config
+	limit  int
+	window time.Duration
+}
+
+var _ Limiter = (*SlidingWindow)(nil)
+
+// NewSlidingWindow limits keys to limit requests per window, windows below a millisecond are
+// raised to one.
+func NewSlidingWindow(cache addcache.Cache, limit int, window time.Duration, options ...Option) *SlidingWindow {
+	if window < minWindow {
+		window = minWindow
+	}
+	return &SlidingWindow{config: newConfig(cache, options), limit: limit, window: window}
+}
+
+func (sw *SlidingWindow) Allow(key string) bool {
+	return sw.AllowN(key, 1)
+}
+
+func (sw *SlidingWindow) AllowN(key string, n int) bool {
+	baseKey := sw.keyPrefix + ":" + key
+	unlock := sw.lock(baseKey)
+	defer unlock()
+	now := time.Now()
+	index := now.UnixNano() / int64(sw.window)
+	currentKey := baseKey + ":" + strconv.FormatInt(index, 10)
+	current := sw.count(currentKey)
+	previous := sw.count(baseKey + ":" + strconv.FormatInt(index-1, 10))
+	elapsed := float64(now.UnixNano()%int64(sw.window)) / float64(sw.window)
+	if float64(previous)*(1-elapsed)+float64(current+n) > float64(sw.limit) {
+		return false
+	}
+	sw.cache.SetEx(currentKey, int64(current+n), 2*sw.window)
+	return true
+}
+
+func (sw *SlidingWindow) count(key string) int {
+	data, err := sw.cache.Get(key)
+	if err != nil {
+		return 0
+	}
+	count, ok := data.(int64)
+	if !ok {
+		return 0
+	}
+	return int(count)
+}
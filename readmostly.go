@@ -0,0 +1,99 @@
+package addcache
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// readMostly mirrors the entries into a sync.Map and the epochs into an immutable *epochView swapped
+// on every bump, so reads find an entry and check it for expiry and invalidation without s.mu.
+// Writers still serialize on s.mu and pay for the extra copy. Writes of many keys that readers
+// must see at once are framed by beginBatch and endBatch, batches is odd while one runs and
+// reads overlapping it take the read lock instead, like a seqlock.
+type readMostly struct {
+	batches uint64
+	entries sync.Map
+	epochs  atomic.Value
+}
+
+// epochView is a copy of the epochs of the storage, it is never modified once published.
+type epochView struct {
+	epochs  map[string]prefixEpoch
+	lengths []int
+}
+
+// WithReadMostly makes Get and GetResult lock free for read-mostly workloads. Reads scale with
+// the cores instead of contending on the read lock while writes get slower, they update a
+// sync.Map beside the map of the cache. It pays off on many cores with reads outnumbering writes
+// by far, on a few cores or with frequent writes the default locking is faster. SwapGeneration and
+// ExpireGroupAt stay atomic to readers, reads running alongside them fall back to the read lock.
+func WithReadMostly() Option {
+	return func(s *storage) {
+		s.readMostly = &readMostly{}
+		s.readMostly.epochs.Store(&epochView{})
+	}
+}
+
+// store mirrors a written entry, the caller holds the write lock.
+func (r *readMostly) store(key string, sd storageData) {
+	if r != nil {
+		r.entries.Store(key, sd)
+	}
+}
+
+// delete mirrors a removal, the caller holds the write lock.
+func (r *readMostly) delete(key string) {
+	if r != nil {
+		r.entries.Delete(key)
+	}
+}
+
+// publishEpochsLocked swaps in a copy of the current epochs, the caller holds the write lock.
+func (s *storage) publishEpochsLocked() {
+	if s.readMostly == nil {
+		return
+	}
+	view := &epochView{
+		epochs:  make(map[string]prefixEpoch, len(s.epochs)),
+		lengths: append([]int(nil), s.epochLengths...),
+	}
+	for prefix, epoch := range s.epochs {
+		view.epochs[prefix] = epoch
+	}
+	s.readMostly.epochs.Store(view)
+}
+
+// beginBatch starts a write of many keys, the caller holds the write lock.
+func (r *readMostly) beginBatch() {
+	if r != nil {
+		atomic.AddUint64(&r.batches, 1)
+	}
+}
+
+// endBatch ends the batch of beginBatch before the write lock is released.
+func (r *readMostly) endBatch() {
+	if r != nil {
+		atomic.AddUint64(&r.batches, 1)
+	}
+}
+
+// load is the lock free counterpart of the locked read in lookup. It reports false for done
+// when it overlapped a batch, the read has to be repeated under the read lock then.
+func (r *readMostly) load(key string, now time.Time) (value storageData, ok, expired, stale, done bool) {
+	batches := atomic.LoadUint64(&r.batches)
+	if batches%2 == 1 {
+		return storageData{}, false, false, false, false
+	}
+	if entry, found := r.entries.Load(key); found {
+		value, ok = entry.(storageData), true
+		view := r.epochs.Load().(*epochView)
+		bumped := bumpedSince(view.epochs, view.lengths, key, value)
+		expired = value.isExpired(now) || !value.held && bumped
+		stale = value.held && (value.deadlinePassed(now) || bumped)
+	}
+	if atomic.LoadUint64(&r.batches) != batches {
+		return storageData{}, false, false, false, false
+	}
+	return value, ok, expired, stale, true
+}
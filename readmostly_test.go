@@ -0,0 +1,20 @@
+package addcache
+
+import (
+	"strconv"
+	"testing"
+)
+
+// BenchmarkReadMostly compares WithReadMostly to the default read lock at falling write
+// shares. The read-mostly path wins once reads outnumber writes by far and many cores contend
+// on the read lock, run with -cpu 1,4,16 to find the crossover of a machine.
+func BenchmarkReadMostly(b *testing.B) {
+	for _, writeEvery := range []int{2, 10, 100, 1000} {
+		b.Run("RWMutex/1:"+strconv.Itoa(writeEvery), func(b *testing.B) {
+			benchmarkReadHeavy(b, writeEvery)
+		})
+		b.Run("ReadMostly/1:"+strconv.Itoa(writeEvery), func(b *testing.B) {
+			benchmarkReadHeavy(b, writeEvery, WithReadMostly())
+		})
+	}
+}
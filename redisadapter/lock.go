@@ -0,0 +1,100 @@
+package redisadapter
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/addit-digital/addcache"
+	"github.com/redis/go-redis/v9"
+)
+
+// fenceSuffix names the counter issuing the fencing tokens of a lock key.
+const fenceSuffix = ":fence"
+
+// unlockScript deletes the lock key while it still holds the token.
+var unlockScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0`)
+
+// extendScript restarts the TTL of the lock key while it still holds the token, zero persists it.
+var extendScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) ~= ARGV[1] then
+	return 0
+end
+if tonumber(ARGV[2]) > 0 then
+	return redis.call("PEXPIRE", KEYS[1], ARGV[2])
+end
+return redis.call("PERSIST", KEYS[1]) + 1`)
+
+// TryLock acquires the key with SET NX for ttl, zero or less keeps it until Unlock, like
+// addcache.LocalCache.TryLock but shared by every instance using the Redis server. Fencing tokens
+// are issued by INCR on the key with a ":fence" suffix, which is kept persistent.
+func (c *Cache) TryLock(key string, ttl time.Duration) (addcache.Lock, bool) {
+	ctx, cancel := c.context()
+	defer cancel()
+	token, err := c.client.Incr(ctx, c.prefix+key+fenceSuffix).Result()
+	if err != nil {
+		c.onError("TryLock", key, c.translate(err))
+		return nil, false
+	}
+	if ttl < 0 {
+		ttl = 0
+	}
+	acquired, err := c.client.SetNX(ctx, c.prefix+key, token, ttl).Result()
+	if err != nil {
+		c.onError("TryLock", key, c.translate(err))
+		return nil, false
+	}
+	if !acquired {
+		return nil, false
+	}
+	c.hooks.Run(addcache.CreateOperation, key, token)
+	return &lock{cache: c, key: key, token: uint64(token)}, true
+}
+
+type lock struct {
+	cache *Cache
+	key   string
+	token uint64
+}
+
+func (l *lock) Key() string {
+	return l.key
+}
+
+func (l *lock) Token() uint64 {
+	return l.token
+}
+
+func (l *lock) Unlock() error {
+	ctx, cancel := l.cache.context()
+	defer cancel()
+	released, err := unlockScript.Run(ctx, l.cache.client, []string{l.cache.prefix + l.key}, l.value()).Int()
+	if err != nil {
+		return l.cache.translate(err)
+	}
+	if released == 0 {
+		return addcache.ErrLockNotHeld
+	}
+	l.cache.hooks.Run(addcache.DeleteOperation, l.key, int64(l.token))
+	return nil
+}
+
+func (l *lock) Extend(ttl time.Duration) error {
+	ctx, cancel := l.cache.context()
+	defer cancel()
+	extended, err := extendScript.Run(ctx, l.cache.client, []string{l.cache.prefix + l.key}, l.value(), ttl.Milliseconds()).Int()
+	if err != nil {
+		return l.cache.translate(err)
+	}
+	if extended == 0 {
+		return addcache.ErrLockNotHeld
+	}
+	return nil
+}
+
+func (l *lock) value() string {
+	return strconv.FormatUint(l.token, 10)
+}
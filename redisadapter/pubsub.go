@@ -0,0 +1,50 @@
+package redisadapter
+
+import (
+	"context"
+	"sync"
+
+	"github.com/addit-digital/addcache/cluster"
+	"github.com/redis/go-redis/v9"
+)
+
+// PubSubTransport is a cluster.Transport on a Redis pub/sub channel. Messages published while
+// a process is disconnected are lost, as with every pub/sub delivery.
+type PubSubTransport struct {
+	client  redis.UniversalClient
+	channel string
+}
+
+var _ cluster.Transport = (*PubSubTransport)(nil)
+
+// NewPubSubTransport uses channel on client, which stays owned by the caller.
+func NewPubSubTransport(client redis.UniversalClient, channel string) *PubSubTransport {
+	return &PubSubTransport{client: client, channel: channel}
+}
+
+func (t *PubSubTransport) Publish(payload []byte) error {
+	return t.client.Publish(context.Background(), t.channel, payload).Err()
+}
+
+// Subscribe waits for the subscription to be confirmed, so no later publication is missed.
+func (t *PubSubTransport) Subscribe(handler func(payload []byte)) (func(), error) {
+	ctx := context.Background()
+	pubsub := t.client.Subscribe(ctx, t.channel)
+	if _, err := pubsub.Receive(ctx); err != nil {
+		pubsub.Close()
+		return nil, err
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for message := range pubsub.Channel() {
+			handler([]byte(message.Payload))
+		}
+	}()
+	return func() {
+		pubsub.Close()
+		wg.Wait()
+	}, nil
+}
@@ -0,0 +1,349 @@
+// Package redisadapter implements addcache.Cache on top of a go-redis client, so the in memory
+// cache and Redis can be swapped behind one interface. It needs Redis 6.2 or newer.
+package redisadapter
+
+import (
+	"context"
+	"errors"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/addit-digital/addcache"
+	"github.com/redis/go-redis/v9"
+)
+
+// Values are stored with a leading tag byte, integers are stored untagged as decimal text so
+// Increment works on them.
+const (
+	tagCodec  byte = 1
+	tagString byte = 2
+	tagBytes  byte = 3
+)
+
+// ErrorHandler receives failed commands of methods without error result.
+type ErrorHandler func(operation string, key string, err error)
+
+// Cache is an addcache.Cache backed by Redis. Hooks are local to the process that registered them.
+type Cache struct {
+	client     redis.UniversalClient
+	codec      addcache.Codec
+	timeout    time.Duration
+	defaultTTL time.Duration
+	prefix     string
+	onError    ErrorHandler
+	hooks      addcache.Hooks
+}
+
+var _ addcache.Cache = (*Cache)(nil)
+
+// Option configures New.
+type Option func(c *Cache)
+
+// WithCodec encodes values other than strings, byte slices and integers, gob is the default.
+// Values are decoded into any, so gob needs concrete types registered with gob.Register.
+func WithCodec(codec addcache.Codec) Option {
+	return func(c *Cache) {
+		c.codec = codec
+	}
+}
+
+// WithDefaultTTL makes Set and GetAndSet expire after ttl like addcache.WithDefaultTTL.
+func WithDefaultTTL(ttl time.Duration) Option {
+	return func(c *Cache) {
+		c.defaultTTL = ttl
+	}
+}
+
+// WithTimeout bounds every command, zero waits as long as the client does.
+func WithTimeout(timeout time.Duration) Option {
+	return func(c *Cache) {
+		c.timeout = timeout
+	}
+}
+
+// WithKeyPrefix namespaces all keys in Redis, hooks still see the keys without prefix.
+func WithKeyPrefix(prefix string) Option {
+	return func(c *Cache) {
+		c.prefix = prefix
+	}
+}
+
+// WithErrorHandler replaces the default handler, which logs failed commands.
+func WithErrorHandler(handler ErrorHandler) Option {
+	return func(c *Cache) {
+		if handler != nil {
+			c.onError = handler
+		}
+	}
+}
+
+// WithHookErrorHandler receives panics recovered from hooks, they are logged by default.
+func WithHookErrorHandler(handler addcache.HookErrorHandler) Option {
+	return func(c *Cache) {
+		c.hooks.ErrorHandler = handler
+	}
+}
+
+// New wraps client, which stays owned by the caller.
+func New(client redis.UniversalClient, options ...Option) *Cache {
+	c := &Cache{
+		client:  client,
+		codec:   addcache.GobCodec{},
+		onError: logError,
+	}
+	for _, option := range options {
+		option(c)
+	}
+	return c
+}
+
+func logError(operation string, key string, err error) {
+	log.Printf("addcache/redisadapter: %s for key %q failed: %v", operation, key, err)
+}
+
+// Set persists the data, or expires it after the WithDefaultTTL duration when configured.
+func (c *Cache) Set(key string, data any) {
+	if err := c.write(key, data, c.defaultTTL); err != nil {
+		c.onError("Set", key, err)
+	}
+}
+
+func (c *Cache) SetPersistent(key string, data any) {
+	if err := c.write(key, data, 0); err != nil {
+		c.onError("SetPersistent", key, err)
+	}
+}
+
+func (c *Cache) SetEx(key string, data any, duration time.Duration) {
+	if err := c.write(key, data, duration); err != nil {
+		c.onError("SetEx", key, err)
+	}
+}
+
+func (c *Cache) Get(key string) (any, error) {
+	ctx, cancel := c.context()
+	defer cancel()
+	raw, err := c.client.Get(ctx, c.prefix+key).Bytes()
+	if err != nil {
+		return nil, c.translate(err)
+	}
+	return c.decode(raw)
+}
+
+func (c *Cache) Delete(key string) {
+	if _, err := c.GetAndDelete(key); err != nil && !errors.Is(err, addcache.ErrCacheKeyNotFound) {
+		c.onError("Delete", key, err)
+	}
+}
+
+func (c *Cache) GetAndDelete(key string) (any, error) {
+	ctx, cancel := c.context()
+	defer cancel()
+	raw, err := c.client.GetDel(ctx, c.prefix+key).Bytes()
+	if err != nil {
+		return nil, c.translate(err)
+	}
+	data, err := c.decode(raw)
+	if err != nil {
+		return nil, err
+	}
+	c.hooks.Run(addcache.DeleteOperation, key, data)
+	return data, nil
+}
+
+// GetAndSet stores newData like Set and returns the data it replaced.
+// ErrCacheKeyNotFound is returned when there was nothing to replace, newData is stored anyway.
+func (c *Cache) GetAndSet(key string, newData any) (any, error) {
+	raw, err := c.encode(newData)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := c.context()
+	defer cancel()
+	previous, err := c.client.SetArgs(ctx, c.prefix+key, raw, redis.SetArgs{Get: true, TTL: c.defaultTTL}).Bytes()
+	if err != nil && !errors.Is(err, redis.Nil) {
+		return nil, c.translate(err)
+	}
+	c.hooks.Run(addcache.CreateOperation, key, newData)
+	if err != nil {
+		return nil, c.translate(err)
+	}
+	return c.decode(previous)
+}
+
+func (c *Cache) Increment(key string, delta int64) (int64, error) {
+	ctx, cancel := c.context()
+	defer cancel()
+	value, err := c.client.IncrBy(ctx, c.prefix+key, delta).Result()
+	if err != nil {
+		if strings.Contains(err.Error(), "not an integer") {
+			return 0, addcache.ErrCacheValueNotInteger
+		}
+		return 0, err
+	}
+	c.hooks.Run(addcache.CreateOperation, key, value)
+	return value, nil
+}
+
+func (c *Cache) Decrement(key string, delta int64) (int64, error) {
+	return c.Increment(key, -delta)
+}
+
+func (c *Cache) CreateKey(args ...string) string {
+	return c.CreateKeyWithDelimiter(":", args...)
+}
+
+func (c *Cache) CreateKeyWithDelimiter(delimiter string, args ...string) string {
+	return strings.Join(args, delimiter)
+}
+
+// StopCleanup is a no-op, Redis expires keys itself.
+func (c *Cache) StopCleanup() {}
+
+// Close is a no-op, the client is owned by the caller.
+func (c *Cache) Close() error {
+	return nil
+}
+
+func (c *Cache) SetHook(operationType addcache.OperationType, handlerFunctions ...addcache.HandlerFunc) addcache.HookID {
+	return c.hooks.SetHook(operationType, handlerFunctions...)
+}
+
+func (c *Cache) RemoveHook(operationType addcache.OperationType, id addcache.HookID) {
+	c.hooks.RemoveHook(operationType, id)
+}
+
+func (c *Cache) ClearHooks(operationType addcache.OperationType) {
+	c.hooks.ClearHooks(operationType)
+}
+
+// write stores the data and runs the Create hooks, a ttl of zero persists.
+func (c *Cache) write(key string, data any, ttl time.Duration) error {
+	raw, err := c.encode(data)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := c.context()
+	defer cancel()
+	if err = c.client.Set(ctx, c.prefix+key, raw, ttl).Err(); err != nil {
+		return c.translate(err)
+	}
+	c.hooks.Run(addcache.CreateOperation, key, data)
+	return nil
+}
+
+// Backend exposes the cache as the L2 of an addcache.TieredCache.
+func (c *Cache) Backend() addcache.Backend {
+	return backend{cache: c}
+}
+
+type backend struct {
+	cache *Cache
+}
+
+func (b backend) Get(key string) (any, error) {
+	return b.cache.Get(key)
+}
+
+// GetWithTTL reads the value and its PTTL in one round trip.
+func (b backend) GetWithTTL(key string) (any, time.Duration, error) {
+	ctx, cancel := b.cache.context()
+	defer cancel()
+	var get *redis.StringCmd
+	var ttl *redis.DurationCmd
+	_, err := b.cache.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		get = pipe.Get(ctx, b.cache.prefix+key)
+		ttl = pipe.PTTL(ctx, b.cache.prefix+key)
+		return nil
+	})
+	if err != nil {
+		return nil, 0, b.cache.translate(err)
+	}
+	data, err := b.cache.decode([]byte(get.Val()))
+	if err != nil {
+		return nil, 0, err
+	}
+	remaining := ttl.Val()
+	if remaining < 0 {
+		remaining = 0
+	}
+	return data, remaining, nil
+}
+
+func (b backend) Set(key string, data any, ttl time.Duration) error {
+	return b.cache.write(key, data, ttl)
+}
+
+func (b backend) Delete(key string) error {
+	_, err := b.cache.GetAndDelete(key)
+	if errors.Is(err, addcache.ErrCacheKeyNotFound) {
+		return nil
+	}
+	return err
+}
+
+func (b backend) Increment(key string, delta int64) (int64, error) {
+	return b.cache.Increment(key, delta)
+}
+
+func (c *Cache) context() (context.Context, context.CancelFunc) {
+	if c.timeout > 0 {
+		return context.WithTimeout(context.Background(), c.timeout)
+	}
+	return context.Background(), func() {}
+}
+
+func (c *Cache) translate(err error) error {
+	if errors.Is(err, redis.Nil) {
+		return addcache.ErrCacheKeyNotFound
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return addcache.ErrTimeout
+	}
+	return err
+}
+
+func (c *Cache) encode(data any) ([]byte, error) {
+	switch v := data.(type) {
+	case string:
+		return append([]byte{tagString}, v...), nil
+	case []byte:
+		return append([]byte{tagBytes}, v...), nil
+	case int:
+		return strconv.AppendInt(nil, int64(v), 10), nil
+	case int64:
+		return strconv.AppendInt(nil, v, 10), nil
+	case int32:
+		return strconv.AppendInt(nil, int64(v), 10), nil
+	}
+	raw, err := c.codec.Marshal(&data)
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte{tagCodec}, raw...), nil
+}
+
+// decode reverses encode, integers are returned as int64.
+func (c *Cache) decode(raw []byte) (any, error) {
+	if len(raw) == 0 {
+		return "", nil
+	}
+	switch raw[0] {
+	case tagString:
+		return string(raw[1:]), nil
+	case tagBytes:
+		return raw[1:], nil
+	case tagCodec:
+		var data any
+		if err := c.codec.Unmarshal(raw[1:], &data); err != nil {
+			return nil, err
+		}
+		return data, nil
+	}
+	if value, err := strconv.ParseInt(string(raw), 10, 64); err == nil {
+		return value, nil
+	}
+	return string(raw), nil
+}
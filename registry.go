@@ -0,0 +1,80 @@
+package addcache
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+)
+
+var (
+	registryMu sync.RWMutex
+	registry   = make(map[string]LocalCache)
+)
+
+// register adds named caches to the registry, a later instance with the same name replaces the earlier one.
+func register(s *storage) {
+	if s.name == "" {
+		return
+	}
+	registryMu.Lock()
+	registry[s.name] = s
+	registryMu.Unlock()
+}
+
+func unregister(s *storage) {
+	if s.name == "" {
+		return
+	}
+	registryMu.Lock()
+	if registered, ok := registry[s.name]; ok && registered == LocalCache(s) {
+		delete(registry, s.name)
+	}
+	registryMu.Unlock()
+}
+
+// Instances returns the registered cache instances by name.
+func Instances() map[string]LocalCache {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	instances := make(map[string]LocalCache, len(registry))
+	for name, cache := range registry {
+		instances[name] = cache
+	}
+	return instances
+}
+
+// AggregatedStats is the stats view over all registered instances.
+type AggregatedStats struct {
+	Total     Stats            `json:"total"`
+	Instances map[string]Stats `json:"instances"`
+}
+
+// CollectStats gathers the stats of all registered instances and their sum.
+func CollectStats() AggregatedStats {
+	aggregated := AggregatedStats{Instances: make(map[string]Stats)}
+	for name, cache := range Instances() {
+		stats := cache.Stats()
+		aggregated.Instances[name] = stats
+		aggregated.Total = aggregated.Total.add(stats)
+	}
+	return aggregated
+}
+
+// StatsHandler serves CollectStats as JSON, instances can be filtered with the name query parameter.
+func StatsHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		aggregated := CollectStats()
+		if names, ok := r.URL.Query()["name"]; ok {
+			filtered := AggregatedStats{Instances: make(map[string]Stats)}
+			for _, name := range names {
+				if stats, ok := aggregated.Instances[name]; ok {
+					filtered.Instances[name] = stats
+					filtered.Total = filtered.Total.add(stats)
+				}
+			}
+			aggregated = filtered
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(aggregated)
+	})
+}
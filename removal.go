@@ -0,0 +1,85 @@
+package addcache
+
+// RemovalReason tells why an entry left the cache.
+type RemovalReason int
+
+const (
+	// RemovalExpired is reported when the entry outlived its expiration.
+	RemovalExpired RemovalReason = iota
+	// RemovalDeleted is reported for explicit deletes.
+	RemovalDeleted
+	// RemovalEvicted is reported when the entry was dropped to respect capacity limits.
+	RemovalEvicted
+	// RemovalReplaced is reported for the previous data when a key is set again.
+	RemovalReplaced
+)
+
+func (r RemovalReason) String() string {
+	switch r {
+	case RemovalExpired:
+		return "Expired"
+	case RemovalDeleted:
+		return "Deleted"
+	case RemovalEvicted:
+		return "Evicted"
+	case RemovalReplaced:
+		return "Replaced"
+	}
+	return "Unknown"
+}
+
+type removal struct {
+	key      string
+	entry    storageData
+	reason   RemovalReason
+	decision Decision
+}
+
+type EvictedFunc func(key string, data any, reason RemovalReason)
+
+// OnEvicted registers functions called whenever data leaves the cache, including replacement by a new Set.
+func (s *storage) OnEvicted(evictedFunctions ...EvictedFunc) {
+	s.hooksMu.Lock()
+	s.evicted = append(s.evicted, evictedFunctions...)
+	s.hooksMu.Unlock()
+}
+
+// finishRemovals accounts and notifies removals collected while the write lock was held, their
+// events were queued by queueRemovalsLocked.
+func (s *storage) finishRemovals(removals ...removal) {
+	s.deliverQueued()
+	for _, r := range removals {
+		switch r.reason {
+		case RemovalDeleted:
+			s.countersOf(r.key).delete()
+		case RemovalExpired:
+			s.countersOf(r.key).expire()
+		case RemovalEvicted:
+			s.countersOf(r.key).evict()
+		}
+		s.logDecision(r)
+		s.notifyRemoval(r.key, s.valueOf(r.entry.data), r.reason)
+	}
+	s.notifyExpiredBatch(removals)
+}
+
+// notifyRemoval runs the Delete hooks, except for replaced data, and the eviction callbacks.
+func (s *storage) notifyRemoval(key string, data any, reason RemovalReason) {
+	operationType := DeleteOperation
+	if reason == RemovalReplaced {
+		operationType = EvictedOperation
+	}
+	s.dispatchHooks(operationType, key, func() {
+		if reason != RemovalReplaced {
+			s.runHooks(DeleteOperation, key, data)
+		}
+		s.hooksMu.RLock()
+		evictedFunctions := s.evicted
+		s.hooksMu.RUnlock()
+		for _, evictedFunction := range evictedFunctions {
+			s.safeCall(EvictedOperation, key, func() {
+				evictedFunction(key, data, reason)
+			})
+		}
+	})
+}
@@ -0,0 +1,65 @@
+package addcache
+
+import (
+	"errors"
+	"time"
+)
+
+// Source names the layer a Result was served from.
+type Source string
+
+const (
+	SourceL1     Source = "L1"
+	SourceL2     Source = "L2"
+	SourceLoader Source = "loader"
+)
+
+// Result is a value read together with its metadata.
+// TTL is the remaining lifetime, zero for persistent entries. Version counts the writes of the key
+// since it was created. Stale marks a value kept past its expiration or invalidation by a hold.
+type Result struct {
+	Value      any
+	TTL        time.Duration
+	Persistent bool
+	Version    uint64
+	Source     Source
+	Stale      bool
+}
+
+// GetResult reads the key like Get and reports the metadata of the entry.
+func (s *storage) GetResult(key string) (Result, error) {
+	sd, stale, err := s.lookup(key)
+	if errors.Is(err, ErrCacheKeyNotFound) {
+		var value any
+		if value, stale, err = s.loadMissing(key, sd); err == nil && !stale {
+			return s.loadedResult(key, value), nil
+		}
+	}
+	if err != nil {
+		return Result{}, err
+	}
+	value, err := s.decodeValue(sd.data)
+	if err != nil {
+		return Result{}, err
+	}
+	result := Result{
+		Value:      value,
+		Persistent: sd.isPersistence,
+		Version:    sd.version,
+		Source:     SourceL1,
+		Stale:      stale,
+	}
+	if remaining := sd.setTime.Add(sd.expireDuration).Sub(s.now()); !sd.isPersistence && remaining > 0 {
+		result.TTL = remaining
+	}
+	return result, nil
+}
+
+// loadedResult reports a value just loaded into the cache with the metadata of its new entry.
+func (s *storage) loadedResult(key string, value any) Result {
+	result := Result{Value: value, Persistent: true, Source: SourceLoader}
+	if info, err := s.Inspect(key); err == nil {
+		result.Persistent, result.TTL, result.Version = info.Persistent, info.TTL, info.Version
+	}
+	return result
+}
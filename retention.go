@@ -0,0 +1,95 @@
+package addcache
+
+import (
+	"sort"
+	"strings"
+	"time"
+)
+
+// RetentionPolicy bounds how long entries under a prefix may live.
+// MinLifetime keeps entries from being deleted, evicted or expired earlier,
+// MaxLifetime caps every TTL and turns persistent writes into expiring ones. Zero disables a bound.
+type RetentionPolicy struct {
+	MinLifetime time.Duration
+	MaxLifetime time.Duration
+}
+
+type retentionRule struct {
+	prefix string
+	policy RetentionPolicy
+}
+
+// WithRetention applies the policy to keys starting with prefix, the longest matching prefix wins.
+func WithRetention(prefix string, policy RetentionPolicy) Option {
+	return func(s *storage) {
+		s.retention = append(s.retention, retentionRule{prefix: prefix, policy: policy})
+		sort.SliceStable(s.retention, func(i, j int) bool {
+			return len(s.retention[i].prefix) > len(s.retention[j].prefix)
+		})
+	}
+}
+
+func (s *storage) retentionFor(key string) (RetentionPolicy, bool) {
+	for _, rule := range s.retention {
+		if strings.HasPrefix(key, rule.prefix) {
+			return rule.policy, true
+		}
+	}
+	return RetentionPolicy{}, false
+}
+
+// applyRetention clamps the lifetime of an entry about to be written to the policy of its key.
+func (s *storage) applyRetention(key string, sd storageData) storageData {
+	policy, ok := s.retentionFor(key)
+	if !ok {
+		return sd
+	}
+	if policy.MaxLifetime > 0 && (sd.isPersistence || sd.expireDuration > policy.MaxLifetime) {
+		sd.isPersistence = false
+		sd.expireDuration = policy.MaxLifetime
+	}
+	if policy.MinLifetime > 0 && !sd.isPersistence && sd.expireDuration < policy.MinLifetime {
+		sd.expireDuration = policy.MinLifetime
+	}
+	return sd
+}
+
+// protected reports whether the entry is on hold or younger than its minimum lifetime.
+func (s *storage) protected(key string, sd storageData, now time.Time) bool {
+	if sd.held {
+		return true
+	}
+	policy, ok := s.retentionFor(key)
+	return ok && policy.MinLifetime > 0 && now.Sub(sd.setTime) < policy.MinLifetime
+}
+
+// evictable is the victim filter of capacity and prefix limits, it spares pinned and protected keys.
+func (s *storage) evictable(key string) bool {
+	sd, ok := s.data[key]
+	return ok && !s.pinned[key] && !s.protected(key, sd, s.now())
+}
+
+// Hold places a legal hold on the key, it is neither deleted, evicted, expired nor overwritten until released.
+func (s *storage) Hold(key string) error {
+	return s.setHold(key, true)
+}
+
+// Release lifts a hold, an entry that expired meanwhile is removed by the next cleanup or access.
+func (s *storage) Release(key string) error {
+	return s.setHold(key, false)
+}
+
+func (s *storage) setHold(key string, held bool) error {
+	if err := s.readable(); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sd, ok := s.data[key]
+	if !ok {
+		return ErrCacheKeyNotFound
+	}
+	sd.held = held
+	s.putLocked(key, sd)
+	return nil
+}
@@ -0,0 +1,98 @@
+package addcache
+
+import (
+	"sort"
+	"strconv"
+	"sync"
+)
+
+const defaultVirtualNodes = 100
+
+// Ring is a consistent hash ring mapping keys to nodes, adding or removing a node only moves
+// the keys of its own share. Nodes are placed with FNVHasher by default, so every process with
+// the same nodes maps keys alike.
+type Ring struct {
+	virtualNodes int
+	hasher       Hasher
+	mu           sync.RWMutex
+	points       []uint64
+	owners       map[uint64]string
+	nodes        []string
+}
+
+// NewRing places every node virtualNodes times on the ring, zero or less selects 100.
+func NewRing(virtualNodes int, nodes ...string) *Ring {
+	return NewRingWithHasher(virtualNodes, nil, nodes...)
+}
+
+// NewRingWithHasher is NewRing placing nodes and keys with hasher, nil selects FNVHasher. The
+// hasher must map keys alike in every process sharing the nodes, MaphashHasher does not.
+func NewRingWithHasher(virtualNodes int, hasher Hasher, nodes ...string) *Ring {
+	if virtualNodes <= 0 {
+		virtualNodes = defaultVirtualNodes
+	}
+	if hasher == nil {
+		hasher = FNVHasher{}
+	}
+	r := &Ring{virtualNodes: virtualNodes, hasher: hasher}
+	r.Set(nodes...)
+	return r
+}
+
+// Set replaces the nodes of the ring.
+func (r *Ring) Set(nodes ...string) {
+	points := make([]uint64, 0, len(nodes)*r.virtualNodes)
+	owners := make(map[uint64]string, len(nodes)*r.virtualNodes)
+	for _, node := range nodes {
+		for i := 0; i < r.virtualNodes; i++ {
+			point := r.hasher.Sum64(strconv.Itoa(i) + node)
+			if _, taken := owners[point]; taken {
+				continue
+			}
+			owners[point] = node
+			points = append(points, point)
+		}
+	}
+	sort.Slice(points, func(i, j int) bool { return points[i] < points[j] })
+
+	r.mu.Lock()
+	r.points, r.owners, r.nodes = points, owners, append([]string(nil), nodes...)
+	r.mu.Unlock()
+}
+
+// Nodes returns the nodes of the ring.
+func (r *Ring) Nodes() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return append([]string(nil), r.nodes...)
+}
+
+// Get returns the node owning key, false for an empty ring.
+func (r *Ring) Get(key string) (string, bool) {
+	nodes := r.GetN(key, 1)
+	if len(nodes) == 0 {
+		return "", false
+	}
+	return nodes[0], true
+}
+
+// GetN returns up to n distinct nodes for key, the owner first followed by the next nodes clockwise.
+func (r *Ring) GetN(key string, n int) []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if len(r.points) == 0 || n <= 0 {
+		return nil
+	}
+	hash := r.hasher.Sum64(key)
+	start := sort.Search(len(r.points), func(i int) bool { return r.points[i] >= hash })
+	var nodes []string
+	seen := make(map[string]bool, n)
+	for i := 0; i < len(r.points) && len(nodes) < n; i++ {
+		node := r.owners[r.points[(start+i)%len(r.points)]]
+		if !seen[node] {
+			seen[node] = true
+			nodes = append(nodes, node)
+		}
+	}
+	return nodes
+}
@@ -0,0 +1,83 @@
+package addcache
+
+import "time"
+
+const (
+	// sampledExpirationBudget bounds the time of one cleanup pass, it is measured on the wall
+	// clock as it limits the work and not the cache time.
+	sampledExpirationBudget = 25 * time.Millisecond
+	// sampledExpirationRepeat is the share of expired keys in a sample above which the pass goes on.
+	sampledExpirationRepeat = 0.25
+	// sampledExpirationVisits bounds the entries visited per expiring key sampled, persistent
+	// entries are skipped.
+	sampledExpirationVisits = 20
+)
+
+// WithSampledExpiration replaces the full scan of every cleanup pass by Redis style active
+// expiration: a pass checks samples expiring keys in random order, removes the expired ones and
+// repeats while more than a quarter of the sample had expired, for 25ms at most. The write lock
+// is only held for one sample at a time, which bounds the pauses of caches with millions of
+// entries. Expired keys left behind read as missing and are removed by later passes or Sweep.
+func WithSampledExpiration(samples int) Option {
+	return func(s *storage) {
+		if samples > 0 {
+			s.expirationSamples = samples
+		}
+	}
+}
+
+// cleanupPass runs one pass of the background cleanup, Sweep always scans all entries.
+func (s *storage) cleanupPass() {
+	if s.expirationSamples == 0 {
+		s.removeExpired()
+		return
+	}
+	s.removeExpiredSampled()
+}
+
+// removeExpiredSampled is the cleanup pass of WithSampledExpiration.
+func (s *storage) removeExpiredSampled() {
+	started := time.Now()
+	s.loaders.dropExpiredMissing(s.now())
+	s.loaders.dropPassedLoads(s.now())
+	keepStale := s.breaker.keepsExpired()
+	var expired []removal
+	for {
+		removals, sampled := s.expireSample(keepStale)
+		expired = append(expired, removals...)
+		if sampled == 0 || float64(len(removals)) <= float64(sampled)*sampledExpirationRepeat ||
+			time.Since(started) >= sampledExpirationBudget {
+			break
+		}
+	}
+	s.finishRemovals(expired...)
+	s.mu.Lock()
+	s.dropPassedGroupsLocked(s.now())
+	s.mu.Unlock()
+}
+
+// expireSample removes the expired keys among a sample of expiring keys. Go randomizes the start
+// of every map iteration, so the first expiring keys met are a random sample. With keepStale the
+// entries kept for the stale fallback stay.
+func (s *storage) expireSample(keepStale bool) (removals []removal, sampled int) {
+	now := s.now()
+	visits := s.expirationSamples * sampledExpirationVisits
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for key, sd := range s.data {
+		if sampled == s.expirationSamples || visits == 0 {
+			break
+		}
+		visits--
+		if sd.isPersistence && !s.staleLocked(key, sd) {
+			continue
+		}
+		sampled++
+		if s.expiredLocked(key, sd, now) && !(keepStale && s.retainsStaleLocked(key, sd, now)) {
+			s.deleteLocked(key)
+			removals = append(removals, removal{key: key, entry: sd, reason: RemovalExpired})
+		}
+	}
+	s.queueRemovalsLocked(removals...)
+	return removals, sampled
+}
@@ -0,0 +1,43 @@
+package addcache
+
+import (
+	"fmt"
+	"reflect"
+)
+
+var bytesType = reflect.TypeOf([]byte(nil))
+
+// GetScan reads the value of key into dest, a non-nil pointer, like the Scan of redis clients.
+// With WithSerialization the stored bytes are unmarshaled straight into dest, which may be of
+// another type than the value written as long as the codec can decode one into the other.
+// Byte slices, such as JSON written by other services, are unmarshaled with that codec too.
+// Other values are assigned to *dest when their type allows it and fail with ErrTypeMismatch otherwise.
+func (s *storage) GetScan(key string, dest any) error {
+	target := reflect.ValueOf(dest)
+	if target.Kind() != reflect.Pointer || target.IsNil() {
+		return fmt.Errorf("%w: GetScan needs a non-nil pointer, not %T", ErrTypeMismatch, dest)
+	}
+	value, _, err := s.lookup(key)
+	if err != nil {
+		return err
+	}
+	if encoded, ok := value.data.(encodedValue); ok && encoded.kind == encodedCodec && encoded.typ != bytesType {
+		raw, err := s.plainBytes(encoded)
+		if err != nil {
+			return err
+		}
+		return s.codec.Unmarshal(raw, dest)
+	}
+	data, err := s.decodeValue(value.data)
+	if err != nil {
+		return err
+	}
+	if v := reflect.ValueOf(data); v.IsValid() && v.Type().AssignableTo(target.Elem().Type()) {
+		target.Elem().Set(v)
+		return nil
+	}
+	if raw, ok := data.([]byte); ok && s.codec != nil {
+		return s.codec.Unmarshal(raw, dest)
+	}
+	return mismatch(key, data, target.Elem().Type().String())
+}
@@ -0,0 +1,158 @@
+package addcache
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+type scopeContextKey struct{}
+
+// WithRequestScope attaches a request scoped memoization layer over cache to ctx.
+// Lookups done through FromContext are served from the scope after the first
+// access, so the shared cache is consulted at most once per key and request.
+func WithRequestScope(ctx context.Context, cache Cache) context.Context {
+	return context.WithValue(ctx, scopeContextKey{}, newScopedCache(cache))
+}
+
+// FromContext returns the request scoped cache attached by WithRequestScope.
+func FromContext(ctx context.Context) (Cache, bool) {
+	scope, ok := ctx.Value(scopeContextKey{}).(*scopedCache)
+	return scope, ok
+}
+
+// scopedCache memoizes results of the shared cache for the lifetime of one request.
+// Entries expiring in the shared cache during the request stay visible in the scope. Only hits,
+// misses and writes known to have succeeded are memoized: Set, SetPersistent, SetEx and Delete
+// report no error, so they drop the key from the scope and the next Get reads the shared cache.
+type scopedCache struct {
+	shared Cache
+	mu     sync.Mutex
+	memo   map[string]scopedResult
+}
+
+type scopedResult struct {
+	data any
+	err  error
+}
+
+func newScopedCache(shared Cache) *scopedCache {
+	return &scopedCache{
+		shared: shared,
+		memo:   make(map[string]scopedResult),
+	}
+}
+
+func (s *scopedCache) Set(key string, data any) {
+	s.shared.Set(key, data)
+	s.forget(key)
+}
+
+func (s *scopedCache) SetPersistent(key string, data any) {
+	s.shared.SetPersistent(key, data)
+	s.forget(key)
+}
+
+func (s *scopedCache) SetEx(key string, data any, duration time.Duration) {
+	s.shared.SetEx(key, data, duration)
+	s.forget(key)
+}
+
+func (s *scopedCache) Get(key string) (any, error) {
+	s.mu.Lock()
+	result, ok := s.memo[key]
+	s.mu.Unlock()
+	if ok {
+		return result.data, result.err
+	}
+
+	data, err := s.shared.Get(key)
+	if succeeded(err) {
+		s.remember(key, data, err)
+	}
+	return data, err
+}
+
+func (s *scopedCache) Delete(key string) {
+	s.shared.Delete(key)
+	s.forget(key)
+}
+
+func (s *scopedCache) GetAndDelete(key string) (any, error) {
+	data, err := s.shared.GetAndDelete(key)
+	if succeeded(err) {
+		s.remember(key, nil, ErrCacheKeyNotFound)
+	} else {
+		s.forget(key)
+	}
+	return data, err
+}
+
+// GetAndSet stores newData even when it reports ErrCacheKeyNotFound for a missing key.
+func (s *scopedCache) GetAndSet(key string, newData any) (any, error) {
+	data, err := s.shared.GetAndSet(key, newData)
+	if succeeded(err) {
+		s.remember(key, newData, nil)
+	} else {
+		s.forget(key)
+	}
+	return data, err
+}
+
+func (s *scopedCache) Increment(key string, delta int64) (int64, error) {
+	value, err := s.shared.Increment(key, delta)
+	s.forget(key)
+	return value, err
+}
+
+func (s *scopedCache) Decrement(key string, delta int64) (int64, error) {
+	value, err := s.shared.Decrement(key, delta)
+	s.forget(key)
+	return value, err
+}
+
+func (s *scopedCache) CreateKey(args ...string) string {
+	return s.shared.CreateKey(args...)
+}
+
+func (s *scopedCache) CreateKeyWithDelimiter(delimiter string, args ...string) string {
+	return s.shared.CreateKeyWithDelimiter(delimiter, args...)
+}
+
+// StopCleanup is a no-op, the lifecycle of the shared cache is not owned by a request.
+func (s *scopedCache) StopCleanup() {}
+
+// Close is a no-op for the same reason as StopCleanup.
+func (s *scopedCache) Close() error {
+	return nil
+}
+
+func (s *scopedCache) SetHook(operationType OperationType, handlerFunctions ...HandlerFunc) HookID {
+	return s.shared.SetHook(operationType, handlerFunctions...)
+}
+
+func (s *scopedCache) RemoveHook(operationType OperationType, id HookID) {
+	s.shared.RemoveHook(operationType, id)
+}
+
+func (s *scopedCache) ClearHooks(operationType OperationType) {
+	s.shared.ClearHooks(operationType)
+}
+
+func (s *scopedCache) remember(key string, data any, err error) {
+	s.mu.Lock()
+	s.memo[key] = scopedResult{data: data, err: err}
+	s.mu.Unlock()
+}
+
+func (s *scopedCache) forget(key string) {
+	s.mu.Lock()
+	delete(s.memo, key)
+	s.mu.Unlock()
+}
+
+// succeeded tells the results worth memoizing from transient errors, a missing key is a result.
+func succeeded(err error) bool {
+	return err == nil || errors.Is(err, ErrCacheKeyNotFound)
+}
@@ -0,0 +1,124 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+)
+
+const (
+	maxBulkLength = 512 << 20
+	maxArgs       = 1024 * 1024
+	// maxLineLength bounds inline commands and headers, like the 64 KiB of redis.
+	maxLineLength = 64 << 10
+	// preallocated bounds what a header alone makes the server allocate, larger arguments grow
+	// with the bytes actually received.
+	preallocated = 64
+)
+
+var errProtocol = errors.New("protocol error")
+
+// readCommand reads a command sent as RESP array of bulk strings or as inline text line.
+func readCommand(r *bufio.Reader) ([]string, error) {
+	line, err := readLine(r)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasPrefix(line, "*") {
+		return strings.Fields(line), nil
+	}
+	count, err := strconv.Atoi(line[1:])
+	if err != nil || count < 0 || count > maxArgs {
+		return nil, errProtocol
+	}
+	args := make([]string, 0, minInt(count, preallocated))
+	for i := 0; i < count; i++ {
+		header, err := readLine(r)
+		if err != nil {
+			return nil, err
+		}
+		if !strings.HasPrefix(header, "$") {
+			return nil, errProtocol
+		}
+		length, err := strconv.Atoi(header[1:])
+		if err != nil || length < 0 || length > maxBulkLength {
+			return nil, errProtocol
+		}
+		var bulk bytes.Buffer
+		bulk.Grow(minInt(length+2, preallocated<<10))
+		if _, err := io.CopyN(&bulk, r, int64(length)+2); err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return nil, err
+		}
+		if !bytes.HasSuffix(bulk.Bytes(), []byte("\r\n")) {
+			return nil, errProtocol
+		}
+		args = append(args, string(bulk.Bytes()[:length]))
+	}
+	return args, nil
+}
+
+// readLine reads a line of at most maxLineLength bytes without its line ending.
+func readLine(r *bufio.Reader) (string, error) {
+	var line []byte
+	for {
+		chunk, err := r.ReadSlice('\n')
+		if len(line)+len(chunk) > maxLineLength {
+			return "", errProtocol
+		}
+		line = append(line, chunk...)
+		if err == bufio.ErrBufferFull {
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+		return strings.TrimRight(string(line), "\r\n"), nil
+	}
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// writer encodes replies, errors are kept and reported by flush.
+type writer struct {
+	w *bufio.Writer
+}
+
+func (w writer) simple(s string) {
+	w.w.WriteString("+" + s + "\r\n")
+}
+
+func (w writer) error(s string) {
+	w.w.WriteString("-" + strings.NewReplacer("\r", " ", "\n", " ").Replace(s) + "\r\n")
+}
+
+func (w writer) integer(n int64) {
+	w.w.WriteString(":" + strconv.FormatInt(n, 10) + "\r\n")
+}
+
+func (w writer) bulk(b []byte) {
+	w.w.WriteString("$" + strconv.Itoa(len(b)) + "\r\n")
+	w.w.Write(b)
+	w.w.WriteString("\r\n")
+}
+
+func (w writer) null() {
+	w.w.WriteString("$-1\r\n")
+}
+
+func (w writer) array(items []string) {
+	w.w.WriteString("*" + strconv.Itoa(len(items)) + "\r\n")
+	for _, item := range items {
+		w.bulk([]byte(item))
+	}
+}
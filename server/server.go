@@ -0,0 +1,426 @@
+// Package server serves an addcache.LocalCache over the Redis protocol, so redis-cli and Redis
+// client libraries can inspect and use an embedded cache. It understands a subset of the
+// commands: GET, SET with EX, PX, NX and XX, SETEX, DEL, EXISTS, EXPIRE, TTL, KEYS and DBSIZE,
+// as well as PING, ECHO, COMMAND and QUIT for the handshakes of clients.
+//
+// Values written over the protocol are stored as strings. Values written by the application are
+// returned as they are for strings and byte slices, as decimal text for integers and as JSON otherwise.
+package server
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/addit-digital/addcache"
+)
+
+// ErrServerClosed is returned by Serve and ListenAndServe after Close.
+var ErrServerClosed = errors.New("addcache/server: server closed")
+
+// Server answers Redis protocol connections from a cache, which stays owned by the caller.
+type Server struct {
+	cache     addcache.LocalCache
+	mu        sync.Mutex
+	closed    bool
+	listeners map[net.Listener]struct{}
+	conns     map[net.Conn]struct{}
+	wg        sync.WaitGroup
+}
+
+func New(cache addcache.LocalCache) *Server {
+	return &Server{
+		cache:     cache,
+		listeners: make(map[net.Listener]struct{}),
+		conns:     make(map[net.Conn]struct{}),
+	}
+}
+
+// ListenAndServe listens on the TCP address and serves connections until Close.
+func (s *Server) ListenAndServe(addr string) error {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	return s.Serve(listener)
+}
+
+// Serve accepts connections on listener until Close, the listener is closed on return.
+func (s *Server) Serve(listener net.Listener) error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		listener.Close()
+		return ErrServerClosed
+	}
+	s.listeners[listener] = struct{}{}
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		delete(s.listeners, listener)
+		s.mu.Unlock()
+		listener.Close()
+	}()
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			s.mu.Lock()
+			closed := s.closed
+			s.mu.Unlock()
+			if closed {
+				return ErrServerClosed
+			}
+			return err
+		}
+		s.mu.Lock()
+		if s.closed {
+			s.mu.Unlock()
+			conn.Close()
+			return ErrServerClosed
+		}
+		s.conns[conn] = struct{}{}
+		s.wg.Add(1)
+		s.mu.Unlock()
+		go s.serveConn(conn)
+	}
+}
+
+// Close stops the listeners, closes open connections and waits for their commands to finish.
+func (s *Server) Close() error {
+	s.mu.Lock()
+	s.closed = true
+	for listener := range s.listeners {
+		listener.Close()
+	}
+	for conn := range s.conns {
+		conn.Close()
+	}
+	s.mu.Unlock()
+	s.wg.Wait()
+	return nil
+}
+
+func (s *Server) serveConn(conn net.Conn) {
+	defer func() {
+		s.mu.Lock()
+		delete(s.conns, conn)
+		s.mu.Unlock()
+		conn.Close()
+		s.wg.Done()
+	}()
+	reader := bufio.NewReader(conn)
+	w := writer{w: bufio.NewWriter(conn)}
+	for {
+		args, err := readCommand(reader)
+		if err != nil {
+			if errors.Is(err, errProtocol) {
+				w.error("ERR Protocol error")
+				w.w.Flush()
+			}
+			return
+		}
+		if len(args) == 0 {
+			continue
+		}
+		quit := s.execute(w, args)
+		// answer pipelined commands in one write
+		if reader.Buffered() == 0 || quit {
+			if w.w.Flush() != nil {
+				return
+			}
+		}
+		if quit {
+			return
+		}
+	}
+}
+
+// execute runs one command and reports whether the connection is to be closed.
+func (s *Server) execute(w writer, args []string) bool {
+	name := strings.ToUpper(args[0])
+	args = args[1:]
+	arity, known := arities[name]
+	if !known {
+		w.error(fmt.Sprintf("ERR unknown command '%s'", truncateName(name)))
+		return false
+	}
+	if len(args) < arity.min || (arity.max >= 0 && len(args) > arity.max) {
+		w.error(fmt.Sprintf("ERR wrong number of arguments for '%s' command", strings.ToLower(name)))
+		return false
+	}
+
+	switch name {
+	case "PING":
+		if len(args) == 1 {
+			w.bulk([]byte(args[0]))
+		} else {
+			w.simple("PONG")
+		}
+	case "ECHO":
+		w.bulk([]byte(args[0]))
+	case "QUIT":
+		w.simple("OK")
+		return true
+	case "COMMAND":
+		w.array(nil)
+	case "GET":
+		data, err := s.cache.Get(args[0])
+		if err != nil {
+			w.null()
+			return false
+		}
+		w.bulk(format(data))
+	case "SET":
+		s.set(w, args)
+	case "SETEX":
+		seconds, err := strconv.ParseInt(args[1], 10, 64)
+		if err != nil || seconds <= 0 {
+			w.error("ERR invalid expire time in 'setex' command")
+			return false
+		}
+		s.cache.SetEx(args[0], args[2], time.Duration(seconds)*time.Second)
+		w.simple("OK")
+	case "DEL":
+		var deleted int64
+		for _, key := range args {
+			if _, err := s.cache.GetAndDelete(key); err == nil {
+				deleted++
+			}
+		}
+		w.integer(deleted)
+	case "EXISTS":
+		var existing int64
+		for _, key := range args {
+			if _, err := s.cache.Get(key); err == nil {
+				existing++
+			}
+		}
+		w.integer(existing)
+	case "EXPIRE":
+		seconds, err := strconv.ParseInt(args[1], 10, 64)
+		if err != nil {
+			w.error("ERR value is not an integer or out of range")
+			return false
+		}
+		result, err := s.cache.GetResult(args[0])
+		if err != nil {
+			w.integer(0)
+			return false
+		}
+		if seconds <= 0 {
+			s.cache.Delete(args[0])
+		} else {
+			s.cache.SetEx(args[0], result.Value, time.Duration(seconds)*time.Second)
+		}
+		w.integer(1)
+	case "TTL":
+		result, err := s.cache.GetResult(args[0])
+		switch {
+		case err != nil:
+			w.integer(-2)
+		case result.Persistent:
+			w.integer(-1)
+		default:
+			w.integer(int64((result.TTL + time.Second/2) / time.Second))
+		}
+	case "KEYS":
+		var keys []string
+		for _, key := range s.cache.Keys(literalPrefix(args[0])) {
+			if match(args[0], key) {
+				keys = append(keys, key)
+			}
+		}
+		w.array(keys)
+	case "DBSIZE":
+		w.integer(int64(len(s.cache.Keys(""))))
+	}
+	return false
+}
+
+type arity struct {
+	min, max int
+}
+
+// arities lists the supported commands with their number of arguments, -1 for any.
+var arities = map[string]arity{
+	"PING":    {0, 1},
+	"ECHO":    {1, 1},
+	"QUIT":    {0, 0},
+	"COMMAND": {0, -1},
+	"GET":     {1, 1},
+	"SET":     {2, 5},
+	"SETEX":   {3, 3},
+	"DEL":     {1, -1},
+	"EXISTS":  {1, -1},
+	"EXPIRE":  {2, 2},
+	"TTL":     {1, 1},
+	"KEYS":    {1, 1},
+	"DBSIZE":  {0, 0},
+}
+
+// truncateName shortens unknown command names echoed in errors.
+func truncateName(name string) string {
+	if len(name) > 64 {
+		name = name[:64]
+	}
+	return strings.ToLower(name)
+}
+
+// set implements SET key value [EX seconds | PX milliseconds] [NX | XX].
+func (s *Server) set(w writer, args []string) {
+	key, value := args[0], args[1]
+	var ttl time.Duration
+	var nx, xx bool
+	for i := 2; i < len(args); i++ {
+		switch option := strings.ToUpper(args[i]); option {
+		case "NX":
+			nx = true
+		case "XX":
+			xx = true
+		case "EX", "PX":
+			if i+1 >= len(args) || ttl != 0 {
+				w.error("ERR syntax error")
+				return
+			}
+			i++
+			n, err := strconv.ParseInt(args[i], 10, 64)
+			if err != nil || n <= 0 {
+				w.error("ERR invalid expire time in 'set' command")
+				return
+			}
+			unit := time.Second
+			if option == "PX" {
+				unit = time.Millisecond
+			}
+			ttl = time.Duration(n) * unit
+		default:
+			w.error("ERR syntax error")
+			return
+		}
+	}
+	if nx && xx {
+		w.error("ERR syntax error")
+		return
+	}
+	if nx || xx {
+		_, err := s.cache.Get(key)
+		if exists := err == nil; (nx && exists) || (xx && !exists) {
+			w.null()
+			return
+		}
+	}
+	if ttl > 0 {
+		s.cache.SetEx(key, value, ttl)
+	} else {
+		s.cache.SetPersistent(key, value)
+	}
+	w.simple("OK")
+}
+
+// format renders a cached value as bulk string.
+func format(data any) []byte {
+	switch v := data.(type) {
+	case string:
+		return []byte(v)
+	case []byte:
+		return v
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+		return []byte(fmt.Sprint(v))
+	}
+	if raw, err := json.Marshal(data); err == nil {
+		return raw
+	}
+	return []byte(fmt.Sprint(data))
+}
+
+// literalPrefix returns the part of a KEYS pattern before its first special character.
+func literalPrefix(pattern string) string {
+	if i := strings.IndexAny(pattern, `*?[\`); i >= 0 {
+		return pattern[:i]
+	}
+	return pattern
+}
+
+// match reports whether key matches the glob pattern of KEYS: * and ? wildcards,
+// [abc], [^abc] and [a-z] classes and \ escapes.
+func match(pattern, key string) bool {
+	for len(pattern) > 0 {
+		switch pattern[0] {
+		case '*':
+			for len(pattern) > 1 && pattern[1] == '*' {
+				pattern = pattern[1:]
+			}
+			if len(pattern) == 1 {
+				return true
+			}
+			for i := 0; i <= len(key); i++ {
+				if match(pattern[1:], key[i:]) {
+					return true
+				}
+			}
+			return false
+		case '?':
+			if len(key) == 0 {
+				return false
+			}
+			key = key[1:]
+			pattern = pattern[1:]
+		case '[':
+			if len(key) == 0 {
+				return false
+			}
+			end := strings.IndexByte(pattern[1:], ']')
+			if end < 0 {
+				// an unterminated class matches itself literally
+				if key[0] != '[' {
+					return false
+				}
+				key, pattern = key[1:], pattern[1:]
+				continue
+			}
+			class := pattern[1 : end+1]
+			negate := strings.HasPrefix(class, "^")
+			if negate {
+				class = class[1:]
+			}
+			if matchClass(class, key[0]) == negate {
+				return false
+			}
+			key, pattern = key[1:], pattern[end+2:]
+		case '\\':
+			if len(pattern) > 1 {
+				pattern = pattern[1:]
+			}
+			fallthrough
+		default:
+			if len(key) == 0 || key[0] != pattern[0] {
+				return false
+			}
+			key, pattern = key[1:], pattern[1:]
+		}
+	}
+	return len(key) == 0
+}
+
+func matchClass(class string, c byte) bool {
+	for i := 0; i < len(class); i++ {
+		if i+2 < len(class) && class[i+1] == '-' {
+			if low, high := class[i], class[i+2]; (low <= c && c <= high) || (high <= c && c <= low) {
+				return true
+			}
+			i += 2
+			continue
+		}
+		if class[i] == c {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,118 @@
+package addcache
+
+import (
+	"errors"
+	"sort"
+)
+
+// SAdd adds the members to the set stored under the key and returns how many were new. Sets are
+// stored as map[string]bool copied on every write, a missing key starts an empty set stored like
+// Set and the expiration of an existing key is kept. ErrTypeMismatch is returned when the key
+// holds another value.
+func (s *storage) SAdd(key string, members ...string) (int, error) {
+	added := 0
+	err := s.update(key, func(current any, ok bool) (any, error) {
+		set, err := setOf(current, ok)
+		if err != nil {
+			return nil, err
+		}
+		next := make(map[string]bool, len(set)+len(members))
+		for member := range set {
+			next[member] = true
+		}
+		for _, member := range members {
+			if !next[member] {
+				next[member] = true
+				added++
+			}
+		}
+		if added == 0 && ok {
+			return nil, errUnchanged
+		}
+		return next, nil
+	})
+	return added, err
+}
+
+// SRem removes the members from the set and returns how many existed, the key is deleted with its last member.
+func (s *storage) SRem(key string, members ...string) (int, error) {
+	removed := 0
+	err := s.update(key, func(current any, ok bool) (any, error) {
+		if !ok {
+			return nil, errUnchanged
+		}
+		set, err := setOf(current, ok)
+		if err != nil {
+			return nil, err
+		}
+		next := make(map[string]bool, len(set))
+		for member := range set {
+			next[member] = true
+		}
+		for _, member := range members {
+			if next[member] {
+				delete(next, member)
+				removed++
+			}
+		}
+		if removed == 0 {
+			return nil, errUnchanged
+		}
+		if len(next) == 0 {
+			return nil, nil
+		}
+		return next, nil
+	})
+	return removed, err
+}
+
+// SMembers returns the sorted members of the set, a missing key is an empty set.
+func (s *storage) SMembers(key string) ([]string, error) {
+	set, err := s.readSet(key)
+	if err != nil {
+		return nil, err
+	}
+	members := make([]string, 0, len(set))
+	for member := range set {
+		members = append(members, member)
+	}
+	sort.Strings(members)
+	return members, nil
+}
+
+// SIsMember reports whether the member is in the set stored under the key.
+func (s *storage) SIsMember(key string, member string) (bool, error) {
+	set, err := s.readSet(key)
+	if err != nil {
+		return false, err
+	}
+	return set[member], nil
+}
+
+// SCard returns the number of members of the set stored under the key.
+func (s *storage) SCard(key string) (int, error) {
+	set, err := s.readSet(key)
+	return len(set), err
+}
+
+func (s *storage) readSet(key string) (map[string]bool, error) {
+	data, err := s.Get(key)
+	if errors.Is(err, ErrCacheKeyNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return setOf(data, true)
+}
+
+func setOf(data any, ok bool) (map[string]bool, error) {
+	if !ok {
+		return nil, nil
+	}
+	set, isSet := data.(map[string]bool)
+	if !isSet {
+		return nil, ErrTypeMismatch
+	}
+	return set, nil
+}
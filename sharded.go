@@ -0,0 +1,245 @@
+package addcache
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ShardedClient spreads keys over several caches, e.g. redisadapter or memcacheadapter clients of
+// separate servers, with a consistent hash Ring so adding a shard only moves a share of the keys.
+// Every key is written to as many shards as the replication factor and read from the first of
+// them holding it. Hooks run once per operation on the client, not per shard.
+type ShardedClient struct {
+	mu           sync.RWMutex
+	shards       map[string]Cache
+	ring         *Ring
+	replicas     int
+	virtualNodes int
+	hasher       Hasher
+	hooks        Hooks
+}
+
+var _ Cache = (*ShardedClient)(nil)
+
+// ShardedOption configures NewShardedClient.
+type ShardedOption func(c *ShardedClient)
+
+// WithReplicationFactor writes every key to replicas shards, 1 by default.
+func WithReplicationFactor(replicas int) ShardedOption {
+	return func(c *ShardedClient) {
+		if replicas > 0 {
+			c.replicas = replicas
+		}
+	}
+}
+
+// WithShardVirtualNodes sets the points of every shard on the ring, see NewRing.
+func WithShardVirtualNodes(virtualNodes int) ShardedOption {
+	return func(c *ShardedClient) {
+		c.virtualNodes = virtualNodes
+	}
+}
+
+// WithShardHasher places shards and keys on the ring with hasher, see NewRingWithHasher.
+func WithShardHasher(hasher Hasher) ShardedOption {
+	return func(c *ShardedClient) {
+		c.hasher = hasher
+	}
+}
+
+// WithShardHookErrorHandler receives panics recovered from hooks of the client, they are logged by default.
+func WithShardHookErrorHandler(handler HookErrorHandler) ShardedOption {
+	return func(c *ShardedClient) {
+		c.hooks.ErrorHandler = handler
+	}
+}
+
+// NewShardedClient routes keys over shards by name, the names place the shards on the ring and
+// have to be the same in every process sharing the shards.
+func NewShardedClient(shards map[string]Cache, options ...ShardedOption) *ShardedClient {
+	c := &ShardedClient{
+		shards:   make(map[string]Cache, len(shards)),
+		replicas: 1,
+	}
+	for _, option := range options {
+		option(c)
+	}
+	for name, shard := range shards {
+		c.shards[name] = shard
+	}
+	c.ring = NewRingWithHasher(c.virtualNodes, c.hasher, c.names()...)
+	return c
+}
+
+// AddShard adds or replaces the shard, the keys it now owns are missing until they are written again.
+func (c *ShardedClient) AddShard(name string, shard Cache) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.shards[name] = shard
+	c.ring.Set(c.names()...)
+}
+
+// RemoveShard takes the shard off the ring without closing it.
+func (c *ShardedClient) RemoveShard(name string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.shards, name)
+	c.ring.Set(c.names()...)
+}
+
+func (c *ShardedClient) names() []string {
+	names := make([]string, 0, len(c.shards))
+	for name := range c.shards {
+		names = append(names, name)
+	}
+	return names
+}
+
+// replicasOf returns the shards of key, the owner first.
+func (c *ShardedClient) replicasOf(key string) []Cache {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	nodes := c.ring.GetN(key, c.replicas)
+	shards := make([]Cache, 0, len(nodes))
+	for _, node := range nodes {
+		shards = append(shards, c.shards[node])
+	}
+	return shards
+}
+
+func (c *ShardedClient) Set(key string, data any) {
+	for _, shard := range c.replicasOf(key) {
+		shard.Set(key, data)
+	}
+	c.hooks.Run(CreateOperation, key, data)
+}
+
+func (c *ShardedClient) SetPersistent(key string, data any) {
+	for _, shard := range c.replicasOf(key) {
+		shard.SetPersistent(key, data)
+	}
+	c.hooks.Run(CreateOperation, key, data)
+}
+
+func (c *ShardedClient) SetEx(key string, data any, duration time.Duration) {
+	for _, shard := range c.replicasOf(key) {
+		shard.SetEx(key, data, duration)
+	}
+	c.hooks.Run(CreateOperation, key, data)
+}
+
+// Get reads the replicas in ring order, the error of the last one is returned when none holds the key.
+func (c *ShardedClient) Get(key string) (any, error) {
+	err := error(ErrCacheKeyNotFound)
+	for _, shard := range c.replicasOf(key) {
+		var data any
+		if data, err = shard.Get(key); err == nil {
+			return data, nil
+		}
+	}
+	return nil, err
+}
+
+func (c *ShardedClient) Delete(key string) {
+	_, _ = c.GetAndDelete(key)
+}
+
+// GetAndDelete removes the key from all replicas and returns the data of the first holding it.
+func (c *ShardedClient) GetAndDelete(key string) (any, error) {
+	var data any
+	err := error(ErrCacheKeyNotFound)
+	found := false
+	for _, shard := range c.replicasOf(key) {
+		removed, removeErr := shard.GetAndDelete(key)
+		if removeErr == nil && !found {
+			data, err, found = removed, nil, true
+		} else if !found && !errors.Is(removeErr, ErrCacheKeyNotFound) {
+			err = removeErr
+		}
+	}
+	if found {
+		c.hooks.Run(DeleteOperation, key, data)
+	}
+	return data, err
+}
+
+// GetAndSet replaces the data on all replicas and returns what the owner held.
+func (c *ShardedClient) GetAndSet(key string, newData any) (any, error) {
+	shards := c.replicasOf(key)
+	if len(shards) == 0 {
+		return nil, ErrCacheKeyNotFound
+	}
+	previous, err := shards[0].GetAndSet(key, newData)
+	if err != nil && !errors.Is(err, ErrCacheKeyNotFound) {
+		return nil, err
+	}
+	for _, shard := range shards[1:] {
+		shard.Set(key, newData)
+	}
+	c.hooks.Run(CreateOperation, key, newData)
+	return previous, err
+}
+
+// Increment counts on the owner, the other replicas receive the result with Set.
+func (c *ShardedClient) Increment(key string, delta int64) (int64, error) {
+	shards := c.replicasOf(key)
+	if len(shards) == 0 {
+		return 0, ErrCacheKeyNotFound
+	}
+	value, err := shards[0].Increment(key, delta)
+	if err != nil {
+		return 0, err
+	}
+	for _, shard := range shards[1:] {
+		shard.Set(key, value)
+	}
+	c.hooks.Run(CreateOperation, key, value)
+	return value, nil
+}
+
+func (c *ShardedClient) Decrement(key string, delta int64) (int64, error) {
+	return c.Increment(key, -delta)
+}
+
+func (c *ShardedClient) CreateKey(args ...string) string {
+	return c.CreateKeyWithDelimiter(defaultDelimiter, args...)
+}
+
+func (c *ShardedClient) CreateKeyWithDelimiter(delimiter string, args ...string) string {
+	return strings.Join(args, delimiter)
+}
+
+func (c *ShardedClient) StopCleanup() {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	for _, shard := range c.shards {
+		shard.StopCleanup()
+	}
+}
+
+// Close closes all shards and returns the first error.
+func (c *ShardedClient) Close() error {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	var err error
+	for _, shard := range c.shards {
+		if closeErr := shard.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	return err
+}
+
+func (c *ShardedClient) SetHook(operationType OperationType, handlerFunctions ...HandlerFunc) HookID {
+	return c.hooks.SetHook(operationType, handlerFunctions...)
+}
+
+func (c *ShardedClient) RemoveHook(operationType OperationType, id HookID) {
+	c.hooks.RemoveHook(operationType, id)
+}
+
+func (c *ShardedClient) ClearHooks(operationType OperationType) {
+	c.hooks.ClearHooks(operationType)
+}
@@ -0,0 +1,70 @@
+package shm
+
+import (
+	"os"
+	"syscall"
+)
+
+type regionFile struct {
+	file *os.File
+}
+
+// Open maps the region in the file at path, creating and formatting it when the file is empty.
+func Open(path string, options ...Option) (*Region, error) {
+	r := newRegion(options)
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	r.file = regionFile{file: file}
+	if err := r.file.lock(true); err != nil {
+		file.Close()
+		return nil, err
+	}
+	defer r.file.unlock()
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	created := info.Size() == 0
+	if created {
+		err = file.Truncate(int64(r.size()))
+	} else if info.Size() != int64(r.size()) {
+		err = ErrIncompatible
+	}
+	if err == nil {
+		r.memory, err = syscall.Mmap(int(file.Fd()), 0, r.size(), syscall.PROT_READ|syscall.PROT_WRITE, syscall.MAP_SHARED)
+	}
+	if err == nil {
+		if err = r.format(created); err != nil {
+			syscall.Munmap(r.memory)
+		}
+	}
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	return r, nil
+}
+
+func (f regionFile) lock(exclusive bool) error {
+	how := syscall.LOCK_SH
+	if exclusive {
+		how = syscall.LOCK_EX
+	}
+	return syscall.Flock(int(f.file.Fd()), how)
+}
+
+func (f regionFile) unlock() {
+	_ = syscall.Flock(int(f.file.Fd()), syscall.LOCK_UN)
+}
+
+func (f regionFile) close(memory []byte) error {
+	err := syscall.Munmap(memory)
+	if closeErr := f.file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
@@ -0,0 +1,20 @@
+//go:build !linux
+
+package shm
+
+type regionFile struct{}
+
+// Open returns ErrUnsupported outside Linux.
+func Open(path string, options ...Option) (*Region, error) {
+	return nil, ErrUnsupported
+}
+
+func (regionFile) lock(exclusive bool) error {
+	return ErrUnsupported
+}
+
+func (regionFile) unlock() {}
+
+func (regionFile) close(memory []byte) error {
+	return nil
+}
@@ -0,0 +1,370 @@
+// Package shm is an experimental addcache.Backend in a shared memory segment, so processes on
+// one host, e.g. the blue and green instance of a deployment, share a single cache region and a
+// newly started process begins warm. Use it as L2 of addcache.NewTieredCache.
+//
+// The region is a file, typically under /dev/shm, mapped into every process. It holds a fixed
+// number of fixed size slots, an entry has to fit into one slot with its key. Access is
+// serialized with an advisory file lock. The region is only supported on Linux.
+package shm
+
+import (
+	"encoding/binary"
+	"errors"
+	"hash/fnv"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/addit-digital/addcache"
+)
+
+const (
+	magic      = "ADDCSHM1"
+	headerSize = 64
+	slotHeader = 16
+
+	defaultSlots    = 4096
+	defaultSlotSize = 1024
+)
+
+// Slot states.
+const (
+	slotEmpty byte = iota
+	slotUsed
+	slotDeleted
+)
+
+// Value tags as in the redisadapter module, integers are stored as decimal text.
+const (
+	tagCodec  byte = 1
+	tagString byte = 2
+	tagBytes  byte = 3
+)
+
+// ErrUnsupported is returned by Open on platforms without shared memory support.
+var ErrUnsupported = errors.New("addcache/shm: shared memory regions are not supported on this platform")
+
+// ErrIncompatible is returned by Open for a file that is not a region or has another layout.
+var ErrIncompatible = errors.New("addcache/shm: file is not a compatible region")
+
+// Option configures Open.
+type Option func(r *Region)
+
+// WithSlots sets the number of entries a newly created region holds, 4096 by default.
+// An existing region keeps its layout and has to have been created with the same options.
+func WithSlots(slots int) Option {
+	return func(r *Region) {
+		r.slots = slots
+	}
+}
+
+// WithSlotSize sets the bytes per entry of a newly created region including its key, 1024 by default.
+func WithSlotSize(size int) Option {
+	return func(r *Region) {
+		r.slotSize = size
+	}
+}
+
+// WithCodec encodes values other than strings, byte slices and integers, gob is the default.
+// Values are decoded into any, so gob needs concrete types registered with gob.Register.
+func WithCodec(codec addcache.Codec) Option {
+	return func(r *Region) {
+		r.codec = codec
+	}
+}
+
+// Region is a shared memory addcache.Backend, safe for concurrent use by goroutines and processes.
+type Region struct {
+	slots    int
+	slotSize int
+	codec    addcache.Codec
+	mu       sync.Mutex
+	memory   []byte
+	file     regionFile
+}
+
+var (
+	_ addcache.Backend            = (*Region)(nil)
+	_ addcache.BackendIncrementer = (*Region)(nil)
+	_ addcache.BackendTTLGetter   = (*Region)(nil)
+)
+
+func newRegion(options []Option) *Region {
+	r := &Region{
+		slots:    defaultSlots,
+		slotSize: defaultSlotSize,
+		codec:    addcache.GobCodec{},
+	}
+	for _, option := range options {
+		option(r)
+	}
+	if r.slots <= 0 {
+		r.slots = defaultSlots
+	}
+	if r.slotSize <= slotHeader {
+		r.slotSize = defaultSlotSize
+	}
+	return r
+}
+
+func (r *Region) Get(key string) (any, error) {
+	var data any
+	err := r.locked(false, func() error {
+		slot, found := r.find(key, time.Now())
+		if !found {
+			return addcache.ErrCacheKeyNotFound
+		}
+		var err error
+		data, err = r.decode(r.value(slot))
+		return err
+	})
+	return data, err
+}
+
+// GetWithTTL reads like Get and returns the remaining TTL, zero for persistent entries.
+func (r *Region) GetWithTTL(key string) (any, time.Duration, error) {
+	var data any
+	var ttl time.Duration
+	err := r.locked(false, func() error {
+		now := time.Now()
+		slot, found := r.find(key, now)
+		if !found {
+			return addcache.ErrCacheKeyNotFound
+		}
+		if expires := int64(binary.LittleEndian.Uint64(r.memory[slot+8:])); expires != 0 {
+			ttl = time.Unix(0, expires).Sub(now)
+		}
+		var err error
+		data, err = r.decode(r.value(slot))
+		return err
+	})
+	return data, ttl, err
+}
+
+func (r *Region) Set(key string, data any, ttl time.Duration) error {
+	raw, err := r.encode(data)
+	if err != nil {
+		return err
+	}
+	return r.locked(true, func() error {
+		return r.put(key, raw, ttl, time.Now())
+	})
+}
+
+func (r *Region) Delete(key string) error {
+	return r.locked(true, func() error {
+		if slot, found := r.find(key, time.Now()); found {
+			r.memory[slot] = slotDeleted
+		}
+		return nil
+	})
+}
+
+// Increment adds delta to an integer entry, a missing key starts from zero and is persisted.
+func (r *Region) Increment(key string, delta int64) (int64, error) {
+	var value int64
+	err := r.locked(true, func() error {
+		now := time.Now()
+		ttl := time.Duration(0)
+		if slot, found := r.find(key, now); found {
+			current, err := strconv.ParseInt(string(r.value(slot)), 10, 64)
+			if err != nil {
+				return addcache.ErrCacheValueNotInteger
+			}
+			value = current
+			if expires := int64(binary.LittleEndian.Uint64(r.memory[slot+8:])); expires != 0 {
+				ttl = time.Unix(0, expires).Sub(now)
+			}
+		}
+		value += delta
+		return r.put(key, strconv.AppendInt(nil, value, 10), ttl, now)
+	})
+	return value, err
+}
+
+// Close unmaps the region, its contents stay in the file for the other processes.
+func (r *Region) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.memory == nil {
+		return nil
+	}
+	err := r.file.close(r.memory)
+	r.memory = nil
+	return err
+}
+
+// locked runs fn holding the region exclusively within the process and the file lock
+// shared or exclusively across processes.
+func (r *Region) locked(exclusive bool, fn func() error) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.memory == nil {
+		return addcache.ErrCacheClosed
+	}
+	if err := r.file.lock(exclusive); err != nil {
+		return err
+	}
+	defer r.file.unlock()
+	return fn()
+}
+
+// format writes the header of a new region, or validates and adopts the layout of an existing one.
+func (r *Region) format(created bool) error {
+	header := r.memory[:headerSize]
+	if created {
+		copy(header, magic)
+		binary.LittleEndian.PutUint32(header[8:], uint32(r.slots))
+		binary.LittleEndian.PutUint32(header[12:], uint32(r.slotSize))
+		return nil
+	}
+	if string(header[:len(magic)]) != magic ||
+		int(binary.LittleEndian.Uint32(header[8:])) != r.slots ||
+		int(binary.LittleEndian.Uint32(header[12:])) != r.slotSize {
+		return ErrIncompatible
+	}
+	return nil
+}
+
+func (r *Region) size() int {
+	return headerSize + r.slots*r.slotSize
+}
+
+// find returns the offset of the live slot holding key.
+func (r *Region) find(key string, now time.Time) (int, bool) {
+	start := r.home(key)
+	for i := 0; i < r.slots; i++ {
+		slot := r.offset((start + i) % r.slots)
+		switch r.memory[slot] {
+		case slotEmpty:
+			return 0, false
+		case slotUsed:
+			if r.key(slot) == key {
+				return slot, !r.expired(slot, now)
+			}
+		}
+	}
+	return 0, false
+}
+
+// put writes the entry into the slot of the key, or the first free one along its probe sequence.
+func (r *Region) put(key string, raw []byte, ttl time.Duration, now time.Time) error {
+	if slotHeader+len(key)+len(raw) > r.slotSize || len(key) > 0xffff {
+		return addcache.ErrValueTooLarge
+	}
+	start := r.home(key)
+	free := -1
+	target := -1
+probe:
+	for i := 0; i < r.slots; i++ {
+		slot := r.offset((start + i) % r.slots)
+		switch r.memory[slot] {
+		case slotEmpty:
+			if free < 0 {
+				free = slot
+			}
+			break probe
+		case slotDeleted:
+			if free < 0 {
+				free = slot
+			}
+		case slotUsed:
+			if r.key(slot) == key {
+				target = slot
+				break probe
+			}
+			if free < 0 && r.expired(slot, now) {
+				free = slot
+			}
+		}
+	}
+	if target < 0 {
+		target = free
+	}
+	if target < 0 {
+		return addcache.ErrCapacityExceeded
+	}
+
+	var expires int64
+	if ttl > 0 {
+		expires = now.Add(ttl).UnixNano()
+	}
+	entry := r.memory[target : target+r.slotSize]
+	binary.LittleEndian.PutUint16(entry[2:], uint16(len(key)))
+	binary.LittleEndian.PutUint32(entry[4:], uint32(len(raw)))
+	binary.LittleEndian.PutUint64(entry[8:], uint64(expires))
+	copy(entry[slotHeader:], key)
+	copy(entry[slotHeader+len(key):], raw)
+	entry[0] = slotUsed
+	return nil
+}
+
+func (r *Region) home(key string) int {
+	h := fnv.New64a()
+	h.Write([]byte(key))
+	return int(h.Sum64() % uint64(r.slots))
+}
+
+func (r *Region) offset(index int) int {
+	return headerSize + index*r.slotSize
+}
+
+func (r *Region) key(slot int) string {
+	length := int(binary.LittleEndian.Uint16(r.memory[slot+2:]))
+	return string(r.memory[slot+slotHeader : slot+slotHeader+length])
+}
+
+// value returns a copy of the encoded value, the memory may change once the lock is released.
+func (r *Region) value(slot int) []byte {
+	start := slot + slotHeader + int(binary.LittleEndian.Uint16(r.memory[slot+2:]))
+	length := int(binary.LittleEndian.Uint32(r.memory[slot+4:]))
+	return append([]byte(nil), r.memory[start:start+length]...)
+}
+
+func (r *Region) expired(slot int, now time.Time) bool {
+	expires := int64(binary.LittleEndian.Uint64(r.memory[slot+8:]))
+	return expires != 0 && now.UnixNano() >= expires
+}
+
+func (r *Region) encode(data any) ([]byte, error) {
+	switch v := data.(type) {
+	case string:
+		return append([]byte{tagString}, v...), nil
+	case []byte:
+		return append([]byte{tagBytes}, v...), nil
+	case int:
+		return strconv.AppendInt(nil, int64(v), 10), nil
+	case int64:
+		return strconv.AppendInt(nil, v, 10), nil
+	case int32:
+		return strconv.AppendInt(nil, int64(v), 10), nil
+	}
+	raw, err := r.codec.Marshal(&data)
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte{tagCodec}, raw...), nil
+}
+
+// decode reverses encode, integers are returned as int64.
+func (r *Region) decode(raw []byte) (any, error) {
+	if len(raw) == 0 {
+		return "", nil
+	}
+	switch raw[0] {
+	case tagString:
+		return string(raw[1:]), nil
+	case tagBytes:
+		return raw[1:], nil
+	case tagCodec:
+		var data any
+		if err := r.codec.Unmarshal(raw[1:], &data); err != nil {
+			return nil, err
+		}
+		return data, nil
+	}
+	if value, err := strconv.ParseInt(string(raw), 10, 64); err == nil {
+		return value, nil
+	}
+	return string(raw), nil
+}
@@ -0,0 +1,86 @@
+package addcache
+
+import (
+	"container/list"
+	"sync"
+	"sync/atomic"
+)
+
+type sieveEntry struct {
+	key     string
+	visited int32
+}
+
+// sievePolicy implements SIEVE: keys queue in insertion order and reads only set a visited bit.
+// The hand sweeps from the oldest key towards the newest, clearing visited bits, and evicts the
+// first key it finds unvisited, so reads never reorder the queue.
+type sievePolicy struct {
+	mu       sync.RWMutex
+	queue    *list.List
+	elements map[string]*list.Element
+	hand     *list.Element
+}
+
+// NewSIEVEPolicy evicts with the SIEVE algorithm. Reads take a shared lock only, which suits read
+// heavy workloads better than LRU, and its hit ratio matches or beats LRU on most web traces.
+func NewSIEVEPolicy() EvictionPolicy {
+	return &sievePolicy{queue: list.New(), elements: make(map[string]*list.Element)}
+}
+
+func (p *sievePolicy) OnSet(key string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if element, ok := p.elements[key]; ok {
+		atomic.StoreInt32(&element.Value.(*sieveEntry).visited, 1)
+		return
+	}
+	p.elements[key] = p.queue.PushFront(&sieveEntry{key: key})
+}
+
+func (p *sievePolicy) OnGet(key string) {
+	p.mu.RLock()
+	element, ok := p.elements[key]
+	if ok {
+		atomic.StoreInt32(&element.Value.(*sieveEntry).visited, 1)
+	}
+	p.mu.RUnlock()
+}
+
+func (p *sievePolicy) OnRemove(key string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	element, ok := p.elements[key]
+	if !ok {
+		return
+	}
+	if p.hand == element {
+		p.hand = element.Prev()
+	}
+	p.queue.Remove(element)
+	delete(p.elements, key)
+}
+
+// Victim moves the hand to the next unvisited evictable key, clearing the visited bits it passes.
+// After two rounds every evictable key was unvisited once, so it gives up on none.
+func (p *sievePolicy) Victim(evictable func(key string) bool) (string, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for steps := 2 * p.queue.Len(); steps > 0; steps-- {
+		if p.hand == nil {
+			p.hand = p.queue.Back()
+		}
+		if p.hand == nil {
+			break
+		}
+		entry := p.hand.Value.(*sieveEntry)
+		if evictable(entry.key) {
+			if atomic.CompareAndSwapInt32(&entry.visited, 1, 0) {
+				p.hand = p.hand.Prev()
+				continue
+			}
+			return entry.key, true
+		}
+		p.hand = p.hand.Prev()
+	}
+	return "", false
+}
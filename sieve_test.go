@@ -0,0 +1,64 @@
+package addcache
+
+import (
+	"strconv"
+	"testing"
+)
+
+// benchmarkReadHeavy runs reads of 1000 keys from parallel goroutines against a cache of 1000
+// entries, one operation in writeEvery is a write.
+func benchmarkReadHeavy(b *testing.B, writeEvery int, options ...Option) {
+	const keys = 1000
+	cache := NewCache(options...)
+	defer cache.Close()
+	names := make([]string, keys)
+	for i := range names {
+		names[i] = "key" + strconv.Itoa(i)
+		cache.Set(names[i], i)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for i := 0; pb.Next(); i++ {
+			key := names[i%keys]
+			if i%writeEvery == 0 {
+				cache.Set(key, i)
+			} else {
+				_, _ = cache.Get(key)
+			}
+		}
+	})
+}
+
+func BenchmarkReadHeavyLRU(b *testing.B) {
+	benchmarkReadHeavy(b, 20, WithMaxEntries(1000), WithEvictionPolicy(NewLRUPolicy()))
+}
+
+func BenchmarkReadHeavySIEVE(b *testing.B) {
+	benchmarkReadHeavy(b, 20, WithMaxEntries(1000), WithEvictionPolicy(NewSIEVEPolicy()))
+}
+
+// benchmarkPolicyReads records reads of 1000 keys from parallel goroutines, the part of a Get
+// that differs between the policies.
+func benchmarkPolicyReads(b *testing.B, policy EvictionPolicy) {
+	const keys = 1000
+	names := make([]string, keys)
+	for i := range names {
+		names[i] = "key" + strconv.Itoa(i)
+		policy.OnSet(names[i])
+	}
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for i := 0; pb.Next(); i++ {
+			policy.OnGet(names[i%keys])
+		}
+	})
+}
+
+func BenchmarkPolicyReadsLRU(b *testing.B) {
+	benchmarkPolicyReads(b, NewLRUPolicy())
+}
+
+func BenchmarkPolicyReadsSIEVE(b *testing.B) {
+	benchmarkPolicyReads(b, NewSIEVEPolicy())
+}
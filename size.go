@@ -0,0 +1,77 @@
+package addcache
+
+import "reflect"
+
+// approximateSize estimates the bytes referenced by a value by walking it with reflection.
+// Shared pointers are counted once, the result ignores allocator overhead.
+func approximateSize(v any) int {
+	if v == nil {
+		return 0
+	}
+	if size := storedSize(v); size > 0 {
+		return size
+	}
+	value := reflect.ValueOf(v)
+	return int(value.Type().Size()) + indirectSize(value, make(map[uintptr]struct{}))
+}
+
+// indirectSize returns the bytes a value references outside of its own memory.
+func indirectSize(value reflect.Value, seen map[uintptr]struct{}) int {
+	switch value.Kind() {
+	case reflect.String:
+		return value.Len()
+	case reflect.Ptr:
+		if value.IsNil() || visited(value.Pointer(), seen) {
+			return 0
+		}
+		elem := value.Elem()
+		return int(elem.Type().Size()) + indirectSize(elem, seen)
+	case reflect.Interface:
+		if value.IsNil() {
+			return 0
+		}
+		elem := value.Elem()
+		return int(elem.Type().Size()) + indirectSize(elem, seen)
+	case reflect.Slice:
+		if value.IsNil() || visited(value.Pointer(), seen) {
+			return 0
+		}
+		size := value.Cap() * int(value.Type().Elem().Size())
+		for i := 0; i < value.Len(); i++ {
+			size += indirectSize(value.Index(i), seen)
+		}
+		return size
+	case reflect.Array:
+		size := 0
+		for i := 0; i < value.Len(); i++ {
+			size += indirectSize(value.Index(i), seen)
+		}
+		return size
+	case reflect.Map:
+		if value.IsNil() || visited(value.Pointer(), seen) {
+			return 0
+		}
+		entry := int(value.Type().Key().Size() + value.Type().Elem().Size())
+		size := value.Len() * entry
+		iter := value.MapRange()
+		for iter.Next() {
+			size += indirectSize(iter.Key(), seen) + indirectSize(iter.Value(), seen)
+		}
+		return size
+	case reflect.Struct:
+		size := 0
+		for i := 0; i < value.NumField(); i++ {
+			size += indirectSize(value.Field(i), seen)
+		}
+		return size
+	}
+	return 0
+}
+
+func visited(pointer uintptr, seen map[uintptr]struct{}) bool {
+	if _, ok := seen[pointer]; ok {
+		return true
+	}
+	seen[pointer] = struct{}{}
+	return false
+}
@@ -0,0 +1,47 @@
+//go:build go1.21
+
+package addcache
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+)
+
+// SlogHooks logs every cache operation with its key, remaining TTL and value type.
+// Removals are logged with their reason, replaced data is covered by the Create entry.
+func SlogHooks(logger *slog.Logger, level slog.Level) Option {
+	return func(s *storage) {
+		log := func(operationType OperationType, key string, data any, attrs ...slog.Attr) {
+			if !logger.Enabled(context.Background(), level) {
+				return
+			}
+			attrs = append(attrs,
+				slog.String("operation", string(operationType)),
+				slog.String("key", key),
+				slog.String("type", fmt.Sprintf("%T", data)),
+			)
+			if s.name != "" {
+				attrs = append(attrs, slog.String("cache", s.name))
+			}
+			logger.LogAttrs(context.Background(), level, "addcache "+string(operationType), attrs...)
+		}
+
+		s.SetHook(CreateOperation, func(key string, data any) {
+			if ttl, ok := s.remainingTTL(key); ok {
+				log(CreateOperation, key, data, slog.Duration("ttl", ttl))
+				return
+			}
+			log(CreateOperation, key, data, slog.Bool("persistent", true))
+		})
+		s.SetHook(PressureOperation, func(key string, data any) {
+			log(PressureOperation, key, data, slog.Any("pressure", data))
+		})
+		s.OnEvicted(func(key string, data any, reason RemovalReason) {
+			if reason == RemovalReplaced {
+				return
+			}
+			log(DeleteOperation, key, data, slog.String("reason", reason.String()))
+		})
+	}
+}
@@ -0,0 +1,328 @@
+package addcache
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/cipher"
+	"encoding/gob"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"time"
+)
+
+const (
+	snapshotMagic = "ADDCACHE1"
+	// maxSnapshotEntries bounds the entry count of a snapshot header.
+	maxSnapshotEntries = math.MaxInt32
+	// snapshotBatch is the number of entries copied per read lock while a snapshot is written.
+	snapshotBatch = 1024
+)
+
+// SnapshotFormat selects the encoding written by SaveTo, LoadFrom detects it from the snapshot header.
+type SnapshotFormat byte
+
+const (
+	// SnapshotGob keeps concrete value types, custom types must be registered with gob.Register.
+	SnapshotGob SnapshotFormat = 'g'
+	// SnapshotJSON is human readable, values are restored as the types encoding/json decodes into any.
+	SnapshotJSON SnapshotFormat = 'j'
+	// SnapshotCodec encodes all entries with the Codec passed to WithSnapshotCodec, loading requires the same option.
+	SnapshotCodec SnapshotFormat = 'c'
+
+	// snapshotEncrypted wraps a snapshot of another format sealed by WithEncryption.
+	snapshotEncrypted SnapshotFormat = 'x'
+)
+
+// SnapshotEntry is the persisted form of one entry, expiration is kept as set time plus TTL.
+type SnapshotEntry struct {
+	Key        string
+	Data       any
+	SetTime    time.Time
+	TTL        time.Duration
+	Persistent bool
+	Held       bool
+}
+
+// WithSnapshotFormat selects the encoding of SaveTo, gob is the default.
+func WithSnapshotFormat(format SnapshotFormat) Option {
+	return func(s *storage) {
+		s.snapshotFormat = format
+	}
+}
+
+// WithSnapshotCodec makes SaveTo and WithPersistence encode snapshots with codec.
+func WithSnapshotCodec(codec Codec) Option {
+	return func(s *storage) {
+		s.snapshotFormat = SnapshotCodec
+		s.snapshotCodec = codec
+	}
+}
+
+// SaveTo writes all live entries to w, expired entries are skipped. Gob and JSON snapshots are
+// streamed, the read lock is held for a batch of entries at a time.
+func (s *storage) SaveTo(w io.Writer) error {
+	if err := s.readable(); err != nil {
+		return err
+	}
+	if s.optionErr != nil {
+		return s.optionErr
+	}
+	return writeSnapshot(w, s.snapshotFormat, s.snapshotCodec, s.aead, s.liveEntries())
+}
+
+// LoadFrom adds the entries of a snapshot, entries that expired in the meantime are skipped.
+// Gob and JSON snapshots are restored while they are read, entries before a corrupt part stay.
+func (s *storage) LoadFrom(r io.Reader) error {
+	if err := s.writable(); err != nil {
+		return err
+	}
+	return readSnapshotEach(r, s.snapshotCodec, s.aead, func(entry SnapshotEntry) {
+		s.restoreEntry(entry)
+	})
+}
+
+// NewCacheFromSnapshot creates a cache and fills it from the snapshot in r, preserving remaining TTLs.
+func NewCacheFromSnapshot(r io.Reader, options ...Option) (LocalCache, error) {
+	cache := NewCache(options...)
+	if err := cache.LoadFrom(r); err != nil {
+		_ = cache.Close()
+		return nil, err
+	}
+	return cache, nil
+}
+
+// entrySource yields the count entries of a snapshot one by one.
+type entrySource struct {
+	count int
+	each  func(emit func(SnapshotEntry) error) error
+}
+
+func sliceEntries(entries []SnapshotEntry) entrySource {
+	return entrySource{count: len(entries), each: func(emit func(SnapshotEntry) error) error {
+		for _, entry := range entries {
+			if err := emit(entry); err != nil {
+				return err
+			}
+		}
+		return nil
+	}}
+}
+
+// liveEntries yields the entries live when it is called. The keys are collected first, the
+// entries are then copied batch by batch and decoded without the lock. Keys removed meanwhile
+// are yielded as expired entries, which loading skips, so the count written up front holds.
+func (s *storage) liveEntries() entrySource {
+	now := s.now()
+	s.mu.RLock()
+	keys := make([]string, 0, len(s.data))
+	for key, sd := range s.data {
+		if !s.expiredLocked(key, sd, now) {
+			keys = append(keys, key)
+		}
+	}
+	s.mu.RUnlock()
+	return entrySource{count: len(keys), each: func(emit func(SnapshotEntry) error) error {
+		batch := make([]storageData, 0, minInt(len(keys), snapshotBatch))
+		live := make([]bool, 0, cap(batch))
+		for start := 0; start < len(keys); start += snapshotBatch {
+			end := minInt(start+snapshotBatch, len(keys))
+			batch, live = batch[:0], live[:0]
+			now := s.now()
+			s.mu.RLock()
+			for _, key := range keys[start:end] {
+				sd, ok := s.data[key]
+				batch = append(batch, sd)
+				live = append(live, ok && !s.expiredLocked(key, sd, now))
+			}
+			s.mu.RUnlock()
+			for i, key := range keys[start:end] {
+				if err := emit(s.snapshotEntryOf(key, batch[i], live[i])); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}}
+}
+
+// snapshotEntryOf converts an entry, one no longer live becomes an entry that expired long ago.
+func (s *storage) snapshotEntryOf(key string, sd storageData, live bool) SnapshotEntry {
+	if !live {
+		return SnapshotEntry{Key: key, TTL: time.Nanosecond}
+	}
+	return SnapshotEntry{
+		Key:        key,
+		Data:       s.valueOf(sd.data),
+		SetTime:    sd.setTime,
+		TTL:        sd.expireDuration,
+		Persistent: sd.isPersistence,
+		Held:       sd.held,
+	}
+}
+
+func (s *storage) restore(entries []SnapshotEntry) {
+	for _, entry := range entries {
+		s.restoreEntry(entry)
+	}
+}
+
+func (s *storage) restoreEntry(entry SnapshotEntry) {
+	sd := storageData{
+		isPersistence:  entry.Persistent,
+		held:           entry.Held,
+		setTime:        entry.SetTime,
+		expireDuration: entry.TTL,
+		data:           entry.Data,
+	}
+	if sd.isExpired(s.now()) {
+		return
+	}
+	if _, _, err := s.storeLocal(entry.Key, sd); err == nil {
+		s.mu.Lock()
+		s.seedLockTokensLocked(entry.Data)
+		s.mu.Unlock()
+	}
+}
+
+func writeSnapshot(w io.Writer, format SnapshotFormat, codec Codec, aead cipher.AEAD, entries entrySource) error {
+	if format == 0 {
+		format = SnapshotGob
+	}
+	if aead != nil {
+		var plain bytes.Buffer
+		if err := writeSnapshot(&plain, format, codec, nil, entries); err != nil {
+			return err
+		}
+		sealed, err := seal(aead, plain.Bytes())
+		if err != nil {
+			return err
+		}
+		if _, err = fmt.Fprintf(w, "%s%c\n", snapshotMagic, snapshotEncrypted); err != nil {
+			return err
+		}
+		_, err = w.Write(sealed)
+		return err
+	}
+	buffered := bufio.NewWriter(w)
+	if _, err := fmt.Fprintf(buffered, "%s%c\n", snapshotMagic, format); err != nil {
+		return err
+	}
+
+	var encode func(v any) error
+	switch format {
+	case SnapshotCodec:
+		if codec == nil {
+			return fmt.Errorf("%w: no codec configured", ErrSnapshotInvalid)
+		}
+		all := make([]SnapshotEntry, 0, entries.count)
+		if err := entries.each(func(entry SnapshotEntry) error {
+			all = append(all, entry)
+			return nil
+		}); err != nil {
+			return err
+		}
+		payload, err := codec.Marshal(all)
+		if err != nil {
+			return err
+		}
+		if _, err = buffered.Write(payload); err != nil {
+			return err
+		}
+		return buffered.Flush()
+	case SnapshotGob:
+		encode = gob.NewEncoder(buffered).Encode
+	case SnapshotJSON:
+		encode = json.NewEncoder(buffered).Encode
+	default:
+		return fmt.Errorf("%w: unknown format %q", ErrSnapshotInvalid, format)
+	}
+	if err := encode(entries.count); err != nil {
+		return err
+	}
+	if err := entries.each(func(entry SnapshotEntry) error {
+		if err := encode(entry); err != nil {
+			return fmt.Errorf("addcache: encode %q: %w", entry.Key, err)
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+	return buffered.Flush()
+}
+
+func readSnapshot(r io.Reader, codec Codec, aead cipher.AEAD) ([]SnapshotEntry, error) {
+	var entries []SnapshotEntry
+	if err := readSnapshotEach(r, codec, aead, func(entry SnapshotEntry) {
+		entries = append(entries, entry)
+	}); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// readSnapshotEach passes the entries of a snapshot to fn as they are decoded. Encrypted and
+// codec snapshots are read completely first.
+func readSnapshotEach(r io.Reader, codec Codec, aead cipher.AEAD, fn func(SnapshotEntry)) error {
+	buffered := bufio.NewReader(r)
+	header := make([]byte, len(snapshotMagic)+2)
+	if _, err := io.ReadFull(buffered, header); err != nil || string(header[:len(snapshotMagic)]) != snapshotMagic {
+		return ErrSnapshotInvalid
+	}
+
+	var decode func(v any) error
+	switch SnapshotFormat(header[len(snapshotMagic)]) {
+	case snapshotEncrypted:
+		if aead == nil {
+			return fmt.Errorf("%w: encrypted without WithEncryption", ErrSnapshotInvalid)
+		}
+		sealed, err := io.ReadAll(buffered)
+		if err != nil {
+			return err
+		}
+		plain, err := open(aead, sealed)
+		if err != nil {
+			return err
+		}
+		return readSnapshotEach(bytes.NewReader(plain), codec, nil, fn)
+	case SnapshotCodec:
+		if codec == nil {
+			return fmt.Errorf("%w: no codec configured", ErrSnapshotInvalid)
+		}
+		payload, err := io.ReadAll(buffered)
+		if err != nil {
+			return err
+		}
+		var entries []SnapshotEntry
+		if err = codec.Unmarshal(payload, &entries); err != nil {
+			return fmt.Errorf("%w: %v", ErrSnapshotInvalid, err)
+		}
+		for _, entry := range entries {
+			fn(entry)
+		}
+		return nil
+	case SnapshotGob:
+		decode = gob.NewDecoder(buffered).Decode
+	case SnapshotJSON:
+		decode = json.NewDecoder(buffered).Decode
+	default:
+		return fmt.Errorf("%w: unknown format %q", ErrSnapshotInvalid, header[len(snapshotMagic)])
+	}
+
+	var count int
+	if err := decode(&count); err != nil {
+		return fmt.Errorf("%w: %v", ErrSnapshotInvalid, err)
+	}
+	if count < 0 || count > maxSnapshotEntries {
+		return fmt.Errorf("%w: entry count %d", ErrSnapshotInvalid, count)
+	}
+	for i := 0; i < count; i++ {
+		var entry SnapshotEntry
+		if err := decode(&entry); err != nil {
+			return fmt.Errorf("%w: %v", ErrSnapshotInvalid, err)
+		}
+		fn(entry)
+	}
+	return nil
+}
@@ -0,0 +1,412 @@
+package addcache
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"math"
+	"math/rand"
+)
+
+// ZMember is a member of a sorted set with its score.
+type ZMember struct {
+	Member string
+	Score  float64
+}
+
+// SortedSet is the value of a sorted set key. It is immutable, writes replace it with a copy
+// sharing all nodes but the O(log n) ones on the modified paths of its two treaps, one ordered by
+// score and member for ranks and ranges, one by member for score lookups.
+type SortedSet struct {
+	byScore  *zNode
+	byMember *zNode
+}
+
+type zNode struct {
+	ZMember
+	priority    uint32
+	size        int
+	left, right *zNode
+}
+
+func init() {
+	gob.Register(&SortedSet{})
+}
+
+// Len returns the number of members.
+func (z *SortedSet) Len() int {
+	return z.byScore.count()
+}
+
+// Score returns the score of the member and whether it is in the set.
+func (z *SortedSet) Score(member string) (float64, bool) {
+	node := z.byMember
+	for node != nil {
+		switch {
+		case member < node.Member:
+			node = node.left
+		case member > node.Member:
+			node = node.right
+		default:
+			return node.Score, true
+		}
+	}
+	return 0, false
+}
+
+// Rank returns the zero based position of the member by ascending score and whether it is in the set.
+func (z *SortedSet) Rank(member string) (int, bool) {
+	score, ok := z.Score(member)
+	if !ok {
+		return 0, false
+	}
+	key := ZMember{Member: member, Score: score}
+	rank := 0
+	node := z.byScore
+	for node != nil {
+		switch {
+		case lessByScore(key, node.ZMember):
+			node = node.left
+		case lessByScore(node.ZMember, key):
+			rank += node.left.count() + 1
+			node = node.right
+		default:
+			return rank + node.left.count(), true
+		}
+	}
+	return 0, false
+}
+
+// Range returns the members from rank start to stop inclusive by ascending score, negative ranks
+// count from the highest score like in Redis.
+func (z *SortedSet) Range(start, stop int) []ZMember {
+	start, stop = listBounds(z.Len(), start, stop)
+	if start > stop {
+		return nil
+	}
+	members := make([]ZMember, 0, stop-start+1)
+	z.byScore.walk(func(member ZMember, rank int) bool {
+		if rank >= start {
+			members = append(members, member)
+		}
+		return rank < stop
+	}, 0)
+	return members
+}
+
+// Members returns all members by ascending score.
+func (z *SortedSet) Members() []ZMember {
+	return z.Range(0, -1)
+}
+
+// with returns a copy of the set with the member scored score.
+func (z *SortedSet) with(member string, score float64) *SortedSet {
+	next := z.without(member)
+	node := &zNode{ZMember: ZMember{Member: member, Score: score}, priority: rand.Uint32(), size: 1}
+	indexed := *node
+	next.byScore = insertNode(next.byScore, node, lessByScore)
+	next.byMember = insertNode(next.byMember, &indexed, lessByMember)
+	return next
+}
+
+// without returns a copy of the set without the member.
+func (z *SortedSet) without(member string) *SortedSet {
+	next := &SortedSet{byScore: z.byScore, byMember: z.byMember}
+	score, ok := z.Score(member)
+	if !ok {
+		return next
+	}
+	key := ZMember{Member: member, Score: score}
+	next.byScore = removeNode(next.byScore, key, lessByScore)
+	next.byMember = removeNode(next.byMember, key, lessByMember)
+	return next
+}
+
+func lessByScore(a, b ZMember) bool {
+	return a.Score < b.Score || a.Score == b.Score && a.Member < b.Member
+}
+
+func lessByMember(a, b ZMember) bool {
+	return a.Member < b.Member
+}
+
+func (n *zNode) count() int {
+	if n == nil {
+		return 0
+	}
+	return n.size
+}
+
+// walk visits the nodes in order starting at rank offset until visit returns false.
+func (n *zNode) walk(visit func(member ZMember, rank int) bool, offset int) bool {
+	if n == nil {
+		return true
+	}
+	if !n.left.walk(visit, offset) {
+		return false
+	}
+	rank := offset + n.left.count()
+	if !visit(n.ZMember, rank) {
+		return false
+	}
+	return n.right.walk(visit, rank+1)
+}
+
+// withChildren copies the node with new children, nodes are never changed once in a tree.
+func (n *zNode) withChildren(left, right *zNode) *zNode {
+	node := *n
+	node.left, node.right = left, right
+	node.size = left.count() + right.count() + 1
+	return &node
+}
+
+// splitNodes splits the tree into the nodes ordered before key and the rest, copying the split path.
+func splitNodes(n *zNode, key ZMember, less func(a, b ZMember) bool) (*zNode, *zNode) {
+	if n == nil {
+		return nil, nil
+	}
+	if less(n.ZMember, key) {
+		left, right := splitNodes(n.right, key, less)
+		return n.withChildren(n.left, left), right
+	}
+	left, right := splitNodes(n.left, key, less)
+	return left, n.withChildren(right, n.right)
+}
+
+func mergeNodes(left, right *zNode) *zNode {
+	switch {
+	case left == nil:
+		return right
+	case right == nil:
+		return left
+	case left.priority > right.priority:
+		return left.withChildren(left.left, mergeNodes(left.right, right))
+	}
+	return right.withChildren(mergeNodes(left, right.left), right.right)
+}
+
+func insertNode(root, node *zNode, less func(a, b ZMember) bool) *zNode {
+	left, right := splitNodes(root, node.ZMember, less)
+	return mergeNodes(mergeNodes(left, node), right)
+}
+
+// removeNode drops the node equal to key, the tree must contain it.
+func removeNode(n *zNode, key ZMember, less func(a, b ZMember) bool) *zNode {
+	if n == nil {
+		return nil
+	}
+	switch {
+	case less(key, n.ZMember):
+		return n.withChildren(removeNode(n.left, key, less), n.right)
+	case less(n.ZMember, key):
+		return n.withChildren(n.left, removeNode(n.right, key, less))
+	}
+	return mergeNodes(n.left, n.right)
+}
+
+// GobEncode encodes the members by ascending score for snapshots and WithSerialization.
+func (z *SortedSet) GobEncode() ([]byte, error) {
+	var buf bytes.Buffer
+	err := gob.NewEncoder(&buf).Encode(z.Members())
+	return buf.Bytes(), err
+}
+
+func (z *SortedSet) GobDecode(raw []byte) error {
+	var members []ZMember
+	if err := gob.NewDecoder(bytes.NewReader(raw)).Decode(&members); err != nil {
+		return err
+	}
+	z.load(members)
+	return nil
+}
+
+// MarshalJSON encodes the members by ascending score for JSON snapshots.
+func (z *SortedSet) MarshalJSON() ([]byte, error) {
+	return json.Marshal(z.Members())
+}
+
+func (z *SortedSet) UnmarshalJSON(raw []byte) error {
+	var members []ZMember
+	if err := json.Unmarshal(raw, &members); err != nil {
+		return err
+	}
+	z.load(members)
+	return nil
+}
+
+func (z *SortedSet) load(members []ZMember) {
+	loaded := &SortedSet{}
+	for _, member := range members {
+		loaded = loaded.with(member.Member, member.Score)
+	}
+	*z = *loaded
+}
+
+// ZAdd sets the scores of the members in the sorted set stored under the key and returns how many
+// were new. Sorted sets are stored as *SortedSet, a missing key starts an empty set stored like
+// Set and the expiration of an existing key is kept. ErrTypeMismatch is returned when the key
+// holds another value, ErrScoreNotANumber a NaN score, in which case nothing is set.
+func (s *storage) ZAdd(key string, scores map[string]float64) (int, error) {
+	for _, score := range scores {
+		if math.IsNaN(score) {
+			return 0, ErrScoreNotANumber
+		}
+	}
+	added := 0
+	err := s.update(key, func(current any, ok bool) (any, error) {
+		set, err := sortedSetOf(current, ok)
+		if err != nil {
+			return nil, err
+		}
+		for member, score := range scores {
+			if _, exists := set.Score(member); !exists {
+				added++
+			}
+			set = set.with(member, score)
+		}
+		return set, nil
+	})
+	return added, err
+}
+
+// ZIncrBy adds delta to the score of the member, missing members start at zero, and returns the
+// new score. A NaN delta or result, such as adding -Inf to +Inf, fails with ErrScoreNotANumber.
+func (s *storage) ZIncrBy(key string, member string, delta float64) (float64, error) {
+	if math.IsNaN(delta) {
+		return 0, ErrScoreNotANumber
+	}
+	var score float64
+	err := s.update(key, func(current any, ok bool) (any, error) {
+		set, err := sortedSetOf(current, ok)
+		if err != nil {
+			return nil, err
+		}
+		score, _ = set.Score(member)
+		score += delta
+		if math.IsNaN(score) {
+			return nil, ErrScoreNotANumber
+		}
+		return set.with(member, score), nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return score, nil
+}
+
+// ZRem removes the members and returns how many existed, the key is deleted with its last member.
+func (s *storage) ZRem(key string, members ...string) (int, error) {
+	removed := 0
+	err := s.update(key, func(current any, ok bool) (any, error) {
+		if !ok {
+			return nil, errUnchanged
+		}
+		set, err := sortedSetOf(current, ok)
+		if err != nil {
+			return nil, err
+		}
+		for _, member := range members {
+			if _, exists := set.Score(member); exists {
+				set = set.without(member)
+				removed++
+			}
+		}
+		if removed == 0 {
+			return nil, errUnchanged
+		}
+		if set.Len() == 0 {
+			return nil, nil
+		}
+		return set, nil
+	})
+	return removed, err
+}
+
+// ZRange returns the members from rank start to stop inclusive by ascending score, see SortedSet.Range.
+func (s *storage) ZRange(key string, start, stop int) ([]ZMember, error) {
+	set, err := s.readSortedSet(key)
+	if err != nil {
+		return nil, err
+	}
+	return set.Range(start, stop), nil
+}
+
+// ZRevRange returns the members from rank start to stop inclusive by descending score, for leaderboards.
+func (s *storage) ZRevRange(key string, start, stop int) ([]ZMember, error) {
+	set, err := s.readSortedSet(key)
+	if err != nil {
+		return nil, err
+	}
+	start, stop = listBounds(set.Len(), start, stop)
+	if start > stop {
+		return nil, nil
+	}
+	members := set.Range(set.Len()-1-stop, set.Len()-1-start)
+	for i, j := 0, len(members)-1; i < j; i, j = i+1, j-1 {
+		members[i], members[j] = members[j], members[i]
+	}
+	return members, nil
+}
+
+// ZRank returns the rank of the member by ascending score, ErrCacheKeyNotFound reports a missing key or member.
+func (s *storage) ZRank(key string, member string) (int, error) {
+	set, err := s.readSortedSet(key)
+	if err != nil {
+		return 0, err
+	}
+	rank, ok := set.Rank(member)
+	if !ok {
+		return 0, ErrCacheKeyNotFound
+	}
+	return rank, nil
+}
+
+// ZRevRank returns the rank of the member by descending score, see ZRank.
+func (s *storage) ZRevRank(key string, member string) (int, error) {
+	set, err := s.readSortedSet(key)
+	if err != nil {
+		return 0, err
+	}
+	rank, ok := set.Rank(member)
+	if !ok {
+		return 0, ErrCacheKeyNotFound
+	}
+	return set.Len() - 1 - rank, nil
+}
+
+// ZScore returns the score of the member, see ZRank.
+func (s *storage) ZScore(key string, member string) (float64, error) {
+	set, err := s.readSortedSet(key)
+	if err != nil {
+		return 0, err
+	}
+	score, ok := set.Score(member)
+	if !ok {
+		return 0, ErrCacheKeyNotFound
+	}
+	return score, nil
+}
+
+// readSortedSet returns the sorted set stored under the key, a missing key is an empty set.
+func (s *storage) readSortedSet(key string) (*SortedSet, error) {
+	data, err := s.Get(key)
+	if errors.Is(err, ErrCacheKeyNotFound) {
+		return &SortedSet{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return sortedSetOf(data, true)
+}
+
+func sortedSetOf(data any, ok bool) (*SortedSet, error) {
+	if !ok {
+		return &SortedSet{}, nil
+	}
+	set, isSet := data.(*SortedSet)
+	if !isSet {
+		return nil, ErrTypeMismatch
+	}
+	return set, nil
+}
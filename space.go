@@ -0,0 +1,101 @@
+package addcache
+
+import (
+	"math"
+	"sync/atomic"
+	"time"
+)
+
+const compactionSampleSize = 64
+
+// SpaceUsage compares the entries held in memory with the entries still readable.
+// Expired and Invalidated count dead entries waiting to be reclaimed, by TTL or by BumpEpoch.
+type SpaceUsage struct {
+	Physical    int   `json:"physical"`
+	Logical     int   `json:"logical"`
+	Expired     int   `json:"expired"`
+	Invalidated int   `json:"invalidated"`
+	Compactions int64 `json:"compactions"`
+}
+
+// Amplification returns physical per logical entries, one when nothing waits for reclamation.
+func (u SpaceUsage) Amplification() float64 {
+	if u.Physical == 0 {
+		return 1
+	}
+	if u.Logical == 0 {
+		return math.Inf(1)
+	}
+	return float64(u.Physical) / float64(u.Logical)
+}
+
+// compaction reclaims dead entries early once their share exceeds the threshold.
+type compaction struct {
+	compactions int64
+	threshold   float64
+	interval    time.Duration
+}
+
+// WithCompactionThreshold reclaims expired and invalidated entries ahead of the cleanup interval
+// whenever the space amplification exceeds threshold, e.g. 1.5 for a third of dead entries.
+// It is estimated from a sample of entries every interval.
+func WithCompactionThreshold(threshold float64, interval time.Duration) Option {
+	return func(s *storage) {
+		s.compaction = &compaction{threshold: threshold, interval: interval}
+	}
+}
+
+// SpaceUsage scans all entries and reports how many of them are dead.
+func (s *storage) SpaceUsage() SpaceUsage {
+	now := s.now()
+	var usage SpaceUsage
+	s.mu.RLock()
+	usage.Physical = len(s.data)
+	for key, sd := range s.data {
+		switch {
+		case sd.isExpired(now):
+			usage.Expired++
+		case s.staleLocked(key, sd):
+			usage.Invalidated++
+		}
+	}
+	s.mu.RUnlock()
+	usage.Logical = usage.Physical - usage.Expired - usage.Invalidated
+	if s.compaction != nil {
+		usage.Compactions = atomic.LoadInt64(&s.compaction.compactions)
+	}
+	return usage
+}
+
+func (s *storage) compactionLoop(t Ticker) {
+	defer t.Stop()
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-t.C():
+			if s.sampledAmplification() > s.compaction.threshold {
+				atomic.AddInt64(&s.compaction.compactions, 1)
+				s.removeExpired()
+			}
+		}
+	}
+}
+
+// sampledAmplification estimates the space amplification from the entries visited first
+// by the randomized map iteration.
+func (s *storage) sampledAmplification() float64 {
+	now := s.now()
+	var sampled, dead int
+	s.mu.RLock()
+	for key, sd := range s.data {
+		if s.expiredLocked(key, sd, now) {
+			dead++
+		}
+		if sampled++; sampled == compactionSampleSize {
+			break
+		}
+	}
+	s.mu.RUnlock()
+	return SpaceUsage{Physical: sampled, Logical: sampled - dead}.Amplification()
+}
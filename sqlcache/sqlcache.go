@@ -0,0 +1,203 @@
+// Package sqlcache caches the results of database/sql queries in an addcache.Cache. Results are
+// keyed by the whitespace normalized query, its arguments and the versions of the tables it reads.
+// Invalidating a table replaces its version, so every cached result reading the table misses
+// from then on and expires with its TTL.
+//
+// Tables are detected from the FROM, JOIN, INTO and UPDATE clauses of a statement, QueryTables
+// and Invalidate take them explicitly for statements the detection does not cover such as views
+// or stored procedures.
+package sqlcache
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/gob"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/addit-digital/addcache"
+)
+
+const defaultKeyPrefix = "sqlcache"
+
+// tablePattern matches the table names following the clauses that read or write a table.
+var tablePattern = regexp.MustCompile("(?i)\\b(?:from|join|into|update)\\s+([`\"\\[]?[\\w.]+[`\"\\]]?)")
+
+// Querier runs queries, *sql.DB, *sql.Tx and *sql.Conn implement it.
+type Querier interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+}
+
+// Execer runs statements, *sql.DB, *sql.Tx and *sql.Conn implement it.
+type Execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+// Result is the materialized result of a query, it is registered with gob for caches encoding values.
+// Rows hold the values database/sql scans into an any destination.
+type Result struct {
+	Columns []string
+	Rows    [][]any
+}
+
+func init() {
+	gob.Register(Result{})
+	gob.Register(time.Time{})
+}
+
+// Option configures New.
+type Option func(c *Cache)
+
+// WithKeyPrefix sets the prefix of result and table version keys, "sqlcache" by default.
+func WithKeyPrefix(prefix string) Option {
+	return func(c *Cache) {
+		c.keyPrefix = prefix
+	}
+}
+
+// Cache caches query results and invalidates them by table.
+type Cache struct {
+	cache     addcache.Cache
+	keyPrefix string
+}
+
+// New caches results in cache, which stays owned by the caller.
+func New(cache addcache.Cache, options ...Option) *Cache {
+	c := &Cache{cache: cache, keyPrefix: defaultKeyPrefix}
+	for _, option := range options {
+		option(c)
+	}
+	return c
+}
+
+// CachedQuery runs query on db unless its result is cached in cache, results are cached for ttl.
+func CachedQuery(ctx context.Context, db Querier, cache addcache.Cache, ttl time.Duration, query string, args ...any) (*Result, error) {
+	return New(cache).Query(ctx, db, ttl, query, args...)
+}
+
+// Query returns the cached result of query with args or runs it on db and caches its result for ttl.
+func (c *Cache) Query(ctx context.Context, db Querier, ttl time.Duration, query string, args ...any) (*Result, error) {
+	return c.QueryTables(ctx, db, ttl, Tables(query), query, args...)
+}
+
+// QueryTables is Query with the tables whose invalidation drops the result.
+func (c *Cache) QueryTables(ctx context.Context, db Querier, ttl time.Duration, tables []string, query string, args ...any) (*Result, error) {
+	key := c.key(tables, query, args)
+	if data, err := c.cache.Get(key); err == nil {
+		if result, ok := data.(Result); ok {
+			return &result, nil
+		}
+	}
+	result, err := run(ctx, db, query, args)
+	if err != nil {
+		return nil, err
+	}
+	c.cache.SetEx(key, *result, ttl)
+	return result, nil
+}
+
+// Exec runs the statement on db and invalidates the tables it writes once it succeeded.
+func (c *Cache) Exec(ctx context.Context, db Execer, query string, args ...any) (sql.Result, error) {
+	result, err := db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	c.Invalidate(Tables(query)...)
+	return result, nil
+}
+
+// Invalidate drops the cached results reading any of the tables.
+func (c *Cache) Invalidate(tables ...string) {
+	for _, table := range tables {
+		c.cache.SetPersistent(c.versionKey(normalizeTable(table)), newVersion())
+	}
+}
+
+// Tables returns the sorted lower cased tables named in the FROM, JOIN, INTO and UPDATE clauses of query.
+func Tables(query string) []string {
+	seen := make(map[string]bool)
+	var tables []string
+	for _, match := range tablePattern.FindAllStringSubmatch(query, -1) {
+		table := normalizeTable(match[1])
+		if table != "" && !seen[table] {
+			seen[table] = true
+			tables = append(tables, table)
+		}
+	}
+	sort.Strings(tables)
+	return tables
+}
+
+func normalizeTable(table string) string {
+	return strings.ToLower(strings.Trim(table, "`\"[]"))
+}
+
+// key hashes the normalized query, the arguments and the current versions of the tables.
+func (c *Cache) key(tables []string, query string, args []any) string {
+	hash := sha256.New()
+	fmt.Fprintf(hash, "%s\n", strings.Join(strings.Fields(query), " "))
+	for _, arg := range args {
+		fmt.Fprintf(hash, "%T:%v\n", arg, arg)
+	}
+	for _, table := range tables {
+		table = normalizeTable(table)
+		fmt.Fprintf(hash, "%s@%s\n", table, c.version(table))
+	}
+	return c.keyPrefix + ":" + hex.EncodeToString(hash.Sum(nil))
+}
+
+// version returns the version of table, a missing version is replaced by a new one so results
+// cached before it was evicted can not match again.
+func (c *Cache) version(table string) string {
+	key := c.versionKey(table)
+	if data, err := c.cache.Get(key); err == nil {
+		if version, ok := data.(string); ok {
+			return version
+		}
+	}
+	version := newVersion()
+	c.cache.SetPersistent(key, version)
+	return version
+}
+
+func (c *Cache) versionKey(table string) string {
+	return c.keyPrefix + ":table:" + table
+}
+
+func newVersion() string {
+	return fmt.Sprintf("%x", time.Now().UnixNano())
+}
+
+// run executes the query and reads all rows.
+func run(ctx context.Context, db Querier, query string, args []any) (*Result, error) {
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+	result := &Result{Columns: columns}
+	for rows.Next() {
+		row := make([]any, len(columns))
+		pointers := make([]any, len(columns))
+		for i := range row {
+			pointers[i] = &row[i]
+		}
+		if err := rows.Scan(pointers...); err != nil {
+			return nil, err
+		}
+		result.Rows = append(result.Rows, row)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
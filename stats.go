@@ -0,0 +1,78 @@
+package addcache
+
+import "sync/atomic"
+
+// Stats is a point in time view of cache activity counters.
+type Stats struct {
+	Hits        int64 `json:"hits"`
+	Misses      int64 `json:"misses"`
+	Sets        int64 `json:"sets"`
+	Deletes     int64 `json:"deletes"`
+	Expirations int64 `json:"expirations"`
+	Evictions   int64 `json:"evictions"`
+	Rejections  int64 `json:"rejections"`
+	Entries     int64 `json:"entries"`
+	// StripeCollisions counts LockKey and GetOrCompute calls that waited for a lock stripe held for
+	// another key. It stays low with the default hasher, a steady rise suggests colliding keys.
+	StripeCollisions int64 `json:"stripe_collisions"`
+}
+
+// HitRatio returns the share of lookups that found data, zero without lookups.
+func (st Stats) HitRatio() float64 {
+	total := st.Hits + st.Misses
+	if total == 0 {
+		return 0
+	}
+	return float64(st.Hits) / float64(total)
+}
+
+func (st Stats) add(other Stats) Stats {
+	return Stats{
+		Hits:             st.Hits + other.Hits,
+		Misses:           st.Misses + other.Misses,
+		Sets:             st.Sets + other.Sets,
+		Deletes:          st.Deletes + other.Deletes,
+		Expirations:      st.Expirations + other.Expirations,
+		Evictions:        st.Evictions + other.Evictions,
+		Rejections:       st.Rejections + other.Rejections,
+		Entries:          st.Entries + other.Entries,
+		StripeCollisions: st.StripeCollisions + other.StripeCollisions,
+	}
+}
+
+// statsCounters are updated atomically and must stay 64-bit aligned.
+type statsCounters struct {
+	hits        int64
+	misses      int64
+	sets        int64
+	deletes     int64
+	expirations int64
+	evictions   int64
+	rejections  int64
+}
+
+func (c *statsCounters) hit()    { atomic.AddInt64(&c.hits, 1) }
+func (c *statsCounters) miss()   { atomic.AddInt64(&c.misses, 1) }
+func (c *statsCounters) set()    { atomic.AddInt64(&c.sets, 1) }
+func (c *statsCounters) delete() { atomic.AddInt64(&c.deletes, 1) }
+func (c *statsCounters) evict()  { atomic.AddInt64(&c.evictions, 1) }
+func (c *statsCounters) reject() { atomic.AddInt64(&c.rejections, 1) }
+
+func (c *statsCounters) expire() { atomic.AddInt64(&c.expirations, 1) }
+
+func (s *storage) Stats() Stats {
+	s.mu.RLock()
+	entries := len(s.data)
+	s.mu.RUnlock()
+	return Stats{
+		Hits:             atomic.LoadInt64(&s.counters.hits),
+		Misses:           atomic.LoadInt64(&s.counters.misses),
+		Sets:             atomic.LoadInt64(&s.counters.sets),
+		Deletes:          atomic.LoadInt64(&s.counters.deletes),
+		Expirations:      atomic.LoadInt64(&s.counters.expirations),
+		Evictions:        atomic.LoadInt64(&s.counters.evictions),
+		Rejections:       atomic.LoadInt64(&s.counters.rejections),
+		Entries:          int64(entries),
+		StripeCollisions: atomic.LoadInt64(&s.keyLocks.collisions),
+	}
+}
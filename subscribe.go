@@ -0,0 +1,122 @@
+package addcache
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+type subscriber struct {
+	mu     sync.Mutex
+	values chan any
+	closed bool
+	from   uint64
+}
+
+// Subscribe delivers the current value of the key, if there is one, and then every value written
+// to it, nil reports that the key was deleted, expired or evicted. The channel holds only the
+// latest value, so a slow consumer skips intermediate values but always ends up with the current
+// one, e.g. to reconfigure a component whenever a config entry changes. The channel is closed by
+// the returned CancelFunc or by Close.
+func (s *storage) Subscribe(key string) (<-chan any, CancelFunc) {
+	sub := &subscriber{values: make(chan any, 1)}
+	// holding the subscriber lock until the current value is sent keeps newer values behind it
+	sub.mu.Lock()
+	s.mu.Lock()
+	s.hooksMu.Lock()
+	if atomic.LoadInt32(&s.closed) == 1 {
+		s.hooksMu.Unlock()
+		s.mu.Unlock()
+		sub.closed = true
+		close(sub.values)
+		sub.mu.Unlock()
+		return sub.values, func() {}
+	}
+	if s.subscribers == nil {
+		s.subscribers = make(map[string][]*subscriber)
+	}
+	sub.from = s.notifications.seq
+	s.subscribers[key] = append(s.subscribers[key], sub)
+	atomic.AddInt32(&s.notifications.listeners, 1)
+	s.hooksMu.Unlock()
+	current, ok := s.data[key]
+	ok = ok && !s.expiredLocked(key, current, s.now())
+	s.mu.Unlock()
+	if ok {
+		if data, err := s.decodeValue(current.data); err == nil {
+			sub.sendLocked(data)
+		}
+	}
+	sub.mu.Unlock()
+	return sub.values, func() {
+		s.hooksMu.Lock()
+		subscribers := s.subscribers[key]
+		for i, registered := range subscribers {
+			if registered == sub {
+				s.subscribers[key] = append(subscribers[:i:i], subscribers[i+1:]...)
+				atomic.AddInt32(&s.notifications.listeners, -1)
+				break
+			}
+		}
+		if len(s.subscribers[key]) == 0 {
+			delete(s.subscribers, key)
+		}
+		s.hooksMu.Unlock()
+		sub.close()
+	}
+}
+
+// sendLocked replaces an undelivered value with data, the caller holds the subscriber lock.
+func (sub *subscriber) sendLocked(data any) {
+	if sub.closed {
+		return
+	}
+	select {
+	case <-sub.values:
+	default:
+	}
+	sub.values <- data
+}
+
+// send delivers a change queued after the subscription was made.
+func (sub *subscriber) send(seq uint64, data any) {
+	sub.mu.Lock()
+	defer sub.mu.Unlock()
+	if seq > sub.from {
+		sub.sendLocked(data)
+	}
+}
+
+func (sub *subscriber) close() {
+	sub.mu.Lock()
+	defer sub.mu.Unlock()
+	if !sub.closed {
+		sub.closed = true
+		close(sub.values)
+	}
+}
+
+// deliver sends the value written to the key, or nil for a removal, to its subscribers.
+// The caller holds the hooks read lock.
+func (s *storage) deliver(change notification) {
+	subscribers := s.subscribers[change.key]
+	if len(subscribers) == 0 {
+		return
+	}
+	value := change.value
+	if change.eventType != EventCreate && change.eventType != EventUpdate {
+		value = nil
+	}
+	for _, sub := range subscribers {
+		sub.send(change.seq, value)
+	}
+}
+
+// closeSubscribersLocked ends all subscriptions when the cache is closed, the caller holds the hooks lock.
+func (s *storage) closeSubscribersLocked() {
+	for _, subscribers := range s.subscribers {
+		for _, sub := range subscribers {
+			sub.close()
+		}
+	}
+	s.subscribers = nil
+}
@@ -0,0 +1,319 @@
+package addcache
+
+import (
+	"errors"
+	"log"
+	"time"
+)
+
+// Backend is the remote second tier of a TieredCache, e.g. a Redis or memcached client.
+// Get reports missing keys with ErrCacheKeyNotFound, a ttl of zero persists the data.
+type Backend interface {
+	Get(key string) (any, error)
+	Set(key string, data any, ttl time.Duration) error
+	Delete(key string) error
+}
+
+// BackendIncrementer is implemented by backends with atomic counters, TieredCache uses it for Increment.
+type BackendIncrementer interface {
+	Increment(key string, delta int64) (int64, error)
+}
+
+// BackendTTLGetter is implemented by backends reporting the remaining TTL of a key, a ttl of zero
+// persists. TieredCache uses it to expire local copies no later than their L2 entry.
+type BackendTTLGetter interface {
+	GetWithTTL(key string) (any, time.Duration, error)
+}
+
+// BackendErrorHandler receives failed backend writes, which have no error result on Cache.
+type BackendErrorHandler func(operation string, key string, err error)
+
+// TieredCache reads through a local L1 cache into a remote L2 Backend and writes to both.
+// L1 entries live at most for the L1 TTL, so local copies of keys changed by other instances
+// refresh regularly, and copies read from a BackendTTLGetter no longer than their L2 entry.
+// Concurrent misses of a key share one backend read, which a local cache of NewCache also
+// shares with the calls of its loaders, by identity with WithLoadIdentity. Hooks are those of
+// the local cache.
+type TieredCache struct {
+	local      LocalCache
+	remote     Backend
+	l1TTL      time.Duration
+	l2TTL      time.Duration
+	backendErr BackendErrorHandler
+	flights    *loaders
+}
+
+var _ Cache = (*TieredCache)(nil)
+
+// TieredOption configures NewTieredCache.
+type TieredOption func(t *TieredCache)
+
+// WithL1TTL bounds the lifetime of local copies, zero keeps the TTL written to or read from L2.
+// Copies read from a backend that does not implement BackendTTLGetter persist without it.
+func WithL1TTL(ttl time.Duration) TieredOption {
+	return func(t *TieredCache) {
+		t.l1TTL = ttl
+	}
+}
+
+// WithL2TTL is the TTL of Set and GetAndSet in the backend, zero persists.
+func WithL2TTL(ttl time.Duration) TieredOption {
+	return func(t *TieredCache) {
+		t.l2TTL = ttl
+	}
+}
+
+// WithBackendErrorHandler replaces the default handler, which logs failed backend writes.
+func WithBackendErrorHandler(handler BackendErrorHandler) TieredOption {
+	return func(t *TieredCache) {
+		if handler != nil {
+			t.backendErr = handler
+		}
+	}
+}
+
+// NewTieredCache composes local as L1 with remote as L2.
+func NewTieredCache(local LocalCache, remote Backend, options ...TieredOption) *TieredCache {
+	t := &TieredCache{
+		local:      local,
+		remote:     remote,
+		backendErr: logBackendError,
+		flights:    &loaders{},
+	}
+	if s, ok := local.(*storage); ok {
+		t.flights = &s.loaders
+	}
+	for _, option := range options {
+		option(t)
+	}
+	return t
+}
+
+func logBackendError(operation string, key string, err error) {
+	log.Printf("addcache: backend %s for key %q failed: %v", operation, key, err)
+}
+
+// Local returns the L1 cache.
+func (t *TieredCache) Local() LocalCache {
+	return t.local
+}
+
+func (t *TieredCache) Set(key string, data any) {
+	t.write("Set", key, data, t.l2TTL)
+}
+
+func (t *TieredCache) SetPersistent(key string, data any) {
+	t.write("SetPersistent", key, data, 0)
+}
+
+func (t *TieredCache) SetEx(key string, data any, duration time.Duration) {
+	t.write("SetEx", key, data, duration)
+}
+
+func (t *TieredCache) Get(key string) (any, error) {
+	result, err := t.GetResult(key)
+	return result.Value, err
+}
+
+// GetResult reads like Get, Source tells which tier served the value. The TTL of a value read
+// from L2 is the lifetime of its new local copy.
+func (t *TieredCache) GetResult(key string) (Result, error) {
+	if result, err := t.local.GetResult(key); !errors.Is(err, ErrCacheKeyNotFound) {
+		return result, err
+	}
+	var ttl time.Duration
+	data, remaining, shared, err := t.flights.share(t.flights.flightOf(key), func() (any, time.Duration, error) {
+		data, remaining, err := t.remoteGet(key)
+		if err == nil {
+			ttl = t.setLocal(key, data, remaining)
+		}
+		return data, remaining, err
+	})
+	if err != nil {
+		return Result{}, err
+	}
+	if shared {
+		// the read may have been made for another key of the identity
+		if copied, err := t.local.GetResult(key); err == nil {
+			ttl = copied.TTL
+		} else {
+			ttl = t.setLocal(key, data, remaining)
+		}
+	}
+	return Result{Value: data, TTL: ttl, Persistent: ttl == 0, Source: SourceL2}, nil
+}
+
+// remoteGet reads the key from L2 with its remaining TTL, zero when the backend does not report it.
+func (t *TieredCache) remoteGet(key string) (any, time.Duration, error) {
+	if getter, ok := t.remote.(BackendTTLGetter); ok {
+		return getter.GetWithTTL(key)
+	}
+	data, err := t.remote.Get(key)
+	return data, 0, err
+}
+
+// Delete removes the key from both tiers.
+func (t *TieredCache) Delete(key string) {
+	if err := t.remote.Delete(key); err != nil {
+		t.backendErr("Delete", key, err)
+	}
+	t.local.Delete(key)
+}
+
+// GetAndDelete is not atomic across instances sharing the backend.
+func (t *TieredCache) GetAndDelete(key string) (any, error) {
+	data, err := t.Get(key)
+	if err != nil {
+		return nil, err
+	}
+	if err = t.remote.Delete(key); err != nil {
+		return nil, err
+	}
+	t.local.Delete(key)
+	return data, nil
+}
+
+// GetAndSet is not atomic across instances sharing the backend.
+func (t *TieredCache) GetAndSet(key string, newData any) (any, error) {
+	previous, err := t.Get(key)
+	if err != nil && !errors.Is(err, ErrCacheKeyNotFound) {
+		return nil, err
+	}
+	if setErr := t.remote.Set(key, newData, t.l2TTL); setErr != nil {
+		return nil, setErr
+	}
+	t.setLocal(key, newData, t.l2TTL)
+	return previous, err
+}
+
+// Increment is atomic when the backend implements BackendIncrementer, the local copy is dropped.
+// Otherwise the counter is read and written back, which races with other instances.
+func (t *TieredCache) Increment(key string, delta int64) (int64, error) {
+	if incrementer, ok := t.remote.(BackendIncrementer); ok {
+		value, err := incrementer.Increment(key, delta)
+		t.local.Delete(key)
+		return value, err
+	}
+	unlock := t.local.LockKey(key)
+	defer unlock()
+	data, err := t.Get(key)
+	if err != nil && !errors.Is(err, ErrCacheKeyNotFound) {
+		return 0, err
+	}
+	current, ok := int64(0), true
+	if err == nil {
+		current, ok = toInt64(data)
+	}
+	if !ok {
+		return 0, ErrCacheValueNotInteger
+	}
+	current += delta
+	if err = t.remote.Set(key, current, t.l2TTL); err != nil {
+		return 0, err
+	}
+	t.setLocal(key, current, t.l2TTL)
+	return current, nil
+}
+
+func (t *TieredCache) Decrement(key string, delta int64) (int64, error) {
+	return t.Increment(key, -delta)
+}
+
+func (t *TieredCache) CreateKey(args ...string) string {
+	return t.local.CreateKey(args...)
+}
+
+func (t *TieredCache) CreateKeyWithDelimiter(delimiter string, args ...string) string {
+	return t.local.CreateKeyWithDelimiter(delimiter, args...)
+}
+
+// StopCleanup stops the cleanup of the local cache.
+//
+// Deprecated: use Close.
+func (t *TieredCache) StopCleanup() {
+	t.local.StopCleanup()
+}
+
+// Close closes the local cache, the backend is owned by the caller.
+func (t *TieredCache) Close() error {
+	return t.local.Close()
+}
+
+func (t *TieredCache) SetHook(operationType OperationType, handlerFunctions ...HandlerFunc) HookID {
+	return t.local.SetHook(operationType, handlerFunctions...)
+}
+
+func (t *TieredCache) RemoveHook(operationType OperationType, id HookID) {
+	t.local.RemoveHook(operationType, id)
+}
+
+func (t *TieredCache) ClearHooks(operationType OperationType) {
+	t.local.ClearHooks(operationType)
+}
+
+// write stores the data in the backend first, the local copy is only written when that succeeded.
+func (t *TieredCache) write(operation string, key string, data any, ttl time.Duration) {
+	if err := t.remote.Set(key, data, ttl); err != nil {
+		t.backendErr(operation, key, err)
+		t.local.Delete(key)
+		return
+	}
+	t.setLocal(key, data, ttl)
+}
+
+// setLocal writes the L1 copy, bounded by the L1 TTL, and returns its TTL.
+func (t *TieredCache) setLocal(key string, data any, ttl time.Duration) time.Duration {
+	if t.l1TTL > 0 && (ttl <= 0 || ttl > t.l1TTL) {
+		ttl = t.l1TTL
+	}
+	if ttl > 0 {
+		t.local.SetEx(key, data, ttl)
+	} else {
+		t.local.SetPersistent(key, data)
+	}
+	return ttl
+}
+
+// CacheBackend adapts a Cache, e.g. a shared in memory instance in tests, to a Backend.
+func CacheBackend(cache Cache) Backend {
+	return cacheBackend{cache: cache}
+}
+
+type cacheBackend struct {
+	cache Cache
+}
+
+func (b cacheBackend) Get(key string) (any, error) {
+	return b.cache.Get(key)
+}
+
+// GetWithTTL reports the TTL of caches with GetResult, the others' keys read as persistent.
+func (b cacheBackend) GetWithTTL(key string) (any, time.Duration, error) {
+	reader, ok := b.cache.(interface {
+		GetResult(key string) (Result, error)
+	})
+	if !ok {
+		data, err := b.cache.Get(key)
+		return data, 0, err
+	}
+	result, err := reader.GetResult(key)
+	return result.Value, result.TTL, err
+}
+
+func (b cacheBackend) Set(key string, data any, ttl time.Duration) error {
+	if ttl > 0 {
+		b.cache.SetEx(key, data, ttl)
+	} else {
+		b.cache.SetPersistent(key, data)
+	}
+	return nil
+}
+
+func (b cacheBackend) Delete(key string) error {
+	b.cache.Delete(key)
+	return nil
+}
+
+func (b cacheBackend) Increment(key string, delta int64) (int64, error) {
+	return b.cache.Increment(key, delta)
+}
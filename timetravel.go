@@ -0,0 +1,81 @@
+package addcache
+
+import (
+	"io"
+	"sort"
+	"strings"
+	"time"
+)
+
+// StateAt reconstructs the live entries at instant at from a snapshot taken before it and the
+// append-only log recorded after the snapshot, so past cache contents can be inspected.
+// Either reader may be nil. Options select the codec and key the snapshot and log were written with.
+// Log records older than the entry they would change are skipped, so the log may overlap the snapshot.
+// Background compaction of the log drops the history it folds in.
+func StateAt(snapshot io.Reader, log io.Reader, at time.Time, options ...Option) ([]SnapshotEntry, error) {
+	var s storage
+	for _, option := range options {
+		option(&s)
+	}
+	if s.optionErr != nil {
+		return nil, s.optionErr
+	}
+
+	state := make(map[string]SnapshotEntry)
+	if snapshot != nil {
+		entries, err := readSnapshot(snapshot, s.snapshotCodec, s.aead)
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range entries {
+			state[entry.Key] = entry
+		}
+	}
+	if log != nil {
+		records, _, err := decodeAOF(log, s.aead)
+		if err != nil {
+			return nil, err
+		}
+		for _, record := range records {
+			if record.Time.After(at) {
+				break
+			}
+			applyRecord(state, record)
+		}
+	}
+
+	entries := make([]SnapshotEntry, 0, len(state))
+	for _, entry := range state {
+		if entry.Persistent || entry.Held || entry.SetTime.Add(entry.TTL).After(at) {
+			entries = append(entries, entry)
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Key < entries[j].Key })
+	return entries, nil
+}
+
+func applyRecord(state map[string]SnapshotEntry, record AOFRecord) {
+	switch record.Op {
+	case aofOpSet:
+		if current, ok := state[record.Key]; !ok || !record.SetTime.Before(current.SetTime) {
+			state[record.Key] = SnapshotEntry{
+				Key:        record.Key,
+				Data:       record.Data,
+				SetTime:    record.SetTime,
+				TTL:        record.TTL,
+				Persistent: record.Persistent,
+				Held:       record.Held,
+			}
+		}
+	case aofOpDelete:
+		if current, ok := state[record.Key]; ok && !record.Time.Before(current.SetTime) {
+			delete(state, record.Key)
+		}
+	case aofOpEpoch:
+		for key, current := range state {
+			if strings.HasPrefix(key, record.Key) && !current.Held && !record.Time.Before(current.SetTime) {
+				delete(state, key)
+			}
+		}
+	}
+}
@@ -0,0 +1,32 @@
+package addcache
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+)
+
+// ImportFrom warms the cache with the live entries of another instance, fetched from the
+// /export path of its Handler, e.g. "http://peer:8080/admin/export", with token as bearer token.
+// Remaining TTLs are preserved as far as the clocks of both instances agree. The snapshot is
+// decoded with the snapshot options of this cache, so both instances need the same
+// WithSnapshotCodec and WithEncryption. A nil client uses http.DefaultClient, ctx bounds the transfer.
+func (s *storage) ImportFrom(ctx context.Context, endpoint, token string, client *http.Client) error {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Authorization", "Bearer "+token)
+	response, err := client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return fmt.Errorf("import: unexpected status %s", response.Status)
+	}
+	return s.LoadFrom(response.Body)
+}
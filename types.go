@@ -0,0 +1,134 @@
+package addcache
+
+import (
+	"encoding/gob"
+	"errors"
+	"time"
+)
+
+// The container values of sets, and of hashes and lists stored before *Hash and *List, are
+// registered for snapshots and the append-only log.
+func init() {
+	gob.Register(map[string]any{})
+	gob.Register([]any{})
+	gob.Register(map[string]bool{})
+}
+
+// errUnchanged is returned by the modify function of update to leave the key as it is.
+var errUnchanged = errors.New("unchanged")
+
+// update replaces the value of the key with the result of modify in one step under the write lock.
+// Modify receives the live value and whether there was one, returning nil deletes the key and
+// errUnchanged leaves it alone.
+// Existing entries keep their expiration, new ones are stored like Set. Modify must not change
+// the value it receives, containers are copied on write so readers never see partial updates.
+func (s *storage) update(key string, modify func(current any, ok bool) (any, error)) error {
+	if err := s.writable(); err != nil {
+		s.countersOf(key).reject()
+		return err
+	}
+	result := s.updateLocked(key, modify)
+	s.finishRemovals(result.removals...)
+	if result.rejected {
+		s.countersOf(key).reject()
+	}
+	if result.err != nil || !result.written {
+		return result.err
+	}
+	s.countersOf(key).set()
+	s.notifyWrite(key, result.next)
+	s.checkPressure(key, result.entries)
+	s.checkQuota(key)
+	return nil
+}
+
+// updateResult is the outcome of updateLocked, rejected marks errors of the write itself.
+type updateResult struct {
+	removals []removal
+	next     any
+	replaced bool
+	written  bool
+	entries  int
+	err      error
+	rejected bool
+}
+
+// updateLocked runs modify and applies its result under the write lock, which is released even
+// when modify panics.
+func (s *storage) updateLocked(key string, modify func(current any, ok bool) (any, error)) (r updateResult) {
+	now := s.now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	defer func() {
+		s.queueRemovalsLocked(r.removals...)
+		if r.written {
+			s.queueWriteLocked(key, r.next, r.replaced)
+		}
+	}()
+	value, ok := s.data[key]
+	if ok && s.expiredLocked(key, value, now) {
+		s.deleteLocked(key)
+		r.removals = append(r.removals, removal{key: key, entry: value, reason: RemovalExpired})
+		ok = false
+	}
+	var current any
+	if ok {
+		if current, r.err = s.decodeValue(value.data); r.err != nil {
+			return r
+		}
+	}
+	next, err := modify(current, ok)
+	if errors.Is(err, errUnchanged) || err == nil && next == nil && !ok {
+		return r
+	}
+	if err != nil {
+		r.err = err
+		return r
+	}
+	if next == nil {
+		if !s.protected(key, value, now) {
+			s.deleteLocked(key)
+			r.removals = append(r.removals, removal{key: key, entry: value, reason: RemovalDeleted})
+		}
+		return r
+	}
+	sd := s.newEntry(next, now)
+	if ok {
+		sd = value
+	}
+	if sd.data, r.err = s.encodeValue(key, next); r.err != nil {
+		r.rejected = true
+		return r
+	}
+	_, _, evicted, err := s.insertLocked(key, sd)
+	r.removals = append(r.removals, evicted...)
+	if err != nil {
+		r.err, r.rejected = err, true
+		return r
+	}
+	r.next, r.replaced, r.written, r.entries = next, ok, true, len(s.data)
+	return r
+}
+
+// Expire sets the remaining lifetime of a live key to ttl without rewriting its value, zero or
+// less makes it persistent. Missing keys are reported with ErrCacheKeyNotFound.
+func (s *storage) Expire(key string, ttl time.Duration) error {
+	if err := s.writable(); err != nil {
+		return err
+	}
+	now := s.now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	value, ok := s.data[key]
+	if !ok || s.expiredLocked(key, value, now) {
+		return ErrCacheKeyNotFound
+	}
+	value.isPersistence = ttl <= 0
+	value.setTime = now
+	value.expireDuration = 0
+	if ttl > 0 {
+		value.expireDuration = s.jitter.apply(ttl)
+	}
+	s.putLocked(key, s.applyRetention(key, value))
+	return nil
+}
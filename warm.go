@@ -0,0 +1,67 @@
+package addcache
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	WarmOperation OperationType = "Warm"
+
+	warmProgressInterval = 1000
+)
+
+// WarmProgress is passed as data to Warm hooks after every 1000 emitted entries and once with
+// Done set when Warm returns. Rejected counts entries the cache refused, e.g. over a limit.
+type WarmProgress struct {
+	Loaded   int64
+	Rejected int64
+	Elapsed  time.Duration
+	Done     bool
+	Err      error
+}
+
+// Warm fills the cache from loader before the service takes traffic, e.g. from a database, a
+// file or another instance. The loader calls emit for every entry, from several goroutines if it
+// likes, a zero ttl stores the entry like Set. Entries are not written through WithWriteThrough.
+// Once ctx is done further entries are dropped and Warm returns the error of ctx, otherwise the
+// error of the loader. Progress is reported to Warm hooks.
+func (s *storage) Warm(ctx context.Context, loader func(emit func(key string, value any, ttl time.Duration)) error) error {
+	if err := s.writable(); err != nil {
+		return err
+	}
+	started := s.now()
+	var loaded, rejected int64
+	emit := func(key string, value any, ttl time.Duration) {
+		if ctx.Err() != nil {
+			return
+		}
+		sd := s.newEntry(value, s.now())
+		if ttl > 0 {
+			sd = storageData{setTime: s.now(), expireDuration: s.jitter.apply(ttl), data: value}
+		}
+		if _, _, err := s.storeLocal(key, sd); err != nil {
+			atomic.AddInt64(&rejected, 1)
+		}
+		if n := atomic.AddInt64(&loaded, 1); n%warmProgressInterval == 0 {
+			s.processHooks(WarmOperation, "", WarmProgress{
+				Loaded:   n,
+				Rejected: atomic.LoadInt64(&rejected),
+				Elapsed:  s.now().Sub(started),
+			})
+		}
+	}
+	err := loader(emit)
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		err = ctxErr
+	}
+	s.processHooks(WarmOperation, "", WarmProgress{
+		Loaded:   atomic.LoadInt64(&loaded),
+		Rejected: atomic.LoadInt64(&rejected),
+		Elapsed:  s.now().Sub(started),
+		Done:     true,
+		Err:      err,
+	})
+	return err
+}
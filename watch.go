@@ -0,0 +1,223 @@
+package addcache
+
+import (
+	"path"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const defaultWatchBuffer = 64
+
+// EventType names the change an Event reports.
+type EventType string
+
+const (
+	EventCreate EventType = "Create"
+	EventUpdate EventType = "Update"
+	EventDelete EventType = "Delete"
+	EventExpire EventType = "Expire"
+	EventEvict  EventType = "Evict"
+)
+
+// Event is a change of a key streamed by Watch. Value is the written data, or the removed data of
+// Delete, Expire and Evict events.
+type Event struct {
+	Type  EventType
+	Key   string
+	Value any
+	Time  time.Time
+}
+
+// CancelFunc ends a subscription and closes its channel.
+type CancelFunc func()
+
+type watcher struct {
+	pattern string
+	glob    bool
+	events  chan Event
+	from    uint64
+}
+
+// notifications queues the changes for Watch and Subscribe while the write lock is held and
+// delivers them once it is released, so concurrent writes of a key arrive in the order they were
+// made. Nothing is queued without subscriptions.
+type notifications struct {
+	listeners int32
+	seq       uint64
+	mu        sync.Mutex
+	queued    []notification
+	deliverMu sync.Mutex
+}
+
+// notification is a queued change, the data of removals is the stored one, decoded on delivery.
+type notification struct {
+	seq       uint64
+	eventType EventType
+	key       string
+	value     any
+	stored    bool
+	time      time.Time
+}
+
+// WithWatchBuffer sets the channel capacity of Watch subscriptions, 64 by default.
+func WithWatchBuffer(size int) Option {
+	return func(s *storage) {
+		s.watchBuffer = size
+	}
+}
+
+// Watch streams the changes of keys matching pattern, a glob as understood by path.Match when it
+// contains any of *?[ and a key prefix otherwise. Events arrive in the order the changes were
+// made and are sent without blocking the writer, a subscriber falling behind by more than the
+// WithWatchBuffer capacity misses the newest events. The channel is closed by the returned
+// CancelFunc or by Close.
+func (s *storage) Watch(pattern string) (<-chan Event, CancelFunc) {
+	size := s.watchBuffer
+	if size <= 0 {
+		size = defaultWatchBuffer
+	}
+	w := &watcher{
+		pattern: pattern,
+		glob:    strings.ContainsAny(pattern, "*?["),
+		events:  make(chan Event, size),
+	}
+	s.mu.Lock()
+	s.hooksMu.Lock()
+	if atomic.LoadInt32(&s.closed) == 1 {
+		s.hooksMu.Unlock()
+		s.mu.Unlock()
+		close(w.events)
+		return w.events, func() {}
+	}
+	w.from = s.notifications.seq
+	s.watchers = append(s.watchers, w)
+	atomic.AddInt32(&s.notifications.listeners, 1)
+	s.hooksMu.Unlock()
+	s.mu.Unlock()
+	return w.events, func() {
+		s.hooksMu.Lock()
+		defer s.hooksMu.Unlock()
+		for i, registered := range s.watchers {
+			if registered == w {
+				s.watchers = append(s.watchers[:i:i], s.watchers[i+1:]...)
+				atomic.AddInt32(&s.notifications.listeners, -1)
+				close(w.events)
+				return
+			}
+		}
+	}
+}
+
+func (w *watcher) matches(key string) bool {
+	if !w.glob {
+		return strings.HasPrefix(key, w.pattern)
+	}
+	matched, _ := path.Match(w.pattern, key)
+	return matched
+}
+
+// queueLocked queues a change for the subscriptions, the caller holds the write lock.
+func (s *storage) queueLocked(eventType EventType, key string, value any, stored bool) {
+	n := &s.notifications
+	if atomic.LoadInt32(&n.listeners) == 0 {
+		return
+	}
+	n.seq++
+	n.mu.Lock()
+	n.queued = append(n.queued, notification{seq: n.seq, eventType: eventType, key: key, value: value, stored: stored, time: s.now()})
+	n.mu.Unlock()
+}
+
+// queueWriteLocked queues the event of a write, updated tells a live key was overwritten.
+func (s *storage) queueWriteLocked(key string, data any, updated bool) {
+	eventType := EventCreate
+	if updated {
+		eventType = EventUpdate
+	}
+	s.queueLocked(eventType, key, data, false)
+}
+
+// queueRemovalsLocked queues the events of removals, replaced data is reported by the Update
+// event of the write. The caller holds the write lock.
+func (s *storage) queueRemovalsLocked(removals ...removal) {
+	for _, r := range removals {
+		if eventType, ok := removalEvents[r.reason]; ok {
+			s.queueLocked(eventType, r.key, r.entry.data, true)
+		}
+	}
+}
+
+// deliverQueued publishes the queued changes in the order they were queued, one caller at a
+// time. Writers call it after releasing the write lock.
+func (s *storage) deliverQueued() {
+	n := &s.notifications
+	if atomic.LoadInt32(&n.listeners) == 0 {
+		return
+	}
+	n.deliverMu.Lock()
+	defer n.deliverMu.Unlock()
+	for {
+		n.mu.Lock()
+		queued := n.queued
+		n.queued = nil
+		n.mu.Unlock()
+		if len(queued) == 0 {
+			return
+		}
+		for _, change := range queued {
+			if change.stored {
+				change.value = s.valueOf(change.value)
+			}
+			s.publish(change)
+		}
+	}
+}
+
+// publish sends the event to the matching watchers, dropping it for those with a full buffer,
+// and the value to the subscribers of the key. Subscriptions made after the change skip it.
+func (s *storage) publish(change notification) {
+	s.hooksMu.RLock()
+	defer s.hooksMu.RUnlock()
+	s.deliver(change)
+	if len(s.watchers) == 0 {
+		return
+	}
+	event := Event{Type: change.eventType, Key: change.key, Value: change.value, Time: change.time}
+	for _, w := range s.watchers {
+		if change.seq <= w.from || !w.matches(change.key) {
+			continue
+		}
+		select {
+		case w.events <- event:
+		default:
+		}
+	}
+}
+
+// notifyWrite delivers the queued changes and runs the Create hooks of a write.
+func (s *storage) notifyWrite(key string, data any) {
+	s.deliverQueued()
+	s.processHooks(CreateOperation, key, data)
+}
+
+// removalEvents maps the reasons of removals to the events published for them, replaced data
+// is reported by the Update event of the write.
+var removalEvents = map[RemovalReason]EventType{
+	RemovalDeleted: EventDelete,
+	RemovalExpired: EventExpire,
+	RemovalEvicted: EventEvict,
+}
+
+// closeWatchers ends all subscriptions of Watch and Subscribe when the cache is closed.
+func (s *storage) closeWatchers() {
+	s.hooksMu.Lock()
+	defer s.hooksMu.Unlock()
+	for _, w := range s.watchers {
+		close(w.events)
+	}
+	s.watchers = nil
+	s.closeSubscribersLocked()
+	atomic.StoreInt32(&s.notifications.listeners, 0)
+}
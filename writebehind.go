@@ -0,0 +1,243 @@
+package addcache
+
+import (
+	"sync"
+	"time"
+)
+
+const DeadLetterOperation OperationType = "DeadLetter"
+
+// DeadLetterEvent is passed as data to DeadLetter hooks for a queued write the BackingStore
+// still refused after the last retry. Deleted marks a queued Delete, Value is nil then.
+type DeadLetterEvent struct {
+	Key      string
+	Value    any
+	Deleted  bool
+	Attempts int
+	Err      error
+}
+
+// BatchBackingStore is implemented by backing stores writing many entries in one round trip,
+// write-behind flushes use it instead of Store when available.
+type BatchBackingStore interface {
+	BackingStore
+	StoreBatch(entries map[string]any) error
+}
+
+// defaultWriteBehindAttempts is the number of tries of a queued write without WithWriteBehindRetries.
+const defaultWriteBehindAttempts = 3
+
+type pendingWrite struct {
+	data     any
+	deleted  bool
+	attempts int
+	// flushing marks a write taken by a flush, it is never changed again and stays pending
+	// until the store acknowledged it, so reads keep finding it.
+	flushing bool
+}
+
+// writeBehind queues writes in arrival order, a key queued again keeps its place with the latest value.
+type writeBehind struct {
+	interval  time.Duration
+	batchSize int
+
+	mu      sync.Mutex
+	order   []string
+	pending map[string]*pendingWrite
+	full    chan struct{}
+}
+
+// WithWriteBehind makes the writes of WithWriteThrough asynchronous: they change the cache at
+// once and are queued for store, which receives them in batches of up to batchSize every
+// interval or as soon as batchSize writes are waiting. Only the latest write of a key is kept.
+// Keys missing in the cache are still loaded from store, queued writes take precedence. Failed
+// writes are retried with the next flush and handed to DeadLetter hooks after the attempts of
+// WithWriteBehindRetries, 3 by default. Close flushes the queue, retrying without waiting.
+func WithWriteBehind(store BackingStore, interval time.Duration, batchSize int) Option {
+	return func(s *storage) {
+		if interval <= 0 || batchSize <= 0 {
+			return
+		}
+		s.backing.store = store
+		s.writeBehind = &writeBehind{
+			interval:  interval,
+			batchSize: batchSize,
+			pending:   make(map[string]*pendingWrite),
+			full:      make(chan struct{}, 1),
+		}
+	}
+}
+
+// WithWriteBehindRetries sets how often a queued write is tried before it goes to DeadLetter hooks.
+func WithWriteBehindRetries(attempts int) Option {
+	return func(s *storage) {
+		if attempts > 0 {
+			s.writeBehindAttempts = attempts
+		}
+	}
+}
+
+// maxWriteBehindAttempts returns the tries of WithWriteBehindRetries or the default.
+func (s *storage) maxWriteBehindAttempts() int {
+	if s.writeBehindAttempts > 0 {
+		return s.writeBehindAttempts
+	}
+	return defaultWriteBehindAttempts
+}
+
+// enqueue queues a write and reports whether a batch is ready. A key whose write is being
+// flushed is queued again behind it.
+func (w *writeBehind) enqueue(key string, data any, deleted bool) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if p, ok := w.pending[key]; ok && !p.flushing {
+		p.data, p.deleted, p.attempts = data, deleted, 0
+	} else {
+		w.pending[key] = &pendingWrite{data: data, deleted: deleted}
+		w.order = append(w.order, key)
+	}
+	return len(w.order) >= w.batchSize
+}
+
+// lookup returns the queued write of key, if any.
+func (w *writeBehind) lookup(key string) (pendingWrite, bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if p, ok := w.pending[key]; ok {
+		return *p, true
+	}
+	return pendingWrite{}, false
+}
+
+// take removes up to n writes from the front of the queue, they stay pending until done or requeue.
+func (w *writeBehind) take(n int) map[string]*pendingWrite {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if n > len(w.order) {
+		n = len(w.order)
+	}
+	batch := make(map[string]*pendingWrite, n)
+	for _, key := range w.order[:n] {
+		p := w.pending[key]
+		p.flushing = true
+		batch[key] = p
+	}
+	w.order = w.order[n:]
+	return batch
+}
+
+// done drops a flushed write unless the key was written again in the meantime.
+func (w *writeBehind) done(key string, p *pendingWrite) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.pending[key] == p {
+		delete(w.pending, key)
+	}
+}
+
+// requeue puts a failed write back with one more attempt unless the key was written again in the meantime.
+func (w *writeBehind) requeue(key string, p *pendingWrite) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.pending[key] != p {
+		return
+	}
+	w.pending[key] = &pendingWrite{data: p.data, deleted: p.deleted, attempts: p.attempts + 1}
+	w.order = append(w.order, key)
+}
+
+func (w *writeBehind) queued() int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return len(w.order)
+}
+
+// queueWrite queues a write or delete and starts a flush once a batch is ready.
+func (s *storage) queueWrite(key string, data any, deleted bool) {
+	if !s.writeBehind.enqueue(key, data, deleted) {
+		return
+	}
+	if !backgroundTasks {
+		s.flushWriteBehind()
+		return
+	}
+	select {
+	case s.writeBehind.full <- struct{}{}:
+	default:
+	}
+}
+
+func (s *storage) writeBehindLoop(t Ticker) {
+	defer t.Stop()
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-t.C():
+			s.flushWriteBehind()
+		case <-s.writeBehind.full:
+			s.flushWriteBehind()
+		}
+	}
+}
+
+// flushWriteBehind writes the queued writes to the backing store batch by batch. Writes queued
+// or requeued while it runs wait for the next flush.
+func (s *storage) flushWriteBehind() {
+	for remaining := s.writeBehind.queued(); remaining > 0; remaining -= s.writeBehind.batchSize {
+		s.flushBatch(s.writeBehind.take(s.writeBehind.batchSize))
+	}
+}
+
+// drainWriteBehind flushes until the queue is empty, failing writes end up as dead letters.
+func (s *storage) drainWriteBehind() {
+	for s.writeBehind.queued() > 0 {
+		s.flushWriteBehind()
+	}
+}
+
+func (s *storage) flushBatch(batch map[string]*pendingWrite) {
+	stores := make(map[string]any, len(batch))
+	for key, p := range batch {
+		if p.deleted {
+			s.settleFlush(key, p, s.deleteThroughNow(key))
+		} else {
+			stores[key] = p.data
+		}
+	}
+	if len(stores) == 0 {
+		return
+	}
+	if batchStore, ok := s.backing.store.(BatchBackingStore); ok && len(stores) > 1 {
+		err := batchStore.StoreBatch(stores)
+		for key := range stores {
+			s.settleFlush(key, batch[key], err)
+		}
+		return
+	}
+	for key, data := range stores {
+		s.settleFlush(key, batch[key], s.backing.store.Store(key, data))
+	}
+}
+
+// settleFlush settles a flushed write: it is done when the store took it, otherwise retried
+// with the next flush or handed to DeadLetter hooks.
+func (s *storage) settleFlush(key string, p *pendingWrite, err error) {
+	if err == nil {
+		s.writeBehind.done(key, p)
+		return
+	}
+	s.reportStoreError("write-behind", key, err)
+	if p.attempts+1 < s.maxWriteBehindAttempts() {
+		s.writeBehind.requeue(key, p)
+		return
+	}
+	s.writeBehind.done(key, p)
+	s.processHooks(DeadLetterOperation, key, DeadLetterEvent{
+		Key:      key,
+		Value:    p.data,
+		Deleted:  p.deleted,
+		Attempts: p.attempts + 1,
+		Err:      err,
+	})
+}
@@ -0,0 +1,141 @@
+package addcache
+
+import (
+	"errors"
+	"fmt"
+)
+
+// BackingStore is the system of record behind the cache, e.g. a database, Redis or S3.
+// Load reports missing keys with ErrCacheKeyNotFound.
+type BackingStore interface {
+	Load(key string) (any, error)
+	Store(key string, data any) error
+	Delete(key string) error
+}
+
+// StoreErrorPolicy decides what a failing BackingStore means for the cache operation.
+type StoreErrorPolicy int
+
+const (
+	// FailOpen carries on with the cache alone: writes and deletes still change the cache and
+	// reads miss. Failures are only reported to the store error handler.
+	FailOpen StoreErrorPolicy = iota
+	// FailClosed leaves the cache untouched and fails the operation with ErrBackingStore, or
+	// counts it as rejection for methods without error result.
+	FailClosed
+)
+
+type backingStore struct {
+	store   BackingStore
+	policy  StoreErrorPolicy
+	onError BackendErrorHandler
+}
+
+// WithWriteThrough writes Set, SetEx, SetPersistent and GetAndSet to store before the cache,
+// removes the keys of Delete and GetAndDelete from it, and loads keys missing in the cache from
+// it on Get and GetResult. Expiration and eviction only drop the cached copy. Counters, locks,
+// lists, sets, hashes, sorted sets, snapshot restores and Warm stay local. Failures follow
+// WithStoreErrorPolicy, FailOpen by default.
+func WithWriteThrough(store BackingStore) Option {
+	return func(s *storage) {
+		s.backing.store = store
+	}
+}
+
+// WithStoreErrorPolicy sets how operations treat a failing BackingStore, see StoreErrorPolicy.
+func WithStoreErrorPolicy(policy StoreErrorPolicy) Option {
+	return func(s *storage) {
+		s.backing.policy = policy
+	}
+}
+
+// WithStoreErrorHandler receives every failed BackingStore call, failures are logged by default.
+func WithStoreErrorHandler(handler BackendErrorHandler) Option {
+	return func(s *storage) {
+		s.backing.onError = handler
+	}
+}
+
+// storeFailed reports a failed call and returns the error to fail the operation with, nil when it fails open.
+func (s *storage) storeFailed(operation, key string, err error) error {
+	s.reportStoreError(operation, key, err)
+	if s.backing.policy == FailClosed {
+		return fmt.Errorf("%w: %s", ErrBackingStore, err)
+	}
+	return nil
+}
+
+func (s *storage) reportStoreError(operation, key string, err error) {
+	if s.backing.onError != nil {
+		s.backing.onError(operation, key, err)
+	} else {
+		logBackendError(operation, key, err)
+	}
+}
+
+// writeThrough writes data to the backing store ahead of the cache.
+func (s *storage) writeThrough(key string, data any) error {
+	if s.backing.store == nil {
+		return nil
+	}
+	if s.writeBehind != nil {
+		s.queueWrite(key, data, false)
+		return nil
+	}
+	if err := s.backing.store.Store(key, data); err != nil {
+		return s.storeFailed("store", key, err)
+	}
+	return nil
+}
+
+// deleteThrough removes the key from the backing store ahead of the cache.
+func (s *storage) deleteThrough(key string) error {
+	if s.backing.store == nil {
+		return nil
+	}
+	if s.writeBehind != nil {
+		s.queueWrite(key, nil, true)
+		return nil
+	}
+	if err := s.deleteThroughNow(key); err != nil {
+		return s.storeFailed("delete", key, err)
+	}
+	return nil
+}
+
+// deleteThroughNow removes the key from the backing store, a key it does not know counts as removed.
+func (s *storage) deleteThroughNow(key string) error {
+	if err := s.backing.store.Delete(key); err != nil && !errors.Is(err, ErrCacheKeyNotFound) {
+		return err
+	}
+	return nil
+}
+
+// readThrough loads a key missing in the cache from the backing store and caches it like Set
+// unless the key was written meanwhile.
+func (s *storage) readThrough(key string) (any, error) {
+	guard := s.beginLoad(key)
+	defer s.endLoad(key, guard)
+	if s.writeBehind != nil {
+		if p, ok := s.writeBehind.lookup(key); ok {
+			if p.deleted {
+				return nil, ErrCacheKeyNotFound
+			}
+			s.storeLoaded(key, s.newEntry(p.data, s.now()), guard)
+			return p.data, nil
+		}
+	}
+	data, err := s.backing.store.Load(key)
+	if errors.Is(err, ErrCacheKeyNotFound) {
+		s.rememberMissing(key, err, guard)
+		return nil, ErrCacheKeyNotFound
+	}
+	if err != nil {
+		if err := s.storeFailed("load", key, err); err != nil {
+			return nil, err
+		}
+		return nil, ErrCacheKeyNotFound
+	}
+	s.storeLoaded(key, s.newEntry(data, s.now()), guard)
+	return data, nil
+}
@@ -0,0 +1,10 @@
+module github.com/addit-digital/addcache/zstd
+
+go 1.18
+
+require (
+	github.com/addit-digital/addcache v0.0.0
+	github.com/klauspost/compress v1.17.9
+)
+
+replace github.com/addit-digital/addcache => ../
@@ -0,0 +1,36 @@
+// Package zstd provides a Zstandard addcache.CompressionCodec.
+package zstd
+
+import (
+	"github.com/addit-digital/addcache"
+	"github.com/klauspost/compress/zstd"
+)
+
+// Compression compresses with github.com/klauspost/compress/zstd, encoders and decoders are shared.
+type Compression struct {
+	encoder *zstd.Encoder
+	decoder *zstd.Decoder
+}
+
+var _ addcache.CompressionCodec = (*Compression)(nil)
+
+// New creates a codec at the given encoder level, pass it to addcache.WithCompression.
+func New(level zstd.EncoderLevel) (*Compression, error) {
+	encoder, err := zstd.NewWriter(nil, zstd.WithEncoderLevel(level))
+	if err != nil {
+		return nil, err
+	}
+	decoder, err := zstd.NewReader(nil)
+	if err != nil {
+		return nil, err
+	}
+	return &Compression{encoder: encoder, decoder: decoder}, nil
+}
+
+func (c *Compression) Compress(data []byte) ([]byte, error) {
+	return c.encoder.EncodeAll(data, nil), nil
+}
+
+func (c *Compression) Decompress(data []byte) ([]byte, error) {
+	return c.decoder.DecodeAll(data, nil)
+}